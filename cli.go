@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/spf13/pflag"
 )
 
@@ -9,6 +11,29 @@ type CLIOptions struct {
 	ConfigPath       string
 	WatchFile        string
 	ClipboardBackend string
+	Backends         []string
+	WaitForFile      bool
+	Debounce         time.Duration
+	StableCheck      bool
+	WatchMode        string
+	PollInterval     time.Duration
+	InitialSync      string
+	Pick             string
+	Ignore           []string
+	Direction        string
+	Selection        string
+	MIMEType         string
+	Representations  []string
+	Secrets          string
+
+	ClipboardPollInterval time.Duration
+	ClipboardTimeout      time.Duration
+
+	// Args holds the positional arguments, i.e. a subcommand such as
+	// "history list".
+	Args []string
+
+	flags *pflag.FlagSet
 }
 
 func ParseCLI(args []string) (*CLIOptions, error) {
@@ -18,11 +43,68 @@ func ParseCLI(args []string) (*CLIOptions, error) {
 	fs.BoolVarP(&opts.ShowVersion, "version", "v", false, "show version")
 	fs.StringVarP(&opts.ConfigPath, "config", "c", "", "path to config file")
 	fs.StringVarP(&opts.WatchFile, "file", "f", "", "path to file to watch")
-	fs.StringVarP(&opts.ClipboardBackend, "backend", "b", "", "clipboard backend (wayland or x11)")
+	fs.StringVarP(&opts.ClipboardBackend, "backend", "b", "", "clipboard backend (auto, wayland, wayland-native, x11, x11-native, darwin, osc52, tmux, screen, command, fallback or fan-out)")
+	fs.StringSliceVar(&opts.Backends, "backends", nil, "backends to use, in order, with --backend fallback or fan-out (e.g. wayland,x11)")
+	fs.BoolVarP(&opts.WaitForFile, "wait", "w", false, "wait for the watch file to appear instead of exiting")
+	fs.DurationVarP(&opts.Debounce, "debounce", "d", 0, "quiet period to wait for after a change before syncing (e.g. 200ms)")
+	fs.BoolVar(&opts.StableCheck, "stable-check", false, "wait until the file size and mtime stop changing before syncing")
+	fs.StringVarP(&opts.WatchMode, "watch-mode", "m", "", "how to detect changes (inotify, poll or auto)")
+	fs.DurationVar(&opts.PollInterval, "poll-interval", 0, "how often to check the file in poll mode (e.g. 1s)")
+	fs.StringVarP(&opts.InitialSync, "initial-sync", "i", "", "sync on startup: file (file to clipboard), clipboard (clipboard to file) or none")
+	fs.StringVar(&opts.Pick, "pick", "", "for a directory or glob: sync the newest file or each created file (newest or created)")
+	fs.StringSliceVar(&opts.Ignore, "ignore", nil, "file name patterns to skip in a directory or glob (repeatable)")
+	fs.StringVar(&opts.Direction, "direction", "", "sync direction: file-to-clipboard, clipboard-to-file or both")
+	fs.StringVar(&opts.Selection, "selection", "", "which clipboard to sync: clipboard, primary, secondary or both (clipboard and primary)")
+	fs.StringVar(&opts.MIMEType, "mime-type", "", "MIME type of the watch file content, e.g. image/png, or auto to tell images from text")
+	fs.StringSliceVar(&opts.Representations, "representations", nil, "also offer the file as these types, on backends that can hold several (text/html from Markdown, text/uri-list from paths)")
+	fs.StringVar(&opts.Secrets, "secrets", "", "what to do with content holding secrets such as API tokens: off, warn, redact or block")
+	fs.DurationVar(&opts.ClipboardPollInterval, "clipboard-poll-interval", 0, "how often to check the clipboard for changes to write to the file (e.g. 500ms)")
+	fs.DurationVar(&opts.ClipboardTimeout, "clipboard-timeout", 0, "how long a clipboard read or write may take before it is given up on (e.g. 5s)")
 
 	if err := fs.Parse(args); err != nil {
 		return nil, err
 	}
+	opts.Args = fs.Args()
+	opts.flags = fs
 
 	return opts, nil
 }
+
+// cliOverrides maps each flag to the setting it overrides.
+var cliOverrides = []struct {
+	flag  string
+	apply func(o *CLIOptions, s *WatchSettings)
+}{
+	{"backend", func(o *CLIOptions, s *WatchSettings) { s.ClipboardBackend = o.ClipboardBackend }},
+	{"backends", func(o *CLIOptions, s *WatchSettings) { s.ClipboardBackends = o.Backends }},
+	{"wait", func(o *CLIOptions, s *WatchSettings) { s.WaitForFile = o.WaitForFile }},
+	{"debounce", func(o *CLIOptions, s *WatchSettings) { s.Debounce = o.Debounce }},
+	{"stable-check", func(o *CLIOptions, s *WatchSettings) { s.StableCheck = o.StableCheck }},
+	{"watch-mode", func(o *CLIOptions, s *WatchSettings) { s.WatchMode = WatchMode(o.WatchMode) }},
+	{"poll-interval", func(o *CLIOptions, s *WatchSettings) { s.PollInterval = o.PollInterval }},
+	{"initial-sync", func(o *CLIOptions, s *WatchSettings) { s.InitialSync = InitialSync(o.InitialSync) }},
+	{"pick", func(o *CLIOptions, s *WatchSettings) { s.Pick = Pick(o.Pick) }},
+	{"direction", func(o *CLIOptions, s *WatchSettings) { s.Direction = Direction(o.Direction) }},
+	{"selection", func(o *CLIOptions, s *WatchSettings) { s.Selection = Selection(o.Selection) }},
+	{"mime-type", func(o *CLIOptions, s *WatchSettings) { s.MIMEType = o.MIMEType }},
+	{"representations", func(o *CLIOptions, s *WatchSettings) { s.Representations = o.Representations }},
+	{"secrets", func(o *CLIOptions, s *WatchSettings) { s.Secrets.Action = o.Secrets }},
+	{"clipboard-poll-interval", func(o *CLIOptions, s *WatchSettings) { s.ClipboardPollInterval = o.ClipboardPollInterval }},
+	{"clipboard-timeout", func(o *CLIOptions, s *WatchSettings) { s.ClipboardTimeout = o.ClipboardTimeout }},
+	{"ignore", func(o *CLIOptions, s *WatchSettings) { s.Ignore = append(append([]string{}, s.Ignore...), o.Ignore...) }},
+}
+
+// ApplyTo overrides the settings of a watch with every flag that was given,
+// even one given its zero value such as --debounce 0.
+func (o *CLIOptions) ApplyTo(s *WatchSettings) {
+	for _, override := range cliOverrides {
+		if o.given(override.flag) {
+			override.apply(o, s)
+		}
+	}
+}
+
+// given reports whether the flag was on the command line.
+func (o *CLIOptions) given(name string) bool {
+	return o.flags != nil && o.flags.Changed(name)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"testing"
+	"time"
 )
 
 func TestParseCLI_VersionFlag(t *testing.T) {
@@ -91,3 +92,279 @@ func TestParseCLI_BackendFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCLI_WaitFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"long form", []string{"--wait"}},
+		{"short form", []string{"-w"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := ParseCLI(tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !opts.WaitForFile {
+				t.Error("expected WaitForFile to be true")
+			}
+		})
+	}
+}
+
+func TestParseCLI_DebounceFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"long form", []string{"--debounce", "250ms"}},
+		{"short form", []string{"-d", "250ms"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := ParseCLI(tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.Debounce != 250*time.Millisecond {
+				t.Errorf("expected Debounce to be 250ms, got %v", opts.Debounce)
+			}
+		})
+	}
+}
+
+func TestParseCLI_StableCheckFlag(t *testing.T) {
+	opts, err := ParseCLI([]string{"--stable-check"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !opts.StableCheck {
+		t.Error("expected StableCheck to be true")
+	}
+}
+
+func TestParseCLI_WatchModeFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"long form", []string{"--watch-mode", "poll"}},
+		{"short form", []string{"-m", "poll"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := ParseCLI(tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.WatchMode != "poll" {
+				t.Errorf("expected WatchMode to be 'poll', got '%s'", opts.WatchMode)
+			}
+		})
+	}
+}
+
+func TestParseCLI_PollIntervalFlag(t *testing.T) {
+	opts, err := ParseCLI([]string{"--poll-interval", "2s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.PollInterval != 2*time.Second {
+		t.Errorf("expected PollInterval to be 2s, got %v", opts.PollInterval)
+	}
+}
+
+func TestParseCLI_InitialSyncFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"long form", []string{"--initial-sync", "clipboard"}},
+		{"short form", []string{"-i", "clipboard"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := ParseCLI(tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.InitialSync != "clipboard" {
+				t.Errorf("expected InitialSync to be 'clipboard', got '%s'", opts.InitialSync)
+			}
+		})
+	}
+}
+
+func TestCLIOptions_ApplyTo_OverridesGivenFlags(t *testing.T) {
+	opts, err := ParseCLI([]string{"--backend", "x11", "--debounce", "1s", "--wait"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := DefaultConfig().WatchSettings
+	settings.WatchMode = WatchModePoll
+	opts.ApplyTo(&settings)
+
+	if settings.ClipboardBackend != "x11" {
+		t.Errorf("expected ClipboardBackend to be 'x11', got '%s'", settings.ClipboardBackend)
+	}
+	if settings.Debounce != time.Second {
+		t.Errorf("expected Debounce to be 1s, got %v", settings.Debounce)
+	}
+	if !settings.WaitForFile {
+		t.Error("expected WaitForFile to be true")
+	}
+	if settings.WatchMode != WatchModePoll {
+		t.Errorf("expected WatchMode to be kept as 'poll', got '%s'", settings.WatchMode)
+	}
+}
+
+func TestCLIOptions_ApplyTo_OverridesWithZeroValues(t *testing.T) {
+	opts, err := ParseCLI([]string{"--debounce", "0", "--stable-check=false"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := DefaultConfig().WatchSettings
+	settings.StableCheck = true
+	opts.ApplyTo(&settings)
+
+	if settings.Debounce != 0 {
+		t.Errorf("expected Debounce to be turned off, got %v", settings.Debounce)
+	}
+	if settings.StableCheck {
+		t.Error("expected StableCheck to be turned off")
+	}
+	if settings.PollInterval != defaultPollInterval {
+		t.Errorf("expected PollInterval to be kept as %v, got %v", defaultPollInterval, settings.PollInterval)
+	}
+}
+
+func TestParseCLI_PickAndIgnoreFlags(t *testing.T) {
+	opts, err := ParseCLI([]string{"--pick", "created", "--ignore", "*.bak", "--ignore", "*.orig"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Pick != "created" {
+		t.Errorf("expected Pick to be 'created', got '%s'", opts.Pick)
+	}
+	if len(opts.Ignore) != 2 || opts.Ignore[0] != "*.bak" || opts.Ignore[1] != "*.orig" {
+		t.Errorf("expected Ignore to be [*.bak *.orig], got %v", opts.Ignore)
+	}
+}
+
+func TestParseCLI_DirectionFlags(t *testing.T) {
+	opts, err := ParseCLI([]string{"--direction", "both", "--clipboard-poll-interval", "1s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Direction != "both" {
+		t.Errorf("expected Direction to be 'both', got '%s'", opts.Direction)
+	}
+	if opts.ClipboardPollInterval != time.Second {
+		t.Errorf("expected ClipboardPollInterval to be 1s, got %v", opts.ClipboardPollInterval)
+	}
+}
+
+func TestParseCLI_SelectionFlag(t *testing.T) {
+	opts, err := ParseCLI([]string{"--selection", "primary"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := DefaultConfig().WatchSettings
+	opts.ApplyTo(&settings)
+
+	if settings.Selection != SelectionPrimary {
+		t.Errorf("expected Selection to be 'primary', got '%s'", settings.Selection)
+	}
+}
+
+func TestParseCLI_BackendsFlag(t *testing.T) {
+	opts, err := ParseCLI([]string{"--backend", "fan-out", "--backends", "wayland,x11"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := DefaultConfig().WatchSettings
+	opts.ApplyTo(&settings)
+
+	if settings.ClipboardBackend != "fan-out" {
+		t.Errorf("expected ClipboardBackend to be 'fan-out', got '%s'", settings.ClipboardBackend)
+	}
+	if len(settings.ClipboardBackends) != 2 || settings.ClipboardBackends[0] != "wayland" || settings.ClipboardBackends[1] != "x11" {
+		t.Errorf("expected ClipboardBackends to be [wayland x11], got %v", settings.ClipboardBackends)
+	}
+}
+
+func TestParseCLI_ClipboardTimeoutFlag(t *testing.T) {
+	opts, err := ParseCLI([]string{"--clipboard-timeout", "2s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := DefaultConfig().WatchSettings
+	opts.ApplyTo(&settings)
+
+	if settings.ClipboardTimeout != 2*time.Second {
+		t.Errorf("expected ClipboardTimeout to be 2s, got %v", settings.ClipboardTimeout)
+	}
+}
+
+func TestParseCLI_MIMETypeFlag(t *testing.T) {
+	opts, err := ParseCLI([]string{"--mime-type", "image/png"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := DefaultConfig().WatchSettings
+	opts.ApplyTo(&settings)
+
+	if settings.MIMEType != "image/png" {
+		t.Errorf("expected MIMEType to be image/png, got %q", settings.MIMEType)
+	}
+}
+
+func TestParseCLI_RepresentationsFlag(t *testing.T) {
+	opts, err := ParseCLI([]string{"--representations", "text/html,text/uri-list"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := DefaultConfig().WatchSettings
+	opts.ApplyTo(&settings)
+
+	if len(settings.Representations) != 2 || settings.Representations[0] != MIMETypeHTML || settings.Representations[1] != MIMETypeURIList {
+		t.Errorf("expected Representations to be [text/html text/uri-list], got %v", settings.Representations)
+	}
+}
+
+func TestParseCLI_SecretsFlag(t *testing.T) {
+	opts, err := ParseCLI([]string{"--secrets", "redact"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := DefaultConfig().WatchSettings
+	opts.ApplyTo(&settings)
+
+	if settings.Secrets.Action != SecretActionRedact {
+		t.Errorf("expected Secrets.Action to be redact, got %q", settings.Secrets.Action)
+	}
+}
+
+func TestParseCLI_PositionalArgs(t *testing.T) {
+	opts, err := ParseCLI([]string{"--backend", "x11", "history", "show", "2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(opts.Args) != 3 || opts.Args[0] != "history" || opts.Args[1] != "show" || opts.Args[2] != "2" {
+		t.Errorf("expected Args to be [history show 2], got %v", opts.Args)
+	}
+}
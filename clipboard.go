@@ -1,28 +1,70 @@
 package main
 
 import (
-	"os/exec"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
 	"strings"
 )
 
 type (
-	CommandExecutor          func(cmd string, args ...string) ([]byte, error)
-	CommandWithStdinExecutor func(cmd string, stdin string, args ...string) error
+	CommandExecutor          func(ctx context.Context, cmd string, args ...string) ([]byte, error)
+	CommandWithStdinExecutor func(ctx context.Context, cmd string, stdin string, args ...string) error
+	// CommandFilterExecutor runs cmd with stdin and returns its stdout.
+	CommandFilterExecutor func(ctx context.Context, cmd string, stdin string, args ...string) ([]byte, error)
 )
 
-func defaultExec(cmd string, args ...string) ([]byte, error) {
-	return exec.Command(cmd, args...).Output()
+func defaultExec(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	c := commandContext(ctx, cmd, args...)
+	c.Stdout = &out
+	if err := runTool(c); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
 }
 
-func defaultExecWithStdin(cmd string, stdin string, args ...string) error {
-	c := exec.Command(cmd, args...)
+func defaultExecWithStdin(ctx context.Context, cmd string, stdin string, args ...string) error {
+	c := commandContext(ctx, cmd, args...)
 	c.Stdin = strings.NewReader(stdin)
-	return c.Run()
+	return runTool(c)
+}
+
+// Selection names one of the clipboards a backend can hold. Besides the
+// regular clipboard, X11 and Wayland have PRIMARY, which holds the current
+// text selection and is pasted with a middle click, and X11 also has the
+// rarely used SECONDARY.
+type Selection string
+
+const (
+	SelectionClipboard Selection = "clipboard"
+	SelectionPrimary   Selection = "primary"
+	SelectionSecondary Selection = "secondary"
+
+	// SelectionBoth is only valid in the config, and syncs CLIPBOARD and
+	// PRIMARY together.
+	SelectionBoth Selection = "both"
+)
+
+// expand returns the selections a configured value stands for.
+func (s Selection) expand() ([]Selection, error) {
+	switch s {
+	case SelectionClipboard, SelectionPrimary, SelectionSecondary:
+		return []Selection{s}, nil
+	case SelectionBoth:
+		return []Selection{SelectionClipboard, SelectionPrimary}, nil
+	default:
+		return nil, fmt.Errorf("unknown selection %q (use clipboard, primary, secondary or both)", s)
+	}
 }
 
 type Clipboard interface {
-	Read() (string, error)
-	Write(content string) error
+	// Read returns the content of the selection as mimeType.
+	Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error)
+	Write(ctx context.Context, selection Selection, content Content) error
 }
 
 type WaylandClipboard struct {
@@ -30,24 +72,46 @@ type WaylandClipboard struct {
 	execCommandWithStdin CommandWithStdinExecutor
 }
 
-func (w *WaylandClipboard) Read() (string, error) {
+// waylandArgs returns the wl-paste and wl-copy flags that pick the selection
+// and the type. Wayland has no SECONDARY. Without a type, both pick a text
+// type themselves.
+func waylandArgs(selection Selection, mimeType string) ([]string, error) {
+	var args []string
+	switch selection {
+	case SelectionClipboard:
+	case SelectionPrimary:
+		args = append(args, "--primary")
+	default:
+		return nil, unsupportedSelection("wayland", selection)
+	}
+	if !isTextType(mimeType) {
+		args = append(args, "--type", mimeType)
+	}
+	return args, nil
+}
+
+func (w *WaylandClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	args, err := waylandArgs(selection, mimeType)
+	if err != nil {
+		return nil, err
+	}
 	executor := w.execCommand
 	if executor == nil {
 		executor = defaultExec
 	}
-	out, err := executor("wl-paste", "-n")
-	if err != nil {
-		return "", err
-	}
-	return string(out), nil
+	return executor(ctx, "wl-paste", append([]string{"-n"}, args...)...)
 }
 
-func (w *WaylandClipboard) Write(content string) error {
+func (w *WaylandClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	args, err := waylandArgs(selection, content.Type)
+	if err != nil {
+		return err
+	}
 	executor := w.execCommandWithStdin
 	if executor == nil {
 		executor = defaultExecWithStdin
 	}
-	return executor("wl-copy", content)
+	return executor(ctx, "wl-copy", string(content.Data), args...)
 }
 
 type X11Clipboard struct {
@@ -60,53 +124,225 @@ type DarwinClipboard struct {
 	execCommandWithStdin CommandWithStdinExecutor
 }
 
-func (d *DarwinClipboard) Read() (string, error) {
+// darwinPreferArgs returns the pbpaste flag for the types the pasteboard
+// tools know. pbcopy recognizes RTF and PostScript from their headers.
+func darwinPreferArgs(mimeType string) ([]string, error) {
+	switch {
+	case isTextType(mimeType):
+		return nil, nil
+	case mimeType == "text/rtf":
+		return []string{"-Prefer", "rtf"}, nil
+	case mimeType == "application/postscript":
+		return []string{"-Prefer", "ps"}, nil
+	default:
+		return nil, unsupportedType("darwin", mimeType)
+	}
+}
+
+// darwinImageClasses are the AppleScript classes of the image types the
+// pasteboard holds, which pbcopy and pbpaste can't handle; osascript copies
+// and pastes those instead.
+var darwinImageClasses = map[string]string{
+	"image/png":  "PNGf",
+	"image/tiff": "TIFF",
+	"image/jpeg": "JPEG",
+	"image/gif":  "GIFf",
+}
+
+func (d *DarwinClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	if selection != SelectionClipboard {
+		return nil, unsupportedSelection("darwin", selection)
+	}
 	executor := d.execCommand
 	if executor == nil {
 		executor = defaultExec
 	}
-	out, err := executor("pbpaste")
+	if class, ok := darwinImageClasses[mimeType]; ok {
+		out, err := executor(ctx, "osascript", "-e", fmt.Sprintf("the clipboard as «class %s»", class))
+		if err != nil {
+			return nil, err
+		}
+		return parseAppleScriptData(out, class)
+	}
+	args, err := darwinPreferArgs(mimeType)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return string(out), nil
+	return executor(ctx, "pbpaste", args...)
 }
 
-func (d *DarwinClipboard) Write(content string) error {
+func (d *DarwinClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	if selection != SelectionClipboard {
+		return unsupportedSelection("darwin", selection)
+	}
+	if class, ok := darwinImageClasses[content.Type]; ok {
+		return d.writeImage(ctx, class, content.Data)
+	}
+	if _, err := darwinPreferArgs(content.Type); err != nil {
+		return err
+	}
 	executor := d.execCommandWithStdin
 	if executor == nil {
 		executor = defaultExecWithStdin
 	}
-	return executor("pbcopy", content)
+	return executor(ctx, "pbcopy", string(content.Data))
 }
 
-func (x *X11Clipboard) Read() (string, error) {
-	executor := x.execCommand
+// writeImage has osascript read the image from a temporary file, as
+// AppleScript has no way to take it from stdin.
+func (d *DarwinClipboard) writeImage(ctx context.Context, class string, data []byte) error {
+	f, err := os.CreateTemp("", "clipboard-txt-watcher-image-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	executor := d.execCommand
 	if executor == nil {
 		executor = defaultExec
 	}
-	out, err := executor("xclip", "-selection", "clipboard", "-o")
+	script := fmt.Sprintf("set the clipboard to (read (POSIX file %q) as «class %s»)", f.Name(), class)
+	_, err = executor(ctx, "osascript", "-e", script)
+	return err
+}
+
+// parseAppleScriptData decodes the «data PNGf89504E47…» that osascript
+// prints for binary clipboard content.
+func parseAppleScriptData(out []byte, class string) ([]byte, error) {
+	s := strings.TrimSpace(string(out))
+	s, ok := strings.CutPrefix(s, "«data "+class)
+	if ok {
+		s, ok = strings.CutSuffix(s, "»")
+	}
+	if !ok {
+		return nil, fmt.Errorf("darwin: unexpected osascript output for %s", class)
+	}
+	return hex.DecodeString(s)
+}
+
+// xclipArgs returns the xclip flags that pick the selection and, unless it
+// is text, the target.
+func xclipArgs(selection Selection, mimeType string) ([]string, error) {
+	if err := checkX11Selection(selection); err != nil {
+		return nil, err
+	}
+	args := []string{"-selection", string(selection)}
+	if !isTextType(mimeType) {
+		args = append(args, "-t", mimeType)
+	}
+	return args, nil
+}
+
+func (x *X11Clipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	args, err := xclipArgs(selection, mimeType)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	executor := x.execCommand
+	if executor == nil {
+		executor = defaultExec
 	}
-	return string(out), nil
+	return executor(ctx, "xclip", append(args, "-o")...)
 }
 
-func (x *X11Clipboard) Write(content string) error {
+func (x *X11Clipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	args, err := xclipArgs(selection, content.Type)
+	if err != nil {
+		return err
+	}
 	executor := x.execCommandWithStdin
 	if executor == nil {
 		executor = defaultExecWithStdin
 	}
-	return executor("xclip", content, "-selection", "clipboard")
+	return executor(ctx, "xclip", string(content.Data), args...)
 }
 
-func NewClipboard(backend string) Clipboard {
+func checkX11Selection(selection Selection) error {
+	switch selection {
+	case SelectionClipboard, SelectionPrimary, SelectionSecondary:
+		return nil
+	default:
+		return unsupportedSelection("x11", selection)
+	}
+}
+
+// NewClipboard creates the clipboard for a backend name, with the default
+// settings of backends that have any. "auto" is replaced by resolveBackend
+// beforehand, so detection runs once however many clipboards are created.
+func NewClipboard(backend string) (Clipboard, error) {
 	switch backend {
+	case "auto":
+		return nil, errors.New("the auto backend has to be resolved before the clipboard is created")
+	case "wayland":
+		return &WaylandClipboard{}, nil
+	case "wayland-native":
+		return &WaylandNativeClipboard{}, nil
 	case "x11":
-		return &X11Clipboard{}
+		return &X11Clipboard{}, nil
+	case "x11-native":
+		return &X11NativeClipboard{}, nil
 	case "darwin":
-		return &DarwinClipboard{}
+		return &DarwinClipboard{}, nil
+	case "osc52":
+		return NewOSC52Clipboard(OSC52Config{}), nil
+	case "tmux":
+		return NewTmuxClipboard(TmuxConfig{}), nil
+	case "screen":
+		return NewScreenClipboard(ScreenConfig{}), nil
+	case "command":
+		return nil, errors.New("the command backend needs a [command] table in the config")
+	case "fallback", "fan-out":
+		return nil, fmt.Errorf("the %s backend needs clipboard_backends", backend)
+	default:
+		return nil, fmt.Errorf("unknown clipboard backend %q (use auto, wayland, wayland-native, x11, x11-native, darwin, osc52, tmux, screen, command, fallback or fan-out)", backend)
+	}
+}
+
+// clipboardFor creates the clipboard of a watch, passing on the settings of
+// backends that have any. Every backend gets the clipboard timeout of its
+// own, so a hanging one can't hold up the others of a fallback or fan-out.
+func clipboardFor(s WatchSettings) (Clipboard, error) {
+	var cb Clipboard
+	switch s.ClipboardBackend {
+	case "osc52":
+		cb = NewOSC52Clipboard(s.OSC52)
+	case "tmux":
+		cb = NewTmuxClipboard(s.Tmux)
+	case "screen":
+		cb = NewScreenClipboard(s.Screen)
+	case "command":
+		cb = NewCommandClipboard(s.Command)
+	case "fallback", "fan-out":
+		if len(s.ClipboardBackends) == 0 {
+			return nil, fmt.Errorf("the %s backend needs clipboard_backends", s.ClipboardBackend)
+		}
+		backends := make([]NamedClipboard, 0, len(s.ClipboardBackends))
+		for _, name := range s.ClipboardBackends {
+			if name == "fallback" || name == "fan-out" {
+				return nil, fmt.Errorf("clipboard_backends can't include %s", name)
+			}
+			backend := s
+			backend.ClipboardBackend = name
+			cb, err := clipboardFor(backend)
+			if err != nil {
+				return nil, err
+			}
+			backends = append(backends, NamedClipboard{Name: name, Clipboard: cb})
+		}
+		return NewMultiClipboard(MultiMode(s.ClipboardBackend), backends...), nil
 	default:
-		return &WaylandClipboard{}
+		var err error
+		cb, err = NewClipboard(s.ClipboardBackend)
+		if err != nil {
+			return nil, err
+		}
 	}
+	return NewTimeoutClipboard(s.ClipboardBackend, s.ClipboardTimeout, cb), nil
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+)
+
+// DetectBackend picks the clipboard backend for the session the watcher
+// runs in, and says why.
+func DetectBackend() (backend, reason string, err error) {
+	return detectBackend(os.Getenv, exec.LookPath, runtime.GOOS)
+}
+
+func detectBackend(getenv func(string) string, lookPath func(string) (string, error), goos string) (string, string, error) {
+	if goos == "darwin" {
+		return "darwin", "running on macOS", nil
+	}
+	s := newSession(getenv, lookPath)
+	for _, rule := range backendRules {
+		if reason, ok := rule.match(s); ok {
+			return rule.backend, reason, nil
+		}
+	}
+	return "", "", errors.New("could not detect a clipboard backend: no display, tmux, screen or SSH session, and no clipboard tool installed; set clipboard_backend")
+}
+
+// session is what the environment says about the session the watcher runs
+// in. wayland and x11 say why there is a display of that kind, if there is.
+type session struct {
+	getenv   func(string) string
+	lookPath func(string) (string, error)
+	wayland  string
+	x11      string
+}
+
+func newSession(getenv func(string) string, lookPath func(string) (string, error)) session {
+	s := session{getenv: getenv, lookPath: lookPath}
+	switch {
+	case getenv("WAYLAND_DISPLAY") != "":
+		s.wayland = "WAYLAND_DISPLAY is set"
+	case getenv("XDG_SESSION_TYPE") == "wayland":
+		s.wayland = "XDG_SESSION_TYPE is wayland"
+	}
+	switch {
+	case getenv("DISPLAY") != "":
+		s.x11 = "DISPLAY is set"
+	case getenv("XDG_SESSION_TYPE") == "x11":
+		s.x11 = "XDG_SESSION_TYPE is x11"
+	}
+	return s
+}
+
+func (s session) installed(names ...string) bool {
+	for _, name := range names {
+		if _, err := s.lookPath(name); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// backendRules are tried in order, and the first that matches the session
+// picks the backend and says why.
+var backendRules = []struct {
+	backend string
+	match   func(s session) (string, bool)
+}{
+	{"wayland", func(s session) (string, bool) {
+		return s.wayland + " and wl-clipboard is installed", s.wayland != "" && s.installed("wl-copy", "wl-paste")
+	}},
+	{"wayland-native", func(s session) (string, bool) {
+		return s.wayland + " and wl-clipboard is not installed", s.wayland != "" && s.x11 == ""
+	}},
+	{"x11", func(s session) (string, bool) {
+		return s.x11 + " and xclip is installed", s.x11 != "" && s.installed("xclip")
+	}},
+	{"x11-native", func(s session) (string, bool) {
+		return s.x11 + " and xclip is not installed", s.x11 != ""
+	}},
+	{"tmux", func(s session) (string, bool) {
+		return "running inside tmux without a display", s.getenv("TMUX") != ""
+	}},
+	{"screen", func(s session) (string, bool) {
+		return "running inside GNU screen without a display", s.getenv("STY") != ""
+	}},
+	{"osc52", func(s session) (string, bool) {
+		return "running in an SSH session without a display", s.getenv("SSH_TTY") != ""
+	}},
+	// Nothing in the environment, as for a service started without it
+	{"wayland", func(s session) (string, bool) {
+		return "no display is set, but wl-clipboard is installed", s.installed("wl-copy", "wl-paste")
+	}},
+	{"x11", func(s session) (string, bool) {
+		return "no display is set, but xclip is installed", s.installed("xclip")
+	}},
+}
+
+// resolveBackend replaces "auto" in s, as the backend or one of the
+// clipboard_backends, with the detected backend, logging why it was chosen.
+func resolveBackend(s *WatchSettings, logger *log.Logger) error {
+	var detected string
+	detect := func() (string, error) {
+		if detected != "" {
+			return detected, nil
+		}
+		backend, reason, err := DetectBackend()
+		if err != nil {
+			return "", err
+		}
+		logger.Printf("Using the %s clipboard backend: %s", backend, reason)
+		detected = backend
+		return backend, nil
+	}
+
+	if s.ClipboardBackend == "auto" {
+		backend, err := detect()
+		if err != nil {
+			return err
+		}
+		s.ClipboardBackend = backend
+	}
+	if !slices.Contains(s.ClipboardBackends, "auto") {
+		return nil
+	}
+	// The list may be shared with other watches
+	s.ClipboardBackends = slices.Clone(s.ClipboardBackends)
+	for i, name := range s.ClipboardBackends {
+		if name != "auto" {
+			continue
+		}
+		backend, err := detect()
+		if err != nil {
+			return err
+		}
+		s.ClipboardBackends[i] = backend
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDetectBackend(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		installed []string
+		goos      string
+		want      string
+	}{
+		{"macOS", map[string]string{"DISPLAY": ":0"}, nil, "darwin", "darwin"},
+		{"wayland with wl-clipboard", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"wl-copy", "wl-paste", "xclip"}, "linux", "wayland"},
+		{"wayland session type", map[string]string{"XDG_SESSION_TYPE": "wayland"}, []string{"wl-copy", "wl-paste"}, "linux", "wayland"},
+		{"wayland without wl-clipboard", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, nil, "linux", "wayland-native"},
+		{"xwayland without wl-clipboard", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"xclip"}, "linux", "x11"},
+		{"x11 with xclip", map[string]string{"DISPLAY": ":0"}, []string{"xclip"}, "linux", "x11"},
+		{"x11 without xclip", map[string]string{"DISPLAY": ":0"}, nil, "linux", "x11-native"},
+		{"tmux over ssh", map[string]string{"TMUX": "/tmp/tmux-1000/default,1,0", "SSH_TTY": "/dev/pts/0"}, nil, "linux", "tmux"},
+		{"screen", map[string]string{"STY": "1234.pts-0.host"}, nil, "linux", "screen"},
+		{"ssh", map[string]string{"SSH_TTY": "/dev/pts/0"}, []string{"xclip"}, "linux", "osc52"},
+		{"service with wl-clipboard", nil, []string{"wl-copy", "wl-paste"}, "linux", "wayland"},
+		{"service with xclip", nil, []string{"xclip"}, "linux", "x11"},
+	}
+	for _, tt := range tests {
+		getenv := func(key string) string { return tt.env[key] }
+		lookPath := func(name string) (string, error) {
+			for _, installed := range tt.installed {
+				if name == installed {
+					return "/usr/bin/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		}
+
+		got, reason, err := detectBackend(getenv, lookPath, tt.goos)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q (%s)", tt.name, tt.want, got, reason)
+		}
+		if reason == "" {
+			t.Errorf("%s: expected a reason", tt.name)
+		}
+	}
+}
+
+func TestDetectBackend_NothingFound(t *testing.T) {
+	getenv := func(string) string { return "" }
+	lookPath := func(string) (string, error) { return "", errors.New("not found") }
+
+	if backend, _, err := detectBackend(getenv, lookPath, "linux"); err == nil {
+		t.Errorf("expected an error, got %q", backend)
+	}
+}
+
+func TestResolveBackend_LogsChoice(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("macOS always uses the darwin backend")
+	}
+	for _, key := range []string{"WAYLAND_DISPLAY", "DISPLAY", "XDG_SESSION_TYPE", "STY", "SSH_TTY"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+
+	var out bytes.Buffer
+	settings := DefaultConfig().WatchSettings
+	if err := resolveBackend(&settings, log.New(&out, "", 0)); err != nil {
+		t.Fatalf("resolveBackend failed: %v", err)
+	}
+
+	if settings.ClipboardBackend != "tmux" {
+		t.Errorf("expected backend %q, got %q", "tmux", settings.ClipboardBackend)
+	}
+	if !strings.Contains(out.String(), "Using the tmux clipboard backend: running inside tmux") {
+		t.Errorf("expected the choice to be logged, got %q", out.String())
+	}
+}
+
+func TestResolveBackend_ResolvesListedBackends(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("macOS always uses the darwin backend")
+	}
+	for _, key := range []string{"WAYLAND_DISPLAY", "DISPLAY", "XDG_SESSION_TYPE", "STY", "SSH_TTY"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+
+	var out bytes.Buffer
+	backends := []string{"auto", "osc52"}
+	settings := DefaultConfig().WatchSettings
+	settings.ClipboardBackends = backends
+	if err := resolveBackend(&settings, log.New(&out, "", 0)); err != nil {
+		t.Fatalf("resolveBackend failed: %v", err)
+	}
+
+	if settings.ClipboardBackend != "tmux" {
+		t.Errorf("expected backend %q, got %q", "tmux", settings.ClipboardBackend)
+	}
+	if want := []string{"tmux", "osc52"}; !reflect.DeepEqual(settings.ClipboardBackends, want) {
+		t.Errorf("expected backends %v, got %v", want, settings.ClipboardBackends)
+	}
+	if backends[0] != "auto" {
+		t.Errorf("expected the shared list to be left alone, got %v", backends)
+	}
+	if n := strings.Count(out.String(), "Using the tmux clipboard backend"); n != 1 {
+		t.Errorf("expected the choice to be logged once, got %q", out.String())
+	}
+
+	if _, err := clipboardFor(DefaultConfig().WatchSettings); err == nil {
+		t.Error("expected an error for a backend that wasn't resolved")
+	}
+}
+
+func TestClipboardFor_PassesBackendSettings(t *testing.T) {
+	settings := DefaultConfig().WatchSettings
+	settings.ClipboardBackend = "tmux"
+	settings.Tmux.Buffer = "clipboard"
+
+	cb, err := clipboardFor(settings)
+	if err != nil {
+		t.Fatalf("clipboardFor failed: %v", err)
+	}
+	timeout, ok := cb.(*TimeoutClipboard)
+	if !ok {
+		t.Fatalf("expected *TimeoutClipboard, got %T", cb)
+	}
+	if timeout.Timeout != defaultClipboardTimeout {
+		t.Errorf("expected timeout %v, got %v", defaultClipboardTimeout, timeout.Timeout)
+	}
+	tmux, ok := timeout.Clipboard.(*TmuxClipboard)
+	if !ok {
+		t.Fatalf("expected *TmuxClipboard, got %T", timeout.Clipboard)
+	}
+	if tmux.config.Buffer != "clipboard" {
+		t.Errorf("expected buffer %q, got %q", "clipboard", tmux.config.Buffer)
+	}
+
+	settings.ClipboardBackend = "clipbored"
+	if _, err := clipboardFor(settings); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultCommandTimeout = 5 * time.Second
+
+// How the write command gets the content.
+const (
+	CommandInputStdin = "stdin"
+	CommandInputArg   = "arg"
+
+	// commandContentPlaceholder is replaced with the content in the write
+	// command when it is passed as an argument.
+	commandContentPlaceholder = "{content}"
+)
+
+type CommandConfig struct {
+	Read       []string          `toml:"read"`
+	Write      []string          `toml:"write"`
+	WriteInput string            `toml:"write_input"`
+	Timeout    time.Duration     `toml:"timeout"`
+	Env        map[string]string `toml:"env"`
+}
+
+// CommandClipboard runs commands from the config to read and write the
+// clipboard, so any clipboard tool can be used without a backend of its
+// own. Without a read command, Read returns what was last written, which
+// suits tools that can only copy.
+type CommandClipboard struct {
+	config               CommandConfig
+	execCommand          CommandExecutor
+	execCommandWithStdin CommandWithStdinExecutor
+
+	mu      sync.Mutex
+	written []byte
+}
+
+func NewCommandClipboard(cfg CommandConfig) *CommandClipboard {
+	return &CommandClipboard{config: cfg}
+}
+
+func (c *CommandClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	if selection != SelectionClipboard {
+		return nil, unsupportedSelection("command", selection)
+	}
+	if !isTextType(mimeType) {
+		return nil, unsupportedType("command", mimeType)
+	}
+	if len(c.config.Read) == 0 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.written, nil
+	}
+	ctx, cancel := operationContext(ctx, "command", "read", c.config.Timeout)
+	defer cancel()
+	executor := c.execCommand
+	if executor == nil {
+		executor = c.exec
+	}
+	out, err := executor(ctx, c.config.Read[0], c.config.Read[1:]...)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	return out, nil
+}
+
+func (c *CommandClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	if selection != SelectionClipboard {
+		return unsupportedSelection("command", selection)
+	}
+	if !content.IsText() {
+		return unsupportedType("command", content.Type)
+	}
+	if len(c.config.Write) == 0 {
+		return errors.New("command: no write command configured")
+	}
+
+	ctx, cancel := operationContext(ctx, "command", "write", c.config.Timeout)
+	defer cancel()
+	var err error
+	switch c.config.WriteInput {
+	case "", CommandInputStdin:
+		executor := c.execCommandWithStdin
+		if executor == nil {
+			executor = c.execWithStdin
+		}
+		err = executor(ctx, c.config.Write[0], string(content.Data), c.config.Write[1:]...)
+	case CommandInputArg:
+		executor := c.execCommand
+		if executor == nil {
+			executor = c.exec
+		}
+		args := contentArgs(c.config.Write[1:], string(content.Data))
+		_, err = executor(ctx, c.config.Write[0], args...)
+	default:
+		return fmt.Errorf("command: unknown write_input %q (use stdin or arg)", c.config.WriteInput)
+	}
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	c.mu.Lock()
+	c.written = content.Data
+	c.mu.Unlock()
+	return nil
+}
+
+// contentArgs puts content in place of the placeholder, or after the last
+// argument if there is none.
+func contentArgs(args []string, content string) []string {
+	out := make([]string, 0, len(args)+1)
+	replaced := false
+	for _, arg := range args {
+		if strings.Contains(arg, commandContentPlaceholder) {
+			arg = strings.ReplaceAll(arg, commandContentPlaceholder, content)
+			replaced = true
+		}
+		out = append(out, arg)
+	}
+	if !replaced {
+		out = append(out, content)
+	}
+	return out
+}
+
+func (c *CommandClipboard) exec(ctx context.Context, cmd string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	command := c.command(ctx, cmd, args...)
+	command.Stdout = &out
+	if err := runTool(command); err != nil {
+		return nil, fmt.Errorf("command: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+func (c *CommandClipboard) execWithStdin(ctx context.Context, cmd string, stdin string, args ...string) error {
+	command := c.command(ctx, cmd, args...)
+	command.Stdin = strings.NewReader(stdin)
+	if err := runTool(command); err != nil {
+		return fmt.Errorf("command: %w", err)
+	}
+	return nil
+}
+
+// command prepares cmd with the configured environment.
+func (c *CommandClipboard) command(ctx context.Context, cmd string, args ...string) *exec.Cmd {
+	command := commandContext(ctx, cmd, args...)
+	if len(c.config.Env) > 0 {
+		keys := make([]string, 0, len(c.config.Env))
+		for key := range c.config.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		command.Env = os.Environ()
+		for _, key := range keys {
+			command.Env = append(command.Env, key+"="+c.config.Env[key])
+		}
+	}
+	return command
+}
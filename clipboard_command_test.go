@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCommandClipboard_RunsConfiguredCommands(t *testing.T) {
+	var readCmd, writeCmd string
+	var readArgs, writeArgs []string
+	var stdinContent string
+
+	cb := NewCommandClipboard(CommandConfig{
+		Read:  []string{"lemonade", "paste"},
+		Write: []string{"lemonade", "copy"},
+	})
+	cb.execCommand = func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+		readCmd, readArgs = cmd, args
+		return []byte("remote content"), nil
+	}
+	cb.execCommandWithStdin = func(_ context.Context, cmd string, stdin string, args ...string) error {
+		writeCmd, writeArgs, stdinContent = cmd, args, stdin
+		return nil
+	}
+
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("local content")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if readCmd != "lemonade" || !reflect.DeepEqual(readArgs, []string{"paste"}) {
+		t.Errorf("expected lemonade paste, got %s %v", readCmd, readArgs)
+	}
+	if string(content) != "remote content" {
+		t.Errorf("expected content %q, got %q", "remote content", content)
+	}
+	if writeCmd != "lemonade" || !reflect.DeepEqual(writeArgs, []string{"copy"}) {
+		t.Errorf("expected lemonade copy, got %s %v", writeCmd, writeArgs)
+	}
+	if stdinContent != "local content" {
+		t.Errorf("expected stdin %q, got %q", "local content", stdinContent)
+	}
+}
+
+func TestCommandClipboard_WritesContentAsArgument(t *testing.T) {
+	tests := []struct {
+		write []string
+		want  []string
+	}{
+		{[]string{"termux-clipboard-set"}, []string{"hello"}},
+		{[]string{"doitclient", "wclip", "--text={content}", "-q"}, []string{"wclip", "--text=hello", "-q"}},
+	}
+	for _, tt := range tests {
+		var calledArgs []string
+		cb := NewCommandClipboard(CommandConfig{Write: tt.write, WriteInput: CommandInputArg})
+		cb.execCommand = func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+			calledArgs = args
+			return nil, nil
+		}
+
+		if err := cb.Write(context.Background(), SelectionClipboard, TextContent("hello")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if !reflect.DeepEqual(calledArgs, tt.want) {
+			t.Errorf("%v: expected args %v, got %v", tt.write, tt.want, calledArgs)
+		}
+	}
+}
+
+func TestCommandClipboard_WriteOnlyToolReadsLastWrite(t *testing.T) {
+	cb := NewCommandClipboard(CommandConfig{Write: []string{"clip.exe"}})
+	cb.execCommandWithStdin = func(_ context.Context, cmd string, stdin string, args ...string) error { return nil }
+
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("copied")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(content) != "copied" {
+		t.Errorf("expected content %q, got %q", "copied", content)
+	}
+}
+
+func TestCommandClipboard_Errors(t *testing.T) {
+	if err := NewCommandClipboard(CommandConfig{}).Write(context.Background(), SelectionClipboard, TextContent("content")); err == nil {
+		t.Error("expected an error without a write command")
+	}
+	cb := NewCommandClipboard(CommandConfig{Write: []string{"tool"}, WriteInput: "pipe"})
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("content")); err == nil {
+		t.Error("expected an error for an unknown write_input")
+	}
+	if _, err := NewCommandClipboard(CommandConfig{}).Read(context.Background(), SelectionPrimary, MIMETypeText); err == nil {
+		t.Error("expected an error for the primary selection")
+	}
+}
+
+func TestCommandClipboard_PassesEnvironment(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	out := filepath.Join(t.TempDir(), "clipboard")
+	cb := NewCommandClipboard(CommandConfig{
+		Read:  []string{"sh", "-c", `printf %s "$CLIPBOARD_PREFIX"; cat "$CLIPBOARD_FILE"`},
+		Write: []string{"sh", "-c", `cat > "$CLIPBOARD_FILE"`},
+		Env:   map[string]string{"CLIPBOARD_FILE": out, "CLIPBOARD_PREFIX": "> "},
+	})
+
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("via sh")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "via sh" {
+		t.Errorf("expected the file to hold %q, got %q", "via sh", data)
+	}
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(content) != "> via sh" {
+		t.Errorf("expected content %q, got %q", "> via sh", content)
+	}
+}
+
+func TestCommandClipboard_TimesOut(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("no sleep")
+	}
+	cb := NewCommandClipboard(CommandConfig{Read: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.Backend != "command" || timeout.Timeout != 50*time.Millisecond {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the command to be stopped, took %v", elapsed)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// maxStderrSize is how much of what a tool printed on stderr is kept.
+const maxStderrSize = 4096
+
+var (
+	// ErrBackendNotInstalled means the tool a backend runs is not in $PATH.
+	ErrBackendNotInstalled = errors.New("clipboard tool not installed")
+	// ErrNoDisplay means there is no display server, or multiplexer
+	// session, for the backend to connect to.
+	ErrNoDisplay = errors.New("no display")
+	// ErrSelectionEmpty means the selection holds nothing to read.
+	ErrSelectionEmpty = errors.New("selection is empty")
+	// ErrUnsupportedSelection means the backend has no such selection.
+	ErrUnsupportedSelection = errors.New("unsupported selection")
+	// ErrUnsupportedType means the backend can't hold content of that MIME
+	// type.
+	ErrUnsupportedType = errors.New("unsupported content type")
+)
+
+type unsupportedSelectionError struct {
+	backend   string
+	selection Selection
+}
+
+func unsupportedSelection(backend string, selection Selection) error {
+	return &unsupportedSelectionError{backend: backend, selection: selection}
+}
+
+func (e *unsupportedSelectionError) Error() string {
+	return fmt.Sprintf("the %s backend does not support the %s selection", e.backend, e.selection)
+}
+
+func (e *unsupportedSelectionError) Is(target error) bool {
+	return target == ErrUnsupportedSelection
+}
+
+func unsupportedType(backend, mimeType string) error {
+	return fmt.Errorf("%s: %w %s", backend, ErrUnsupportedType, mimeType)
+}
+
+// stderrReasons tells from what a clipboard tool printed why it failed.
+var stderrReasons = []struct {
+	pattern *regexp.Regexp
+	reason  error
+}{
+	{regexp.MustCompile(`Failed to connect to a Wayland server`), ErrNoDisplay}, // wl-copy, wl-paste
+	{regexp.MustCompile(`Can't open display`), ErrNoDisplay},                    // xclip
+	{regexp.MustCompile(`no server running`), ErrNoDisplay},                     // tmux
+	{regexp.MustCompile(`No screen session found`), ErrNoDisplay},               // screen
+	{regexp.MustCompile(`Nothing is copied`), ErrSelectionEmpty},                // wl-paste
+	{regexp.MustCompile(`No selection`), ErrSelectionEmpty},                     // wl-paste
+	{regexp.MustCompile(`target \S+ not available`), ErrSelectionEmpty},         // xclip
+	{regexp.MustCompile(`no buffer`), ErrSelectionEmpty},                        // tmux
+}
+
+// CommandError is a clipboard tool that failed, with what it printed on
+// stderr. When that says why, the error also matches ErrNoDisplay or
+// ErrSelectionEmpty with errors.Is.
+type CommandError struct {
+	Cmd    string
+	Stderr string
+	// ExitCode is -1 if the command didn't exit by itself.
+	ExitCode int
+	Err      error
+
+	reason error
+}
+
+func (e *CommandError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s: %v", e.Cmd, e.Err)
+	}
+	return fmt.Sprintf("%s: %v: %s", e.Cmd, e.Err, e.Stderr)
+}
+
+func (e *CommandError) Unwrap() []error {
+	if e.reason == nil {
+		return []error{e.Err}
+	}
+	return []error{e.reason, e.Err}
+}
+
+// runTool runs c, keeping what it prints on stderr for the error. That goes
+// to a file rather than a pipe, as xclip and wl-copy leave a process behind
+// to serve the selection, which would hold a pipe open. Without a place for
+// the file, such as when $TMPDIR isn't writable, the tool still runs but
+// its stderr is lost. A failure is returned as a *CommandError, or as
+// ErrBackendNotInstalled if there is no such command.
+func runTool(c *exec.Cmd) error {
+	stderr, err := os.CreateTemp("", "clipboard-txt-watcher-stderr-")
+	if err == nil {
+		defer func() {
+			_ = stderr.Close()
+			_ = os.Remove(stderr.Name())
+		}()
+		c.Stderr = stderr
+	}
+
+	err = c.Run()
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%s: %w", c.Args[0], ErrBackendNotInstalled)
+	}
+
+	cmdErr := &CommandError{Cmd: c.Args[0], ExitCode: -1, Err: err}
+	if stderr != nil {
+		buf := make([]byte, maxStderrSize)
+		n, _ := stderr.ReadAt(buf, 0)
+		cmdErr.Stderr = strings.TrimSpace(string(buf[:n]))
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+	cmdErr.reason = stderrReason(cmdErr.Stderr)
+	return cmdErr
+}
+
+// stderrReason returns the reason for the first of stderrReasons that
+// stderr matches, or nil.
+func stderrReason(stderr string) error {
+	for _, r := range stderrReasons {
+		if r.pattern.MatchString(stderr) {
+			return r.reason
+		}
+	}
+	return nil
+}
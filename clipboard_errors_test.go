@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultExec_ReportsMissingTool(t *testing.T) {
+	_, err := defaultExec(context.Background(), "clipboard-txt-watcher-no-such-tool")
+	if !errors.Is(err, ErrBackendNotInstalled) {
+		t.Errorf("expected ErrBackendNotInstalled, got %v", err)
+	}
+}
+
+func TestDefaultExec_CapturesStderrAndExitCode(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+
+	_, err := defaultExec(context.Background(), "sh", "-c", "echo \"Error: Can't open display: (null)\" >&2; exit 2")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected a *CommandError, got %v", err)
+	}
+	if cmdErr.Cmd != "sh" || cmdErr.ExitCode != 2 || cmdErr.Stderr != "Error: Can't open display: (null)" {
+		t.Errorf("unexpected error %+v", cmdErr)
+	}
+	if !errors.Is(err, ErrNoDisplay) {
+		t.Error("expected the error to match ErrNoDisplay")
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Error("expected the error to keep the *exec.ExitError")
+	}
+}
+
+func TestDefaultExec_RunsWithoutTempDir(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+
+	if _, err := defaultExec(context.Background(), "sh", "-c", "exit 0"); err != nil {
+		t.Errorf("expected success, got %v", err)
+	}
+	_, err := defaultExec(context.Background(), "sh", "-c", "echo oops >&2; exit 3")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.ExitCode != 3 {
+		t.Errorf("expected a *CommandError with exit code 3, got %v", err)
+	}
+}
+
+func TestStderrReasons(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   error
+	}{
+		{"Error: target STRING not available", ErrSelectionEmpty},
+		{"Error: target image/png not available", ErrSelectionEmpty},
+		{"Error: Can't open display: (null)", ErrNoDisplay},
+		{"xsel: selection owner not available", nil},
+		{"/usr/bin/tool: resource not available", nil},
+	}
+	for _, tt := range tests {
+		if got := stderrReason(tt.stderr); got != tt.want {
+			t.Errorf("%q: expected reason %v, got %v", tt.stderr, tt.want, got)
+		}
+	}
+}
+
+func TestDefaultExecWithStdin_ReportsEmptySelection(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+
+	err := defaultExecWithStdin(context.Background(), "sh", "content", "-c", "cat >/dev/null; echo 'Nothing is copied' >&2; exit 1")
+	if !errors.Is(err, ErrSelectionEmpty) {
+		t.Errorf("expected ErrSelectionEmpty, got %v", err)
+	}
+	if errors.Is(err, ErrNoDisplay) {
+		t.Error("expected only one reason")
+	}
+}
+
+func TestDefaultExecWithStdin_DoesNotWaitForBackgroundProcess(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+
+	// Like xclip and wl-copy, which stay around to serve the selection
+	start := time.Now()
+	if err := defaultExecWithStdin(context.Background(), "sh", "content", "-c", "cat >/dev/null; sleep 5 & exit 0"); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= commandWaitDelay {
+		t.Errorf("expected to return once the command exits, took %v", elapsed)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MultiMode is how a MultiClipboard uses its backends.
+type MultiMode string
+
+const (
+	// MultiModeFallback uses the first backend that works.
+	MultiModeFallback MultiMode = "fallback"
+	// MultiModeFanOut writes to every backend and reads from the first that
+	// works.
+	MultiModeFanOut MultiMode = "fan-out"
+)
+
+// Syncer is implemented by clipboards that check for changes themselves
+// when SyncToClipboard updates them, because a single Read can't tell
+// whether every part of them is up to date.
+type Syncer interface {
+	Sync(ctx context.Context, selection Selection, content Content) error
+}
+
+// Holder is implemented by clipboards that serve what was written from this
+// process, so it is gone once the process exits. Hold waits until other
+// clients took every selection written over, or ctx is done.
+type Holder interface {
+	Hold(ctx context.Context) error
+}
+
+// holds reports whether cb, or a backend it wraps, serves what was written
+// itself.
+func holds(cb Clipboard) bool {
+	switch cb := cb.(type) {
+	case *TimeoutClipboard:
+		return holds(cb.Clipboard)
+	case *MultiClipboard:
+		for _, b := range cb.backends {
+			if holds(b.Clipboard) {
+				return true
+			}
+		}
+		return false
+	default:
+		_, ok := cb.(Holder)
+		return ok
+	}
+}
+
+type NamedClipboard struct {
+	Name string
+	Clipboard
+}
+
+// MultiClipboard combines several backends, in order, such as Wayland and
+// X11 in an Xwayland session where apps of either kind should see the
+// same clipboard.
+type MultiClipboard struct {
+	mode     MultiMode
+	backends []NamedClipboard
+}
+
+func NewMultiClipboard(mode MultiMode, backends ...NamedClipboard) *MultiClipboard {
+	return &MultiClipboard{mode: mode, backends: backends}
+}
+
+// Read returns the content of the first backend that can be read.
+func (m *MultiClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	var errs []error
+	for _, b := range m.backends {
+		// An empty selection is an answer, not a failure
+		data, err := b.Read(ctx, selection, mimeType)
+		if err == nil || errors.Is(err, ErrSelectionEmpty) {
+			return data, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+	}
+	return nil, m.failed(errs)
+}
+
+// Write writes to the first backend that works, or in fan-out mode to all of
+// them, failing if any does.
+func (m *MultiClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	var errs []error
+	for _, b := range m.backends {
+		err := b.Write(ctx, selection, content)
+		if err == nil && m.mode == MultiModeFallback {
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+		}
+	}
+	return m.failed(errs)
+}
+
+// Sync brings every backend that is out of date up to date in fan-out mode.
+// In fallback mode it is SyncToClipboard on the first backend that works.
+func (m *MultiClipboard) Sync(ctx context.Context, selection Selection, content Content) error {
+	var errs []error
+	for _, b := range m.backends {
+		err := SyncToClipboard(ctx, b.Clipboard, selection, content)
+		if err == nil && m.mode == MultiModeFallback {
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+		}
+	}
+	return m.failed(errs)
+}
+
+func (m *MultiClipboard) failed(errs []error) error {
+	switch {
+	case len(m.backends) == 0:
+		return errors.New("no clipboard backends configured")
+	case len(errs) == 0:
+		return nil
+	case m.mode == MultiModeFanOut:
+		return fmt.Errorf("%d of %d clipboard backends failed: %w", len(errs), len(m.backends), errors.Join(errs...))
+	default:
+		return fmt.Errorf("all clipboard backends failed: %w", errors.Join(errs...))
+	}
+}
+
+// Hold waits for every backend that serves what was written itself.
+func (m *MultiClipboard) Hold(ctx context.Context) error {
+	for _, b := range m.backends {
+		if h, ok := b.Clipboard.(Holder); ok {
+			if err := h.Hold(ctx); err != nil {
+				return fmt.Errorf("%s: %w", b.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the backends that keep connections open.
+func (m *MultiClipboard) Close() error {
+	var errs []error
+	for _, b := range m.backends {
+		if c, ok := b.Clipboard.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
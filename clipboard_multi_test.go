@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMultiClipboard_FallbackUsesFirstWorkingBackend(t *testing.T) {
+	broken := &mockClipboard{readErr: errors.New("no compositor"), writeErr: errors.New("no compositor")}
+	working := &mockClipboard{content: "x11 content"}
+	unused := &mockClipboard{content: "unused"}
+	cb := NewMultiClipboard(MultiModeFallback,
+		NamedClipboard{"wayland", broken}, NamedClipboard{"x11", working}, NamedClipboard{"tmux", unused})
+
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(content) != "x11 content" {
+		t.Errorf("expected content %q, got %q", "x11 content", content)
+	}
+
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("new")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !working.writeCalled || unused.writeCalled {
+		t.Error("expected only the first working backend to be written")
+	}
+}
+
+func TestMultiClipboard_FallbackFailsWhenAllFail(t *testing.T) {
+	cb := NewMultiClipboard(MultiModeFallback,
+		NamedClipboard{"wayland", &mockClipboard{writeErr: errors.New("no compositor")}},
+		NamedClipboard{"x11", &mockClipboard{writeErr: errors.New("no display")}})
+
+	err := cb.Write(context.Background(), SelectionClipboard, TextContent("content"))
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, want := range []string{"wayland: no compositor", "x11: no display"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err)
+		}
+	}
+}
+
+func TestMultiClipboard_FanOutWritesEveryBackend(t *testing.T) {
+	wayland := &mockClipboard{}
+	x11 := &mockClipboard{writeErr: errors.New("no display")}
+	tmux := &mockClipboard{}
+	cb := NewMultiClipboard(MultiModeFanOut,
+		NamedClipboard{"wayland", wayland}, NamedClipboard{"x11", x11}, NamedClipboard{"tmux", tmux})
+
+	err := cb.Write(context.Background(), SelectionClipboard, TextContent("content"))
+	if err == nil || !strings.Contains(err.Error(), "1 of 3") || !strings.Contains(err.Error(), "x11: no display") {
+		t.Errorf("expected the x11 failure to be reported, got %v", err)
+	}
+	if !wayland.writeCalled || !tmux.writeCalled {
+		t.Error("expected every backend to be written despite the failure")
+	}
+}
+
+func TestSyncToClipboard_FanOutUpdatesStaleBackends(t *testing.T) {
+	current := &mockClipboard{content: "new content"}
+	stale := &mockClipboard{content: "old content"}
+	cb := NewMultiClipboard(MultiModeFanOut, NamedClipboard{"wayland", current}, NamedClipboard{"x11", stale})
+
+	// Reading cb alone would find it up to date
+	if err := SyncToClipboard(context.Background(), cb, SelectionClipboard, TextContent("new content")); err != nil {
+		t.Fatalf("SyncToClipboard failed: %v", err)
+	}
+
+	if current.writeCalled {
+		t.Error("expected the up to date backend not to be written")
+	}
+	if !stale.writeCalled || stale.writeContent != "new content" {
+		t.Errorf("expected the stale backend to be updated, got %q", stale.writeContent)
+	}
+}
+
+func TestSyncToClipboard_FallbackChecksFirstWorkingBackend(t *testing.T) {
+	broken := &mockClipboard{readErr: errors.New("no compositor")}
+	same := &mockClipboard{content: "content"}
+	cb := NewMultiClipboard(MultiModeFallback, NamedClipboard{"wayland", broken}, NamedClipboard{"x11", same})
+
+	if err := SyncToClipboard(context.Background(), cb, SelectionClipboard, TextContent("content")); err != nil {
+		t.Fatalf("SyncToClipboard failed: %v", err)
+	}
+	if broken.writeCalled || same.writeCalled {
+		t.Error("expected no writes for unchanged content")
+	}
+}
+
+func TestClipboardFor_BuildsMultiClipboard(t *testing.T) {
+	settings := DefaultConfig().WatchSettings
+	settings.ClipboardBackend = "fan-out"
+	settings.ClipboardBackends = []string{"wayland", "tmux"}
+	settings.Tmux.Buffer = "clipboard"
+
+	cb, err := clipboardFor(settings)
+	if err != nil {
+		t.Fatalf("clipboardFor failed: %v", err)
+	}
+	multi, ok := cb.(*MultiClipboard)
+	if !ok {
+		t.Fatalf("expected *MultiClipboard, got %T", cb)
+	}
+	if multi.mode != MultiModeFanOut || len(multi.backends) != 2 {
+		t.Fatalf("expected a fan-out over 2 backends, got %s over %d", multi.mode, len(multi.backends))
+	}
+	timeout, ok := multi.backends[1].Clipboard.(*TimeoutClipboard)
+	if !ok {
+		t.Fatalf("expected each backend to have a timeout, got %T", multi.backends[1].Clipboard)
+	}
+	if tmux, ok := timeout.Clipboard.(*TmuxClipboard); !ok || tmux.config.Buffer != "clipboard" {
+		t.Errorf("expected the tmux backend with its settings, got %#v", timeout.Clipboard)
+	}
+
+	for _, backends := range [][]string{nil, {"wayland", "fallback"}, {"wayland", "clipbored"}} {
+		settings.ClipboardBackends = backends
+		if _, err := clipboardFor(settings); err == nil {
+			t.Errorf("%v: expected an error", backends)
+		}
+	}
+}
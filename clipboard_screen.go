@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	screenExchangeTimeout = time.Second
+	screenExchangePoll    = 10 * time.Millisecond
+)
+
+type ScreenConfig struct {
+	// Session is passed to screen -S. Without one, screen uses $STY or the
+	// only running session.
+	Session string `toml:"session"`
+	// Register is a one-character register to use instead of the paste
+	// buffer. Reading it copies it into the paste buffer.
+	Register string `toml:"register"`
+}
+
+// ScreenClipboard keeps the clipboard in the paste buffer or a register of a
+// GNU screen session. Screen only exchanges buffers through files, which
+// live in a private temporary directory, and carries out -X commands after
+// the screen client has already returned, so reads wait for the file to be
+// written. An empty paste buffer writes no file at all, so a read that sees
+// none within screenExchangeTimeout fails with ErrSelectionEmpty.
+type ScreenClipboard struct {
+	config      ScreenConfig
+	execCommand CommandExecutor
+
+	mu  sync.Mutex
+	dir string
+}
+
+func NewScreenClipboard(cfg ScreenConfig) *ScreenClipboard {
+	return &ScreenClipboard{config: cfg}
+}
+
+// command runs screen commands in the session, in order.
+func (s *ScreenClipboard) command(ctx context.Context, commands ...[]string) error {
+	executor := s.execCommand
+	if executor == nil {
+		executor = defaultExec
+	}
+	for _, command := range commands {
+		var args []string
+		if s.config.Session != "" {
+			args = append(args, "-S", s.config.Session)
+		}
+		args = append(args, "-X")
+		if _, err := executor(ctx, "screen", append(args, command...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exchangeFile returns the path of a file in the exchange directory,
+// creating the directory on first use. The caller holds s.mu.
+func (s *ScreenClipboard) exchangeFile(name string) (string, error) {
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "clipboard-txt-watcher-screen-")
+		if err != nil {
+			return "", fmt.Errorf("screen: %w", err)
+		}
+		s.dir = dir
+	}
+	return filepath.Join(s.dir, name), nil
+}
+
+func (s *ScreenClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	if selection != SelectionClipboard {
+		return nil, unsupportedSelection("screen", selection)
+	}
+	if !isTextType(mimeType) {
+		return nil, unsupportedType("screen", mimeType)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.exchangeFile("out")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("screen: %w", err)
+	}
+	var commands [][]string
+	if s.config.Register != "" {
+		commands = append(commands, []string{"paste", s.config.Register, "."})
+	}
+	if err := s.command(ctx, append(commands, []string{"writebuf", path})...); err != nil {
+		return nil, err
+	}
+	return waitForExchangeFile(ctx, path)
+}
+
+// waitForExchangeFile returns the content of a file screen writes. The file
+// is complete once its size stops changing. Screen creates it before
+// writing, so it is empty until then.
+func waitForExchangeFile(ctx context.Context, path string) ([]byte, error) {
+	size := int64(-1)
+	timeout := time.NewTimer(screenExchangeTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(screenExchangePoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			return readExchangeFile(path)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		info, err := os.Stat(path)
+		if err == nil && info.Size() > 0 && info.Size() == size {
+			return readExchangeFile(path)
+		}
+		if err == nil {
+			size = info.Size()
+		}
+	}
+}
+
+// readExchangeFile reads a file screen wrote. An empty paste buffer writes
+// none at all.
+func readExchangeFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("screen: %w", ErrSelectionEmpty)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("screen: %w", err)
+	}
+	return data, nil
+}
+
+func (s *ScreenClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	if selection != SelectionClipboard {
+		return unsupportedSelection("screen", selection)
+	}
+	if !content.IsText() {
+		return unsupportedType("screen", content.Type)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.exchangeFile("in")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content.Data, 0o600); err != nil {
+		return fmt.Errorf("screen: %w", err)
+	}
+	if s.config.Register != "" {
+		return s.command(ctx, []string{"readreg", s.config.Register, path})
+	}
+	return s.command(ctx, []string{"readbuf", path})
+}
+
+// Close removes the exchange directory.
+func (s *ScreenClipboard) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(s.dir)
+	s.dir = ""
+	return err
+}
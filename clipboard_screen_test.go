@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeScreen stands in for a screen session, carrying out the commands it
+// is sent on its paste buffer and registers.
+type fakeScreen struct {
+	t         *testing.T
+	buffer    string
+	registers map[string]string
+	commands  [][]string
+}
+
+func (f *fakeScreen) exec(_ context.Context, cmd string, args ...string) ([]byte, error) {
+	if cmd != "screen" {
+		f.t.Errorf("expected command %q, got %q", "screen", cmd)
+	}
+	f.commands = append(f.commands, args)
+	for len(args) > 0 && args[0] != "-X" {
+		args = args[1:]
+	}
+	switch args[1] {
+	case "readbuf":
+		data, err := os.ReadFile(args[2])
+		if err != nil {
+			return nil, err
+		}
+		f.buffer = string(data)
+	case "readreg":
+		data, err := os.ReadFile(args[3])
+		if err != nil {
+			return nil, err
+		}
+		f.registers[args[2]] = string(data)
+	case "paste":
+		f.buffer = f.registers[args[2]]
+	case "writebuf":
+		// Like screen, leave no file for an empty buffer
+		if f.buffer == "" {
+			return nil, nil
+		}
+		return nil, os.WriteFile(args[2], []byte(f.buffer), 0o600)
+	default:
+		f.t.Errorf("unexpected screen command %v", args)
+	}
+	return nil, nil
+}
+
+func newTestScreenClipboard(t *testing.T, cfg ScreenConfig) (*ScreenClipboard, *fakeScreen) {
+	t.Helper()
+	screen := &fakeScreen{t: t, registers: make(map[string]string)}
+	cb := NewScreenClipboard(cfg)
+	cb.execCommand = screen.exec
+	t.Cleanup(func() { _ = cb.Close() })
+	return cb, screen
+}
+
+func TestNewClipboard_ReturnsScreenWhenSpecified(t *testing.T) {
+	cb, err := NewClipboard("screen")
+	if err != nil {
+		t.Fatalf("NewClipboard failed: %v", err)
+	}
+
+	if _, ok := cb.(*ScreenClipboard); !ok {
+		t.Errorf("expected *ScreenClipboard, got %T", cb)
+	}
+}
+
+func TestScreenClipboard_UsesPasteBuffer(t *testing.T) {
+	cb, screen := newTestScreenClipboard(t, ScreenConfig{})
+
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("screen content")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if screen.buffer != "screen content" {
+		t.Errorf("expected paste buffer %q, got %q", "screen content", screen.buffer)
+	}
+
+	screen.buffer = "copied in screen"
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(content) != "copied in screen" {
+		t.Errorf("expected content %q, got %q", "copied in screen", content)
+	}
+}
+
+func TestScreenClipboard_RegisterAndSession(t *testing.T) {
+	cb, screen := newTestScreenClipboard(t, ScreenConfig{Session: "work", Register: "c"})
+
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("in a register")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if screen.registers["c"] != "in a register" || screen.buffer != "" {
+		t.Errorf("expected only register c to be set, got %v and buffer %q", screen.registers, screen.buffer)
+	}
+
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(content) != "in a register" {
+		t.Errorf("expected content %q, got %q", "in a register", content)
+	}
+	for _, command := range screen.commands {
+		if !reflect.DeepEqual(command[:3], []string{"-S", "work", "-X"}) {
+			t.Errorf("expected commands for session work, got %v", command)
+		}
+	}
+}
+
+func TestScreenClipboard_Close_RemovesExchangeFiles(t *testing.T) {
+	cb, _ := newTestScreenClipboard(t, ScreenConfig{})
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("content")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	dir := cb.dir
+
+	if err := cb.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected %s to be removed, got %v", dir, err)
+	}
+}
+
+func TestScreenClipboard_Read_ReturnsError(t *testing.T) {
+	cb := &ScreenClipboard{
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+			return nil, errors.New("no screen session found")
+		},
+	}
+	defer func() { _ = cb.Close() }()
+
+	if _, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestScreenClipboard_Read_EmptyBuffer(t *testing.T) {
+	cb, _ := newTestScreenClipboard(t, ScreenConfig{})
+
+	_, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if !errors.Is(err, ErrSelectionEmpty) {
+		t.Errorf("expected ErrSelectionEmpty, got %v", err)
+	}
+}
+
+func TestScreenClipboard_Read_WaitsForContent(t *testing.T) {
+	// Screen creates the file, and only writes to it a while later
+	cb := &ScreenClipboard{
+		execCommand: func(_ context.Context, _ string, args ...string) ([]byte, error) {
+			path := args[len(args)-1]
+			if err := os.WriteFile(path, nil, 0o600); err != nil {
+				return nil, err
+			}
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				_ = os.WriteFile(path, []byte("late"), 0o600)
+			}()
+			return nil, nil
+		},
+	}
+	defer func() { _ = cb.Close() }()
+
+	got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "late" {
+		t.Errorf("expected %q, got %q", "late", got)
+	}
+}
+
+func TestScreenClipboard_RejectsPrimary(t *testing.T) {
+	cb := &ScreenClipboard{}
+
+	if _, err := cb.Read(context.Background(), SelectionPrimary, MIMETypeText); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
 )
 
-func TestNewClipboard_ReturnsWaylandByDefault(t *testing.T) {
-	cb := NewClipboard("wayland")
+func TestNewClipboard_ReturnsWaylandWhenSpecified(t *testing.T) {
+	cb, err := NewClipboard("wayland")
+	if err != nil {
+		t.Fatalf("NewClipboard failed: %v", err)
+	}
 
 	_, ok := cb.(*WaylandClipboard)
 	if !ok {
@@ -15,7 +22,10 @@ func TestNewClipboard_ReturnsWaylandByDefault(t *testing.T) {
 }
 
 func TestNewClipboard_ReturnsX11WhenSpecified(t *testing.T) {
-	cb := NewClipboard("x11")
+	cb, err := NewClipboard("x11")
+	if err != nil {
+		t.Fatalf("NewClipboard failed: %v", err)
+	}
 
 	_, ok := cb.(*X11Clipboard)
 	if !ok {
@@ -24,7 +34,10 @@ func TestNewClipboard_ReturnsX11WhenSpecified(t *testing.T) {
 }
 
 func TestNewClipboard_ReturnsDarwinWhenSpecified(t *testing.T) {
-	cb := NewClipboard("darwin")
+	cb, err := NewClipboard("darwin")
+	if err != nil {
+		t.Fatalf("NewClipboard failed: %v", err)
+	}
 
 	_, ok := cb.(*DarwinClipboard)
 	if !ok {
@@ -37,14 +50,14 @@ func TestDarwinClipboard_Read_CallsPbpaste(t *testing.T) {
 	var calledArgs []string
 
 	cb := &DarwinClipboard{
-		execCommand: func(cmd string, args ...string) ([]byte, error) {
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
 			calledCmd = cmd
 			calledArgs = args
 			return []byte("darwin content"), nil
 		},
 	}
 
-	content, err := cb.Read()
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
 	if err != nil {
 		t.Fatalf("Read failed: %v", err)
 	}
@@ -55,7 +68,7 @@ func TestDarwinClipboard_Read_CallsPbpaste(t *testing.T) {
 	if len(calledArgs) != 0 {
 		t.Errorf("expected no args, got %v", calledArgs)
 	}
-	if content != "darwin content" {
+	if string(content) != "darwin content" {
 		t.Errorf("expected content %q, got %q", "darwin content", content)
 	}
 }
@@ -65,14 +78,14 @@ func TestDarwinClipboard_Write_CallsPbcopy(t *testing.T) {
 	var stdinContent string
 
 	cb := &DarwinClipboard{
-		execCommandWithStdin: func(cmd string, stdin string, args ...string) error {
+		execCommandWithStdin: func(_ context.Context, cmd string, stdin string, args ...string) error {
 			calledCmd = cmd
 			stdinContent = stdin
 			return nil
 		},
 	}
 
-	err := cb.Write("darwin test content")
+	err := cb.Write(context.Background(), SelectionClipboard, TextContent("darwin test content"))
 	if err != nil {
 		t.Fatalf("Write failed: %v", err)
 	}
@@ -90,14 +103,14 @@ func TestWaylandClipboard_Read_CallsWlPaste(t *testing.T) {
 	var calledArgs []string
 
 	cb := &WaylandClipboard{
-		execCommand: func(cmd string, args ...string) ([]byte, error) {
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
 			calledCmd = cmd
 			calledArgs = args
 			return []byte("clipboard content"), nil
 		},
 	}
 
-	content, err := cb.Read()
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
 	if err != nil {
 		t.Fatalf("Read failed: %v", err)
 	}
@@ -108,7 +121,7 @@ func TestWaylandClipboard_Read_CallsWlPaste(t *testing.T) {
 	if len(calledArgs) != 1 || calledArgs[0] != "-n" {
 		t.Errorf("expected args %v, got %v", []string{"-n"}, calledArgs)
 	}
-	if content != "clipboard content" {
+	if string(content) != "clipboard content" {
 		t.Errorf("expected content %q, got %q", "clipboard content", content)
 	}
 }
@@ -119,7 +132,7 @@ func TestWaylandClipboard_Write_CallsWlCopy(t *testing.T) {
 	var stdinContent string
 
 	cb := &WaylandClipboard{
-		execCommandWithStdin: func(cmd string, stdin string, args ...string) error {
+		execCommandWithStdin: func(_ context.Context, cmd string, stdin string, args ...string) error {
 			calledCmd = cmd
 			calledArgs = args
 			stdinContent = stdin
@@ -127,7 +140,7 @@ func TestWaylandClipboard_Write_CallsWlCopy(t *testing.T) {
 		},
 	}
 
-	err := cb.Write("test content")
+	err := cb.Write(context.Background(), SelectionClipboard, TextContent("test content"))
 	if err != nil {
 		t.Fatalf("Write failed: %v", err)
 	}
@@ -146,14 +159,14 @@ func TestX11Clipboard_Read_CallsXclip(t *testing.T) {
 	var calledArgs []string
 
 	cb := &X11Clipboard{
-		execCommand: func(cmd string, args ...string) ([]byte, error) {
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
 			calledCmd = cmd
 			calledArgs = args
 			return []byte("x11 content"), nil
 		},
 	}
 
-	content, err := cb.Read()
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
 	if err != nil {
 		t.Fatalf("Read failed: %v", err)
 	}
@@ -171,7 +184,7 @@ func TestX11Clipboard_Read_CallsXclip(t *testing.T) {
 			break
 		}
 	}
-	if content != "x11 content" {
+	if string(content) != "x11 content" {
 		t.Errorf("expected content %q, got %q", "x11 content", content)
 	}
 }
@@ -182,7 +195,7 @@ func TestX11Clipboard_Write_CallsXclip(t *testing.T) {
 	var stdinContent string
 
 	cb := &X11Clipboard{
-		execCommandWithStdin: func(cmd string, stdin string, args ...string) error {
+		execCommandWithStdin: func(_ context.Context, cmd string, stdin string, args ...string) error {
 			calledCmd = cmd
 			calledArgs = args
 			stdinContent = stdin
@@ -190,7 +203,7 @@ func TestX11Clipboard_Write_CallsXclip(t *testing.T) {
 		},
 	}
 
-	err := cb.Write("x11 test content")
+	err := cb.Write(context.Background(), SelectionClipboard, TextContent("x11 test content"))
 	if err != nil {
 		t.Fatalf("Write failed: %v", err)
 	}
@@ -213,23 +226,20 @@ func TestX11Clipboard_Write_CallsXclip(t *testing.T) {
 	}
 }
 
-func TestNewClipboard_ReturnsWaylandForUnknownBackend(t *testing.T) {
-	cb := NewClipboard("unknown")
-
-	_, ok := cb.(*WaylandClipboard)
-	if !ok {
-		t.Errorf("expected *WaylandClipboard for unknown backend, got %T", cb)
+func TestNewClipboard_ReturnsErrorForUnknownBackend(t *testing.T) {
+	if cb, err := NewClipboard("unknown"); err == nil {
+		t.Errorf("expected an error for an unknown backend, got %T", cb)
 	}
 }
 
 func TestWaylandClipboard_Read_ReturnsError(t *testing.T) {
 	cb := &WaylandClipboard{
-		execCommand: func(cmd string, args ...string) ([]byte, error) {
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
 			return nil, errors.New("wl-paste failed")
 		},
 	}
 
-	_, err := cb.Read()
+	_, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -237,12 +247,12 @@ func TestWaylandClipboard_Read_ReturnsError(t *testing.T) {
 
 func TestWaylandClipboard_Write_ReturnsError(t *testing.T) {
 	cb := &WaylandClipboard{
-		execCommandWithStdin: func(cmd string, stdin string, args ...string) error {
+		execCommandWithStdin: func(_ context.Context, cmd string, stdin string, args ...string) error {
 			return errors.New("wl-copy failed")
 		},
 	}
 
-	err := cb.Write("content")
+	err := cb.Write(context.Background(), SelectionClipboard, TextContent("content"))
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -250,12 +260,12 @@ func TestWaylandClipboard_Write_ReturnsError(t *testing.T) {
 
 func TestX11Clipboard_Read_ReturnsError(t *testing.T) {
 	cb := &X11Clipboard{
-		execCommand: func(cmd string, args ...string) ([]byte, error) {
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
 			return nil, errors.New("xclip failed")
 		},
 	}
 
-	_, err := cb.Read()
+	_, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -263,12 +273,12 @@ func TestX11Clipboard_Read_ReturnsError(t *testing.T) {
 
 func TestX11Clipboard_Write_ReturnsError(t *testing.T) {
 	cb := &X11Clipboard{
-		execCommandWithStdin: func(cmd string, stdin string, args ...string) error {
+		execCommandWithStdin: func(_ context.Context, cmd string, stdin string, args ...string) error {
 			return errors.New("xclip failed")
 		},
 	}
 
-	err := cb.Write("content")
+	err := cb.Write(context.Background(), SelectionClipboard, TextContent("content"))
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -276,12 +286,12 @@ func TestX11Clipboard_Write_ReturnsError(t *testing.T) {
 
 func TestDarwinClipboard_Read_ReturnsError(t *testing.T) {
 	cb := &DarwinClipboard{
-		execCommand: func(cmd string, args ...string) ([]byte, error) {
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
 			return nil, errors.New("pbpaste failed")
 		},
 	}
 
-	_, err := cb.Read()
+	_, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
@@ -289,13 +299,242 @@ func TestDarwinClipboard_Read_ReturnsError(t *testing.T) {
 
 func TestDarwinClipboard_Write_ReturnsError(t *testing.T) {
 	cb := &DarwinClipboard{
-		execCommandWithStdin: func(cmd string, stdin string, args ...string) error {
+		execCommandWithStdin: func(_ context.Context, cmd string, stdin string, args ...string) error {
 			return errors.New("pbcopy failed")
 		},
 	}
 
-	err := cb.Write("content")
+	err := cb.Write(context.Background(), SelectionClipboard, TextContent("content"))
 	if err == nil {
 		t.Error("expected error, got nil")
 	}
 }
+
+func TestWaylandClipboard_PrimarySelection(t *testing.T) {
+	var readArgs, writeArgs []string
+
+	cb := &WaylandClipboard{
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+			readArgs = args
+			return []byte("primary content"), nil
+		},
+		execCommandWithStdin: func(_ context.Context, cmd string, stdin string, args ...string) error {
+			writeArgs = args
+			return nil
+		},
+	}
+
+	if _, err := cb.Read(context.Background(), SelectionPrimary, MIMETypeText); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if err := cb.Write(context.Background(), SelectionPrimary, TextContent("content")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if len(readArgs) != 2 || readArgs[0] != "-n" || readArgs[1] != "--primary" {
+		t.Errorf("expected wl-paste args %v, got %v", []string{"-n", "--primary"}, readArgs)
+	}
+	if len(writeArgs) != 1 || writeArgs[0] != "--primary" {
+		t.Errorf("expected wl-copy args %v, got %v", []string{"--primary"}, writeArgs)
+	}
+}
+
+func TestX11Clipboard_PrimarySelection(t *testing.T) {
+	var calledArgs []string
+
+	cb := &X11Clipboard{
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+			calledArgs = args
+			return []byte("primary content"), nil
+		},
+	}
+
+	if _, err := cb.Read(context.Background(), SelectionPrimary, MIMETypeText); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if len(calledArgs) != 3 || calledArgs[1] != "primary" {
+		t.Errorf("expected args %v, got %v", []string{"-selection", "primary", "-o"}, calledArgs)
+	}
+}
+
+func TestClipboards_RejectUnsupportedSelections(t *testing.T) {
+	neverCalled := func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+		t.Errorf("unexpected call to %s", cmd)
+		return nil, nil
+	}
+
+	tests := []struct {
+		name      string
+		cb        Clipboard
+		selection Selection
+	}{
+		{"wayland secondary", &WaylandClipboard{execCommand: neverCalled}, SelectionSecondary},
+		{"darwin primary", &DarwinClipboard{execCommand: neverCalled}, SelectionPrimary},
+		{"x11 both", &X11Clipboard{execCommand: neverCalled}, SelectionBoth},
+	}
+	for _, tt := range tests {
+		if _, err := tt.cb.Read(context.Background(), tt.selection, MIMETypeText); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestClipboards_PassImageTypes(t *testing.T) {
+	image := Content{Type: "image/png", Data: []byte("\x89PNG")}
+	tests := []struct {
+		name      string
+		cb        func(exec CommandExecutor, execWithStdin CommandWithStdinExecutor) Clipboard
+		readArgs  []string
+		writeArgs []string
+	}{
+		{
+			"wayland",
+			func(exec CommandExecutor, execWithStdin CommandWithStdinExecutor) Clipboard {
+				return &WaylandClipboard{execCommand: exec, execCommandWithStdin: execWithStdin}
+			},
+			[]string{"-n", "--type", "image/png"},
+			[]string{"--type", "image/png"},
+		},
+		{
+			"x11",
+			func(exec CommandExecutor, execWithStdin CommandWithStdinExecutor) Clipboard {
+				return &X11Clipboard{execCommand: exec, execCommandWithStdin: execWithStdin}
+			},
+			[]string{"-selection", "clipboard", "-t", "image/png", "-o"},
+			[]string{"-selection", "clipboard", "-t", "image/png"},
+		},
+	}
+	for _, tt := range tests {
+		var readArgs, writeArgs []string
+		var stdin string
+		cb := tt.cb(func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+			readArgs = args
+			return image.Data, nil
+		}, func(_ context.Context, cmd string, in string, args ...string) error {
+			writeArgs, stdin = args, in
+			return nil
+		})
+
+		got, err := cb.Read(context.Background(), SelectionClipboard, image.Type)
+		if err != nil {
+			t.Fatalf("%s: Read failed: %v", tt.name, err)
+		}
+		if err := cb.Write(context.Background(), SelectionClipboard, image); err != nil {
+			t.Fatalf("%s: Write failed: %v", tt.name, err)
+		}
+
+		if !reflect.DeepEqual(readArgs, tt.readArgs) {
+			t.Errorf("%s: expected read args %v, got %v", tt.name, tt.readArgs, readArgs)
+		}
+		if !reflect.DeepEqual(writeArgs, tt.writeArgs) {
+			t.Errorf("%s: expected write args %v, got %v", tt.name, tt.writeArgs, writeArgs)
+		}
+		if string(got) != string(image.Data) || stdin != string(image.Data) {
+			t.Errorf("%s: expected the image to pass through unchanged, got %q and %q", tt.name, got, stdin)
+		}
+	}
+}
+
+func TestDarwinClipboard_ImagesUseOsascript(t *testing.T) {
+	var scripts []string
+	var copied []byte
+	cb := &DarwinClipboard{
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+			if cmd != "osascript" || len(args) != 2 || args[0] != "-e" {
+				t.Fatalf("unexpected command %s %v", cmd, args)
+			}
+			scripts = append(scripts, args[1])
+			if path, ok := strings.CutPrefix(args[1], "set the clipboard to (read (POSIX file \""); ok {
+				path, _, _ = strings.Cut(path, "\"")
+				var err error
+				if copied, err = os.ReadFile(path); err != nil {
+					t.Errorf("reading the image to copy: %v", err)
+				}
+				return nil, nil
+			}
+			return []byte("«data PNGf89504E47»\n"), nil
+		},
+	}
+
+	got, err := cb.Read(context.Background(), SelectionClipboard, "image/png")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "\x89PNG" {
+		t.Errorf("expected %q, got %q", "\x89PNG", got)
+	}
+	if err := cb.Write(context.Background(), SelectionClipboard, Content{Type: "image/png", Data: []byte("\x89PNG")}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if string(copied) != "\x89PNG" {
+		t.Errorf("expected osascript to copy the image, got %q", copied)
+	}
+	for _, script := range scripts {
+		if !strings.Contains(script, "«class PNGf»") {
+			t.Errorf("expected script %q to use the PNG class", script)
+		}
+	}
+}
+
+func TestParseAppleScriptData(t *testing.T) {
+	got, err := parseAppleScriptData([]byte("«data TIFF4D4D002A»\n"), "TIFF")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "MM\x00*" {
+		t.Errorf("expected %q, got %q", "MM\x00*", got)
+	}
+	for _, out := range []string{"", "some text", "«data PNGf89504E47»", "«data TIFF4D4D"} {
+		if _, err := parseAppleScriptData([]byte(out), "TIFF"); err == nil {
+			t.Errorf("%q: expected an error", out)
+		}
+	}
+}
+
+func TestClipboards_RejectUnsupportedTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		cb   Clipboard
+	}{
+		{"darwin", &DarwinClipboard{}},
+		{"tmux", &TmuxClipboard{}},
+		{"screen", &ScreenClipboard{}},
+		{"command", NewCommandClipboard(CommandConfig{Read: []string{"cat"}, Write: []string{"cat"}})},
+	}
+	for _, tt := range tests {
+		if _, err := tt.cb.Read(context.Background(), SelectionClipboard, "image/webp"); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("%s: expected ErrUnsupportedType reading, got %v", tt.name, err)
+		}
+		err := tt.cb.Write(context.Background(), SelectionClipboard, Content{Type: "image/webp", Data: []byte("RIFF")})
+		if !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("%s: expected ErrUnsupportedType writing, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestSelection_Expand(t *testing.T) {
+	tests := []struct {
+		selection Selection
+		want      []Selection
+	}{
+		{SelectionClipboard, []Selection{SelectionClipboard}},
+		{SelectionPrimary, []Selection{SelectionPrimary}},
+		{SelectionSecondary, []Selection{SelectionSecondary}},
+		{SelectionBoth, []Selection{SelectionClipboard, SelectionPrimary}},
+	}
+	for _, tt := range tests {
+		got, err := tt.selection.expand()
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.selection, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.selection, tt.want, got)
+		}
+	}
+
+	if _, err := Selection("selection").expand(); err == nil {
+		t.Error("expected an error for an unknown selection")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	defaultClipboardTimeout = 5 * time.Second
+
+	// commandWaitDelay is how much longer the output of a killed command is
+	// read, in case a process outside its group still holds it open.
+	commandWaitDelay = time.Second
+)
+
+// TimeoutError is returned when a clipboard operation doesn't finish in
+// time. It matches context.DeadlineExceeded with errors.Is.
+type TimeoutError struct {
+	Backend string
+	Op      string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s: %s did not finish within %s", e.Backend, e.Op, e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// operationContext bounds one operation of a backend. A timeout of 0 or
+// less only inherits the deadline of ctx.
+func operationContext(ctx context.Context, backend, op string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, &TimeoutError{Backend: backend, Op: op, Timeout: timeout})
+}
+
+// contextError replaces an error an operation failed with once ctx is done
+// by the reason it is done, so a timeout comes out as a *TimeoutError
+// rather than as a killed process or a closed pipe.
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return context.Cause(ctx)
+}
+
+// TimeoutClipboard gives up on reads and writes of a backend that take
+// longer than Timeout, so a clipboard tool that hangs can't stall a watch.
+type TimeoutClipboard struct {
+	Name    string
+	Timeout time.Duration
+	Clipboard
+}
+
+func NewTimeoutClipboard(name string, timeout time.Duration, cb Clipboard) *TimeoutClipboard {
+	return &TimeoutClipboard{Name: name, Timeout: timeout, Clipboard: cb}
+}
+
+func (t *TimeoutClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	ctx, cancel := operationContext(ctx, t.Name, "read", t.Timeout)
+	defer cancel()
+	data, err := t.Clipboard.Read(ctx, selection, mimeType)
+	return data, contextError(ctx, err)
+}
+
+func (t *TimeoutClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	ctx, cancel := operationContext(ctx, t.Name, "write", t.Timeout)
+	defer cancel()
+	return contextError(ctx, t.Clipboard.Write(ctx, selection, content))
+}
+
+// Hold waits for the backend if it serves what was written itself. That is
+// not bounded by the timeout.
+func (t *TimeoutClipboard) Hold(ctx context.Context) error {
+	if h, ok := t.Clipboard.(Holder); ok {
+		return h.Hold(ctx)
+	}
+	return nil
+}
+
+// Close closes the backend if it keeps connections open.
+func (t *TimeoutClipboard) Close() error {
+	if c, ok := t.Clipboard.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// hangingClipboard blocks until it is given up on, and then fails the way a
+// killed command does.
+type hangingClipboard struct{}
+
+func (hangingClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, errors.New("signal: killed")
+}
+
+func (hangingClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	<-ctx.Done()
+	return errors.New("signal: killed")
+}
+
+func TestTimeoutClipboard_ReturnsTimeoutError(t *testing.T) {
+	cb := NewTimeoutClipboard("wayland", 20*time.Millisecond, hangingClipboard{})
+
+	_, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("expected a *TimeoutError, got %v", err)
+	}
+	if timeout.Backend != "wayland" || timeout.Op != "read" || timeout.Timeout != 20*time.Millisecond {
+		t.Errorf("unexpected timeout error %+v", timeout)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected the error to match context.DeadlineExceeded")
+	}
+
+	err = cb.Write(context.Background(), SelectionClipboard, TextContent("content"))
+	if !errors.As(err, &timeout) || timeout.Op != "write" {
+		t.Errorf("expected a write timeout, got %v", err)
+	}
+}
+
+func TestTimeoutClipboard_KeepsOtherErrors(t *testing.T) {
+	cb := NewTimeoutClipboard("wayland", time.Second, &mockClipboard{readErr: errors.New("no clipboard")})
+
+	_, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	var timeout *TimeoutError
+	if err == nil || errors.As(err, &timeout) {
+		t.Errorf("expected the backend's error, got %v", err)
+	}
+}
+
+func TestTimeoutClipboard_ReportsCancellation(t *testing.T) {
+	cb := NewTimeoutClipboard("wayland", time.Second, hangingClipboard{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cb.Read(ctx, SelectionClipboard, MIMETypeText)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTimeoutClipboard_OuterTimeoutWins(t *testing.T) {
+	inner := NewTimeoutClipboard("command", time.Minute, hangingClipboard{})
+	cb := NewTimeoutClipboard("fallback", 20*time.Millisecond, inner)
+
+	_, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.Backend != "fallback" {
+		t.Errorf("expected the timeout that expired, got %v", err)
+	}
+}
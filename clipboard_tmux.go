@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+)
+
+type TmuxConfig struct {
+	// Buffer is the paste buffer to use. Without one, reads get the most
+	// recent buffer and writes add a new one, like copy mode does.
+	Buffer string `toml:"buffer"`
+}
+
+// TmuxClipboard keeps the clipboard in a tmux paste buffer, for machines
+// that have no other clipboard than the one tmux provides.
+type TmuxClipboard struct {
+	config               TmuxConfig
+	execCommand          CommandExecutor
+	execCommandWithStdin CommandWithStdinExecutor
+}
+
+func NewTmuxClipboard(cfg TmuxConfig) *TmuxClipboard {
+	return &TmuxClipboard{config: cfg}
+}
+
+func (t *TmuxClipboard) bufferArgs(args ...string) []string {
+	if t.config.Buffer != "" {
+		args = append(args, "-b", t.config.Buffer)
+	}
+	return append(args, "-")
+}
+
+func (t *TmuxClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	if selection != SelectionClipboard {
+		return nil, unsupportedSelection("tmux", selection)
+	}
+	if !isTextType(mimeType) {
+		return nil, unsupportedType("tmux", mimeType)
+	}
+	executor := t.execCommand
+	if executor == nil {
+		executor = defaultExec
+	}
+	// A buffer that doesn't exist yet fails with ErrSelectionEmpty
+	return executor(ctx, "tmux", t.bufferArgs("save-buffer")...)
+}
+
+func (t *TmuxClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	if selection != SelectionClipboard {
+		return unsupportedSelection("tmux", selection)
+	}
+	if !content.IsText() {
+		return unsupportedType("tmux", content.Type)
+	}
+	executor := t.execCommandWithStdin
+	if executor == nil {
+		executor = defaultExecWithStdin
+	}
+	return executor(ctx, "tmux", string(content.Data), t.bufferArgs("load-buffer")...)
+}
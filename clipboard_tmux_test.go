@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewClipboard_ReturnsTmuxWhenSpecified(t *testing.T) {
+	cb, err := NewClipboard("tmux")
+	if err != nil {
+		t.Fatalf("NewClipboard failed: %v", err)
+	}
+
+	if _, ok := cb.(*TmuxClipboard); !ok {
+		t.Errorf("expected *TmuxClipboard, got %T", cb)
+	}
+}
+
+func TestTmuxClipboard_Read_CallsSaveBuffer(t *testing.T) {
+	var calledCmd string
+	var calledArgs []string
+
+	cb := &TmuxClipboard{
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+			calledCmd = cmd
+			calledArgs = args
+			return []byte("tmux content"), nil
+		},
+	}
+
+	content, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if calledCmd != "tmux" {
+		t.Errorf("expected command %q, got %q", "tmux", calledCmd)
+	}
+	if want := []string{"save-buffer", "-"}; !reflect.DeepEqual(calledArgs, want) {
+		t.Errorf("expected args %v, got %v", want, calledArgs)
+	}
+	if string(content) != "tmux content" {
+		t.Errorf("expected content %q, got %q", "tmux content", content)
+	}
+}
+
+func TestTmuxClipboard_Write_CallsLoadBuffer(t *testing.T) {
+	var calledArgs []string
+	var stdinContent string
+
+	cb := &TmuxClipboard{
+		execCommandWithStdin: func(_ context.Context, cmd string, stdin string, args ...string) error {
+			calledArgs = args
+			stdinContent = stdin
+			return nil
+		},
+	}
+
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("tmux test content")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if want := []string{"load-buffer", "-"}; !reflect.DeepEqual(calledArgs, want) {
+		t.Errorf("expected args %v, got %v", want, calledArgs)
+	}
+	if stdinContent != "tmux test content" {
+		t.Errorf("expected stdin %q, got %q", "tmux test content", stdinContent)
+	}
+}
+
+func TestTmuxClipboard_NamedBuffer(t *testing.T) {
+	var readArgs, writeArgs []string
+
+	cb := NewTmuxClipboard(TmuxConfig{Buffer: "clipboard"})
+	cb.execCommand = func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+		readArgs = args
+		return nil, nil
+	}
+	cb.execCommandWithStdin = func(_ context.Context, cmd string, stdin string, args ...string) error {
+		writeArgs = args
+		return nil
+	}
+
+	if _, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("content")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if want := []string{"save-buffer", "-b", "clipboard", "-"}; !reflect.DeepEqual(readArgs, want) {
+		t.Errorf("expected args %v, got %v", want, readArgs)
+	}
+	if want := []string{"load-buffer", "-b", "clipboard", "-"}; !reflect.DeepEqual(writeArgs, want) {
+		t.Errorf("expected args %v, got %v", want, writeArgs)
+	}
+}
+
+func TestTmuxClipboard_Read_MissingBuffer(t *testing.T) {
+	cb := &TmuxClipboard{
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+			return nil, &CommandError{Cmd: "tmux", Stderr: "no buffers", ExitCode: 1, Err: errors.New("exit status 1"), reason: ErrSelectionEmpty}
+		},
+	}
+
+	_, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if !errors.Is(err, ErrSelectionEmpty) {
+		t.Errorf("expected ErrSelectionEmpty, got %v", err)
+	}
+}
+
+func TestTmuxClipboard_Read_ReturnsError(t *testing.T) {
+	cb := &TmuxClipboard{
+		execCommand: func(_ context.Context, cmd string, args ...string) ([]byte, error) {
+			return nil, errors.New("no server running")
+		},
+	}
+
+	if _, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+func TestTmuxClipboard_RejectsPrimary(t *testing.T) {
+	cb := &TmuxClipboard{}
+
+	if err := cb.Write(context.Background(), SelectionPrimary, TextContent("content")); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
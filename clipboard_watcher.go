@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultClipboardPollInterval = 500 * time.Millisecond
+
+// ClipboardWatcher polls a clipboard and reports every change in its content.
+// Polling works with every backend, including ones that have no way to
+// subscribe to changes.
+type ClipboardWatcher struct {
+	cb        Clipboard
+	selection Selection
+	mimeType  string
+	interval  time.Duration
+	callback  func(string)
+	onError   func(error)
+
+	// ctx is cancelled by Close, which also stops a read in progress
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	last     string
+	haveLast bool
+	failing  bool
+}
+
+// NewClipboardWatcher starts polling the selection of cb for content of
+// mimeType. The content present at startup is taken as the baseline and not
+// reported. onError is called when reading starts failing, not on every
+// failed poll.
+func NewClipboardWatcher(cb Clipboard, selection Selection, mimeType string, interval time.Duration, callback func(string), onError func(error)) *ClipboardWatcher {
+	if interval <= 0 {
+		interval = defaultClipboardPollInterval
+	}
+
+	w := &ClipboardWatcher{
+		cb:        cb,
+		selection: selection,
+		mimeType:  mimeType,
+		interval:  interval,
+		callback:  callback,
+		onError:   onError,
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.last, w.haveLast = w.read()
+
+	go w.run()
+
+	return w
+}
+
+func (w *ClipboardWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.poll()
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *ClipboardWatcher) poll() {
+	content, ok := w.read()
+	if !ok || (w.haveLast && content == w.last) {
+		return
+	}
+	w.last, w.haveLast = content, true
+	w.callback(content)
+}
+
+func (w *ClipboardWatcher) read() (string, bool) {
+	// An empty clipboard, as after it was cleared, is no content to sync
+	data, err := w.cb.Read(w.ctx, w.selection, w.mimeType)
+	if errors.Is(err, ErrSelectionEmpty) || err == nil && len(data) == 0 {
+		w.failing = false
+		return "", false
+	}
+	if err != nil {
+		if w.ctx.Err() != nil {
+			// Closed while reading
+			return "", false
+		}
+		if !w.failing && w.onError != nil {
+			w.onError(err)
+		}
+		w.failing = true
+		return "", false
+	}
+	w.failing = false
+	return string(data), true
+}
+
+func (w *ClipboardWatcher) Close() error {
+	w.cancel()
+	return nil
+}
+
+// syncGuard breaks the loop in bidirectional sync. It remembers the content
+// last copied from one side to the other, so the change that copy causes on
+// the other side is recognized as an echo instead of being copied back.
+type syncGuard struct {
+	mu   sync.Mutex
+	last string
+	set  bool
+}
+
+func (g *syncGuard) mark(content string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.last, g.set = content, true
+}
+
+func (g *syncGuard) isEcho(content string) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.set && content == g.last
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testClipboardPollInterval = 10 * time.Millisecond
+
+// pollableClipboard lets tests change the clipboard content and read errors
+// while a ClipboardWatcher is polling it.
+type pollableClipboard struct {
+	mu      sync.Mutex
+	content string
+	readErr error
+}
+
+func (p *pollableClipboard) Read(_ context.Context, selection Selection, mimeType string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return []byte(p.content), p.readErr
+}
+
+func (p *pollableClipboard) Write(_ context.Context, selection Selection, content Content) error {
+	p.set(string(content.Data), nil)
+	return nil
+}
+
+func (p *pollableClipboard) set(content string, readErr error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.content, p.readErr = content, readErr
+}
+
+func TestClipboardWatcher_ReportsChanges(t *testing.T) {
+	cb := &pollableClipboard{content: "initial"}
+
+	called := make(chan string, 10)
+	w := NewClipboardWatcher(cb, SelectionClipboard, MIMETypeText, testClipboardPollInterval, func(content string) {
+		called <- content
+	}, nil)
+	defer func() { _ = w.Close() }()
+
+	select {
+	case content := <-called:
+		t.Fatalf("expected the startup content not to be reported, got %q", content)
+	case <-time.After(5 * testClipboardPollInterval):
+	}
+
+	cb.set("copied", nil)
+	waitForContent(t, called, "copied")
+}
+
+func TestClipboardWatcher_ReportsErrorOncePerOutage(t *testing.T) {
+	cb := &pollableClipboard{content: "initial"}
+
+	errs := make(chan error, 10)
+	called := make(chan string, 10)
+	w := NewClipboardWatcher(cb, SelectionClipboard, MIMETypeText, testClipboardPollInterval, func(content string) {
+		called <- content
+	}, func(err error) {
+		errs <- err
+	})
+	defer func() { _ = w.Close() }()
+
+	cb.set("", errors.New("compositor gone"))
+	select {
+	case <-errs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the read error to be reported")
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("expected a single error report, got another: %v", err)
+	case <-time.After(5 * testClipboardPollInterval):
+	}
+
+	cb.set("after outage", nil)
+	waitForContent(t, called, "after outage")
+}
+
+func TestClipboardWatcher_Close_StopsPolling(t *testing.T) {
+	cb := &pollableClipboard{content: "initial"}
+
+	called := make(chan string, 10)
+	w := NewClipboardWatcher(cb, SelectionClipboard, MIMETypeText, testClipboardPollInterval, func(content string) {
+		called <- content
+	}, nil)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	cb.set("after close", nil)
+	select {
+	case content := <-called:
+		t.Errorf("callback was called after Close with %q", content)
+	case <-time.After(5 * testClipboardPollInterval):
+	}
+}
+
+func TestSyncGuard_RecognizesEcho(t *testing.T) {
+	g := &syncGuard{}
+	if g.isEcho("content") {
+		t.Error("expected nothing to be an echo before anything was synced")
+	}
+
+	g.mark("content")
+	if !g.isEcho("content") {
+		t.Error("expected the synced content to be an echo")
+	}
+	if g.isEcho("other") {
+		t.Error("expected different content not to be an echo")
+	}
+}
+
+func TestSyncGuard_NilLetsEverythingThrough(t *testing.T) {
+	var g *syncGuard
+	g.mark("content")
+	if g.isEcho("content") {
+		t.Error("expected a nil guard never to report an echo")
+	}
+}
@@ -0,0 +1,635 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Interfaces and opcodes of the parts of the Wayland core protocol and of the
+// data-control protocols that the native clipboard uses. ext-data-control-v1
+// and wlr-data-control-unstable-v1 are identical on the wire apart from their
+// interface names.
+const (
+	wlSeatInterface            = "wl_seat"
+	extDataControlManagerIface = "ext_data_control_manager_v1"
+	wlrDataControlManagerIface = "zwlr_data_control_manager_v1"
+
+	wlDisplayID = 1
+)
+
+// Requests
+const (
+	wlDisplaySync           = 0
+	wlDisplayGetRegistry    = 1
+	wlRegistryBind          = 0
+	dataControlCreateSource = 0
+	dataControlGetDevice    = 1
+	dataDeviceSetSelection  = 0
+	dataDeviceSetPrimary    = 2
+	dataSourceOffer         = 0
+	dataSourceDestroy       = 1
+	dataOfferReceive        = 0
+	dataOfferDestroy        = 1
+)
+
+// Events
+const (
+	wlDisplayEventError      = 0
+	wlDisplayEventDeleteID   = 1
+	wlRegistryEventGlobal    = 0
+	wlCallbackEventDone      = 0
+	dataDeviceEventDataOffer = 0
+	dataDeviceEventSelection = 1
+	dataDeviceEventFinished  = 2
+	dataDeviceEventPrimary   = 3
+	dataSourceEventSend      = 0
+	dataSourceEventCancelled = 1
+	dataOfferEventOffer      = 0
+)
+
+// textMIMETypes are offered when writing text and looked for, in order, when
+// reading it.
+var textMIMETypes = []string{
+	"text/plain;charset=utf-8",
+	"text/plain",
+	"UTF8_STRING",
+	"STRING",
+	"TEXT",
+}
+
+// WaylandNativeClipboard talks to the compositor directly through the
+// ext-data-control or wlr-data-control protocol instead of running wl-paste
+// and wl-copy. It keeps one connection open, which also serves the clipboard
+// contents to other clients after a Write, and reconnects if it is lost.
+type WaylandNativeClipboard struct {
+	mu     sync.Mutex
+	client *wlClient
+}
+
+func (w *WaylandNativeClipboard) connect(ctx context.Context) (*wlClient, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.client != nil && !w.client.closed() {
+		return w.client, nil
+	}
+	client, err := dialWayland(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w.client = client
+	return client, nil
+}
+
+func (w *WaylandNativeClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	client, err := w.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.read(ctx, selection, mimeType)
+}
+
+func (w *WaylandNativeClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	client, err := w.connect(ctx)
+	if err != nil {
+		return err
+	}
+	return client.write(ctx, selection, content)
+}
+
+// Hold waits until everything written was replaced by another client.
+func (w *WaylandNativeClipboard) Hold(ctx context.Context) error {
+	w.mu.Lock()
+	client := w.client
+	w.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	return client.hold(ctx)
+}
+
+// Close drops the connection. Anything written is no longer served to other
+// clients afterwards.
+func (w *WaylandNativeClipboard) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.client == nil {
+		return nil
+	}
+	err := w.client.close()
+	w.client = nil
+	return err
+}
+
+type wlObjectKind int
+
+const (
+	wlDisplayObject wlObjectKind = iota
+	wlRegistryObject
+	wlCallbackObject
+	wlSeatObject
+	dataControlManagerObject
+	dataDeviceObject
+	dataSourceObject
+	dataOfferObject
+)
+
+type wlGlobal struct {
+	name    uint32
+	iface   string
+	version uint32
+}
+
+// wlClient is one connection to the compositor. Events are dispatched by a
+// goroutine of its own, so that it can hand out the clipboard contents while
+// a Read or Write is waiting.
+type wlClient struct {
+	wire *wlConn
+
+	// mu guards everything below, and also serializes requests so that new
+	// object ids reach the compositor in the order they were allocated.
+	mu        sync.Mutex
+	nextID    uint32
+	objects   map[uint32]wlObjectKind
+	callbacks map[uint32]chan struct{}
+	globals   []wlGlobal
+	manager   uint32
+	device    uint32
+	offers    map[uint32][]string
+	sources   map[uint32]Content
+	// released is closed, and replaced, whenever a source is cancelled.
+	released chan struct{}
+
+	// The offers currently holding the clipboard and PRIMARY, and whether
+	// the compositor supports PRIMARY at all.
+	selection  uint32
+	primary    uint32
+	hasPrimary bool
+
+	done chan struct{}
+	err  error
+}
+
+func waylandSocketPath() (string, error) {
+	display := os.Getenv("WAYLAND_DISPLAY")
+	if display == "" {
+		display = "wayland-0"
+	}
+	if filepath.IsAbs(display) {
+		return display, nil
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return "", fmt.Errorf("wayland: %w: XDG_RUNTIME_DIR is not set", ErrNoDisplay)
+	}
+	return filepath.Join(runtimeDir, display), nil
+}
+
+func dialWayland(ctx context.Context) (*wlClient, error) {
+	path, err := waylandSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("wayland: %w: %w", ErrNoDisplay, err)
+	}
+
+	c := &wlClient{
+		wire:      newWLConn(conn),
+		nextID:    wlDisplayID + 1,
+		objects:   map[uint32]wlObjectKind{wlDisplayID: wlDisplayObject},
+		callbacks: make(map[uint32]chan struct{}),
+		offers:    make(map[uint32][]string),
+		sources:   make(map[uint32]Content),
+		released:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go c.run()
+
+	if err := c.setup(ctx); err != nil {
+		_ = c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// setup binds a seat and a data-control manager, and gets the seat's data
+// device. Once it returns, the current selection is known.
+func (c *wlClient) setup(ctx context.Context) error {
+	c.mu.Lock()
+	registry := c.newID(wlRegistryObject)
+	err := c.request(wlDisplayID, wlDisplayGetRegistry, registry)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// The first roundtrip collects the globals, the second the selection
+	if err := c.roundtrip(ctx); err != nil {
+		return err
+	}
+	if err := c.getDevice(registry); err != nil {
+		return err
+	}
+	return c.roundtrip(ctx)
+}
+
+func (c *wlClient) getDevice(registry uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// ext-data-control has PRIMARY from the start, wlr-data-control since
+	// version 2.
+	manager, managerIface, version := c.global(extDataControlManagerIface), extDataControlManagerIface, uint32(1)
+	if manager == nil {
+		manager, managerIface = c.global(wlrDataControlManagerIface), wlrDataControlManagerIface
+		if manager != nil {
+			version = min(manager.version, 2)
+		}
+	}
+	if manager == nil {
+		return errors.New("wayland: the compositor supports neither ext-data-control nor wlr-data-control")
+	}
+	c.hasPrimary = managerIface == extDataControlManagerIface || version >= 2
+	seat := c.global(wlSeatInterface)
+	if seat == nil {
+		return errors.New("wayland: the compositor has no seat")
+	}
+
+	seatID := c.newID(wlSeatObject)
+	if err := c.request(registry, wlRegistryBind, seat.name, wlSeatInterface, uint32(1), seatID); err != nil {
+		return err
+	}
+	c.manager = c.newID(dataControlManagerObject)
+	if err := c.request(registry, wlRegistryBind, manager.name, managerIface, version, c.manager); err != nil {
+		return err
+	}
+	c.device = c.newID(dataDeviceObject)
+	return c.request(c.manager, dataControlGetDevice, c.device, seatID)
+}
+
+func (c *wlClient) global(iface string) *wlGlobal {
+	for i := range c.globals {
+		if c.globals[i].iface == iface {
+			return &c.globals[i]
+		}
+	}
+	return nil
+}
+
+// newID allocates the id of a new client-side object. The caller must hold
+// c.mu and send the request that creates the object before releasing it.
+func (c *wlClient) newID(kind wlObjectKind) uint32 {
+	id := c.nextID
+	c.nextID++
+	c.objects[id] = kind
+	return id
+}
+
+func (c *wlClient) request(object uint32, opcode uint16, args ...any) error {
+	if err := c.wire.send(object, opcode, args...); err != nil {
+		c.fail(fmt.Errorf("wayland: %w", err))
+		return c.err
+	}
+	return nil
+}
+
+// roundtrip waits until the compositor has handled every request sent so far
+// and all events it sent in response have been dispatched.
+func (c *wlClient) roundtrip(ctx context.Context) error {
+	c.mu.Lock()
+	callback := c.newID(wlCallbackObject)
+	done := make(chan struct{})
+	c.callbacks[callback] = done
+	err := c.request(wlDisplayID, wlDisplaySync, callback)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// selectionRequest returns the request that sets the selection, checking
+// that the compositor supports it. The caller holds c.mu.
+func (c *wlClient) selectionRequest(selection Selection) (uint16, error) {
+	switch {
+	case selection == SelectionClipboard:
+		return dataDeviceSetSelection, nil
+	case selection == SelectionPrimary && c.hasPrimary:
+		return dataDeviceSetPrimary, nil
+	default:
+		return 0, unsupportedSelection("wayland-native", selection)
+	}
+}
+
+func (c *wlClient) read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	if err := c.roundtrip(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if _, err := c.selectionRequest(selection); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	offer := c.selection
+	if selection == SelectionPrimary {
+		offer = c.primary
+	}
+	if offer == 0 {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("wayland: %w", ErrSelectionEmpty)
+	}
+	receiveType := pickMIMEType(c.offers[offer], mimeType)
+	if receiveType == "" {
+		c.mu.Unlock()
+		if isTextType(mimeType) {
+			return nil, errors.New("wayland: the clipboard holds no text")
+		}
+		return nil, fmt.Errorf("wayland: the clipboard holds no %s", mimeType)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	err = c.request(offer, dataOfferReceive, receiveType, w)
+	c.mu.Unlock()
+
+	// The compositor has its own copy of the write end now, and the pipe
+	// only reaches EOF once that is closed too.
+	_ = w.Close()
+	defer func() { _ = r.Close() }()
+	if err != nil {
+		return nil, err
+	}
+	// A client that never finishes sending is given up on with the context
+	stop := context.AfterFunc(ctx, func() { _ = r.SetReadDeadline(time.Now()) })
+	defer stop()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// pickMIMEType returns which of the offered types to receive content of
+// mimeType as: the best text type for text, or else that very type.
+func pickMIMEType(offered []string, mimeType string) string {
+	if isTextType(mimeType) {
+		return pickTextMIMEType(offered)
+	}
+	for _, offeredType := range offered {
+		if offeredType == mimeType {
+			return offeredType
+		}
+	}
+	return ""
+}
+
+func pickTextMIMEType(offered []string) string {
+	for _, want := range textMIMETypes {
+		for _, mimeType := range offered {
+			if mimeType == want {
+				return mimeType
+			}
+		}
+	}
+	return ""
+}
+
+// offerMIMETypes returns the types one representation is offered as.
+func offerMIMETypes(content Content) []string {
+	if content.IsText() {
+		return textMIMETypes
+	}
+	return []string{content.Type}
+}
+
+// sourceData returns the representation of content that a client asked for
+// as mimeType.
+func sourceData(content Content, mimeType string) []byte {
+	for _, representation := range content.representations() {
+		if slices.Contains(offerMIMETypes(representation), mimeType) {
+			return representation.Data
+		}
+	}
+	return content.Data
+}
+
+func (c *wlClient) write(ctx context.Context, selection Selection, content Content) error {
+	c.mu.Lock()
+	setSelection, err := c.selectionRequest(selection)
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	source := c.newID(dataSourceObject)
+	err = c.request(c.manager, dataControlCreateSource, source)
+	for _, representation := range content.representations() {
+		for _, mimeType := range offerMIMETypes(representation) {
+			if err == nil {
+				err = c.request(source, dataSourceOffer, mimeType)
+			}
+		}
+	}
+	if err == nil {
+		c.sources[source] = content
+		err = c.request(c.device, setSelection, source)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return c.roundtrip(ctx)
+}
+
+// hold waits until no source is left to serve.
+func (c *wlClient) hold(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		held, released := len(c.sources), c.released
+		c.mu.Unlock()
+		if held == 0 {
+			return nil
+		}
+		select {
+		case <-released:
+		case <-c.done:
+			return c.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *wlClient) run() {
+	defer func() { _ = c.wire.Close() }()
+
+	for {
+		m, err := c.wire.next()
+		c.mu.Lock()
+		if err != nil {
+			c.fail(fmt.Errorf("wayland: connection lost: %w", err))
+		} else {
+			c.dispatch(m)
+			if m.err != nil {
+				c.fail(m.err)
+			}
+		}
+		closed := c.closed()
+		c.mu.Unlock()
+		if closed {
+			return
+		}
+	}
+}
+
+// dispatch handles one event, by the interface of the object it is for.
+// The caller holds c.mu.
+func (c *wlClient) dispatch(m *wlMessage) {
+	kind, ok := c.objects[m.object]
+	if !ok {
+		return
+	}
+
+	switch kind {
+	case wlDisplayObject:
+		c.displayEvent(m)
+	case wlRegistryObject:
+		if m.opcode == wlRegistryEventGlobal {
+			c.globals = append(c.globals, wlGlobal{name: m.uint(), iface: m.string(), version: m.uint()})
+		}
+	case wlCallbackObject:
+		c.callbackEvent(m)
+	case dataDeviceObject:
+		c.deviceEvent(m)
+	case dataOfferObject:
+		if m.opcode == dataOfferEventOffer {
+			c.offers[m.object] = append(c.offers[m.object], m.string())
+		}
+	case dataSourceObject:
+		c.sourceEvent(m)
+	case wlSeatObject, dataControlManagerObject:
+		// Nothing they send is of use
+	}
+}
+
+func (c *wlClient) displayEvent(m *wlMessage) {
+	switch m.opcode {
+	case wlDisplayEventError:
+		object, code, message := m.uint(), m.uint(), m.string()
+		c.fail(fmt.Errorf("wayland: protocol error %d on object %d: %s", code, object, message))
+	case wlDisplayEventDeleteID:
+		delete(c.objects, m.uint())
+	}
+}
+
+func (c *wlClient) callbackEvent(m *wlMessage) {
+	if m.opcode != wlCallbackEventDone {
+		return
+	}
+	if done, ok := c.callbacks[m.object]; ok {
+		close(done)
+		delete(c.callbacks, m.object)
+	}
+}
+
+func (c *wlClient) deviceEvent(m *wlMessage) {
+	switch m.opcode {
+	case dataDeviceEventDataOffer:
+		offer := m.uint()
+		c.objects[offer] = dataOfferObject
+		c.offers[offer] = nil
+	case dataDeviceEventSelection:
+		c.replaceOffer(&c.selection, m.uint())
+	case dataDeviceEventPrimary:
+		c.replaceOffer(&c.primary, m.uint())
+	case dataDeviceEventFinished:
+		c.fail(errors.New("wayland: the data control device is no longer valid"))
+	}
+}
+
+func (c *wlClient) sourceEvent(m *wlMessage) {
+	switch m.opcode {
+	case dataSourceEventSend:
+		mimeType := m.string()
+		if f := m.file(); f != nil {
+			go serveContent(f, sourceData(c.sources[m.object], mimeType))
+		}
+	case dataSourceEventCancelled:
+		// Someone else owns the clipboard now
+		delete(c.sources, m.object)
+		_ = c.request(m.object, dataSourceDestroy)
+		close(c.released)
+		c.released = make(chan struct{})
+	}
+}
+
+// replaceOffer makes offer the one holding a selection, destroying the
+// previous one.
+func (c *wlClient) replaceOffer(current *uint32, offer uint32) {
+	if *current != 0 && *current != offer {
+		delete(c.offers, *current)
+		_ = c.request(*current, dataOfferDestroy)
+	}
+	*current = offer
+}
+
+// serveContent hands the clipboard contents to a client that pasted them.
+func serveContent(f *os.File, data []byte) {
+	_, _ = f.Write(data)
+	_ = f.Close()
+}
+
+// fail shuts the connection down after an unrecoverable error. The caller
+// holds c.mu.
+func (c *wlClient) fail(err error) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	c.err = err
+	close(c.done)
+	_ = c.wire.conn.Close()
+}
+
+func (c *wlClient) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *wlClient) close() error {
+	c.mu.Lock()
+	c.fail(net.ErrClosed)
+	c.mu.Unlock()
+	return nil
+}
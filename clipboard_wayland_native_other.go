@@ -0,0 +1,25 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+var errWaylandNativeUnsupported = errors.New("wayland: the native backend needs a unix system")
+
+// WaylandNativeClipboard is only available on unix systems.
+type WaylandNativeClipboard struct{}
+
+func (w *WaylandNativeClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	return nil, errWaylandNativeUnsupported
+}
+
+func (w *WaylandNativeClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	return errWaylandNativeUnsupported
+}
+
+func (w *WaylandNativeClipboard) Close() error {
+	return nil
+}
@@ -0,0 +1,583 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSelection is what the fake compositor's clipboard holds: either a
+// source owned by one of its clients, or content set by the test itself.
+type fakeSelection struct {
+	mimeTypes []string
+	owner     *fakeWaylandClient
+	source    uint32
+	content   string
+}
+
+// fakeCompositor speaks just enough of the Wayland protocol to serve the
+// data-control clipboard and primary selection to the clients that connect
+// to it.
+type fakeCompositor struct {
+	t        *testing.T
+	listener *net.UnixListener
+	globals  []string
+
+	mu          sync.Mutex
+	versions    map[string]uint32
+	clients     []*fakeWaylandClient
+	selection   *fakeSelection
+	primary     *fakeSelection
+	connections int
+}
+
+type fakeWaylandClient struct {
+	conn    *wlConn
+	objects map[uint32]string
+	sources map[uint32][]string
+	offers  map[uint32]*fakeSelection
+	devices []uint32
+	nextID  uint32
+	// hasPrimary is set once the client binds a data-control manager
+	// version with the primary selection.
+	hasPrimary bool
+}
+
+// newFakeCompositor listens on a socket in a temp dir and points
+// WAYLAND_DISPLAY at it. globals are the interfaces it advertises, at
+// version 1 unless versions says otherwise.
+func newFakeCompositor(t *testing.T, globals ...string) *fakeCompositor {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wayland-0")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WAYLAND_DISPLAY", path)
+
+	f := &fakeCompositor{
+		t:        t,
+		listener: listener,
+		globals:  globals,
+		versions: map[string]uint32{wlrDataControlManagerIface: 2},
+	}
+	go f.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+		f.dropClients()
+	})
+	return f
+}
+
+func (f *fakeCompositor) serve() {
+	for {
+		conn, err := f.listener.AcceptUnix()
+		if err != nil {
+			return
+		}
+		client := &fakeWaylandClient{
+			conn:    newWLConn(conn),
+			objects: map[uint32]string{wlDisplayID: "wl_display"},
+			sources: make(map[uint32][]string),
+			offers:  make(map[uint32]*fakeSelection),
+			nextID:  0xff000000,
+		}
+		f.mu.Lock()
+		f.clients = append(f.clients, client)
+		f.connections++
+		f.mu.Unlock()
+		go f.handle(client)
+	}
+}
+
+func (f *fakeCompositor) handle(client *fakeWaylandClient) {
+	for {
+		m, err := client.conn.next()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.request(client, m)
+		f.mu.Unlock()
+	}
+}
+
+// request handles one request, by the interface of the object it is for.
+func (f *fakeCompositor) request(client *fakeWaylandClient, m *wlMessage) {
+	iface := client.objects[m.object]
+	switch {
+	case iface == "wl_display":
+		f.displayRequest(client, m)
+	case iface == "wl_registry" && m.opcode == wlRegistryBind:
+		_ = m.uint()
+		bound := m.string()
+		version := m.uint()
+		client.objects[m.uint()] = bound
+		if bound == extDataControlManagerIface || (bound == wlrDataControlManagerIface && version >= 2) {
+			client.hasPrimary = true
+		}
+	case strings.HasSuffix(iface, "data_control_manager_v1"):
+		f.managerRequest(client, m)
+	case iface == "source":
+		f.sourceRequest(client, m)
+	case iface == "device":
+		f.deviceRequest(client, m)
+	case iface == "offer":
+		f.offerRequest(client, m)
+	}
+	if m.err != nil {
+		f.t.Errorf("fake compositor: malformed request %d on %s", m.opcode, iface)
+	}
+}
+
+func (f *fakeCompositor) displayRequest(client *fakeWaylandClient, m *wlMessage) {
+	switch m.opcode {
+	case wlDisplaySync:
+		callback := m.uint()
+		f.send(client, callback, wlCallbackEventDone, uint32(0))
+		f.send(client, wlDisplayID, wlDisplayEventDeleteID, callback)
+	case wlDisplayGetRegistry:
+		registry := m.uint()
+		client.objects[registry] = "wl_registry"
+		for i, global := range f.globals {
+			version := max(f.versions[global], 1)
+			f.send(client, registry, wlRegistryEventGlobal, uint32(i+1), global, version)
+		}
+	}
+}
+
+func (f *fakeCompositor) managerRequest(client *fakeWaylandClient, m *wlMessage) {
+	switch m.opcode {
+	case dataControlCreateSource:
+		client.objects[m.uint()] = "source"
+	case dataControlGetDevice:
+		device := m.uint()
+		client.objects[device] = "device"
+		client.devices = append(client.devices, device)
+		f.announce(client, device)
+	}
+}
+
+func (f *fakeCompositor) sourceRequest(client *fakeWaylandClient, m *wlMessage) {
+	switch m.opcode {
+	case dataSourceOffer:
+		client.sources[m.object] = append(client.sources[m.object], m.string())
+	case dataSourceDestroy:
+		delete(client.sources, m.object)
+	}
+}
+
+func (f *fakeCompositor) deviceRequest(client *fakeWaylandClient, m *wlMessage) {
+	switch m.opcode {
+	case dataDeviceSetSelection:
+		source := m.uint()
+		f.setSelection(&f.selection, &fakeSelection{mimeTypes: client.sources[source], owner: client, source: source})
+	case dataDeviceSetPrimary:
+		if !client.hasPrimary {
+			f.t.Errorf("fake compositor: set_primary_selection on a manager version without it")
+		}
+		source := m.uint()
+		f.setSelection(&f.primary, &fakeSelection{mimeTypes: client.sources[source], owner: client, source: source})
+	}
+}
+
+func (f *fakeCompositor) offerRequest(client *fakeWaylandClient, m *wlMessage) {
+	switch m.opcode {
+	case dataOfferReceive:
+		mimeType, fd := m.string(), m.file()
+		selection := client.offers[m.object]
+		if selection.owner != nil {
+			f.send(selection.owner, selection.source, dataSourceEventSend, mimeType, fd)
+		} else {
+			_, _ = io.WriteString(fd, selection.content)
+		}
+		_ = fd.Close()
+	case dataOfferDestroy:
+		delete(client.offers, m.object)
+	}
+}
+
+func (f *fakeCompositor) send(client *fakeWaylandClient, object uint32, opcode uint16, args ...any) {
+	// Clients that went away are not the test's concern
+	_ = client.conn.send(object, opcode, args...)
+}
+
+// setSelection replaces the clipboard or primary selection held in slot and
+// tells every client about it. The caller holds f.mu.
+func (f *fakeCompositor) setSelection(slot **fakeSelection, selection *fakeSelection) {
+	if old := *slot; old != nil && old.owner != nil && old.source != selection.source {
+		f.send(old.owner, old.source, dataSourceEventCancelled)
+	}
+	*slot = selection
+	for _, client := range f.clients {
+		for _, device := range client.devices {
+			f.announce(client, device)
+		}
+	}
+}
+
+// announce sends the current selections to a data device.
+func (f *fakeCompositor) announce(client *fakeWaylandClient, device uint32) {
+	f.announceSelection(client, device, f.selection, dataDeviceEventSelection)
+	if client.hasPrimary {
+		f.announceSelection(client, device, f.primary, dataDeviceEventPrimary)
+	}
+}
+
+func (f *fakeCompositor) announceSelection(client *fakeWaylandClient, device uint32, selection *fakeSelection, event uint16) {
+	if selection == nil {
+		f.send(client, device, event, uint32(0))
+		return
+	}
+	offer := client.nextID
+	client.nextID++
+	client.objects[offer] = "offer"
+	client.offers[offer] = selection
+	f.send(client, device, dataDeviceEventDataOffer, offer)
+	for _, mimeType := range selection.mimeTypes {
+		f.send(client, offer, dataOfferEventOffer, mimeType)
+	}
+	f.send(client, device, event, offer)
+}
+
+// copy simulates another application copying content.
+func (f *fakeCompositor) copy(content string, mimeTypes ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setSelection(&f.selection, &fakeSelection{mimeTypes: mimeTypes, content: content})
+}
+
+// selectText simulates another application selecting text.
+func (f *fakeCompositor) selectText(content string, mimeTypes ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setSelection(&f.primary, &fakeSelection{mimeTypes: mimeTypes, content: content})
+}
+
+// advertise changes the version a global is advertised at.
+func (f *fakeCompositor) advertise(global string, version uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.versions[global] = version
+}
+
+func (f *fakeCompositor) dropClients() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, client := range f.clients {
+		_ = client.conn.conn.Close()
+	}
+	f.clients = nil
+}
+
+func (f *fakeCompositor) connectionCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connections
+}
+
+func newTestNativeClipboard(t *testing.T) *WaylandNativeClipboard {
+	t.Helper()
+	cb := &WaylandNativeClipboard{}
+	t.Cleanup(func() { _ = cb.Close() })
+	return cb
+}
+
+func TestNewClipboard_ReturnsWaylandNativeWhenSpecified(t *testing.T) {
+	cb, err := NewClipboard("wayland-native")
+	if err != nil {
+		t.Fatalf("NewClipboard failed: %v", err)
+	}
+
+	if _, ok := cb.(*WaylandNativeClipboard); !ok {
+		t.Errorf("expected *WaylandNativeClipboard, got %T", cb)
+	}
+}
+
+func TestWaylandNativeClipboard_ReadsWhatAnotherClientCopied(t *testing.T) {
+	for _, manager := range []string{extDataControlManagerIface, wlrDataControlManagerIface} {
+		t.Run(manager, func(t *testing.T) {
+			f := newFakeCompositor(t, wlSeatInterface, manager)
+			f.copy("from another app", "image/png", "text/plain")
+
+			got, err := newTestNativeClipboard(t).Read(context.Background(), SelectionClipboard, MIMETypeText)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if string(got) != "from another app" {
+				t.Errorf("expected %q, got %q", "from another app", got)
+			}
+		})
+	}
+}
+
+func TestWaylandNativeClipboard_WriteServesOtherClients(t *testing.T) {
+	newFakeCompositor(t, wlSeatInterface, extDataControlManagerIface)
+
+	writer := newTestNativeClipboard(t)
+	if err := writer.Write(context.Background(), SelectionClipboard, TextContent("hello\nworld")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Reading through the same connection has the writer serve itself
+	for _, cb := range []*WaylandNativeClipboard{writer, newTestNativeClipboard(t)} {
+		got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if string(got) != "hello\nworld" {
+			t.Errorf("expected %q, got %q", "hello\nworld", got)
+		}
+	}
+}
+
+func TestWaylandNativeClipboard_SeesNewSelectionAfterWrite(t *testing.T) {
+	f := newFakeCompositor(t, wlSeatInterface, extDataControlManagerIface)
+
+	cb := newTestNativeClipboard(t)
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("mine")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	f.copy("theirs", "text/plain;charset=utf-8")
+
+	got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "theirs" {
+		t.Errorf("expected %q, got %q", "theirs", got)
+	}
+}
+
+func TestWaylandNativeClipboard_HoldsUntilReplaced(t *testing.T) {
+	f := newFakeCompositor(t, wlSeatInterface, extDataControlManagerIface)
+
+	cb := newTestNativeClipboard(t)
+	if err := cb.Hold(context.Background()); err != nil {
+		t.Errorf("expected Hold to return at once before a write, got %v", err)
+	}
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("mine")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := cb.Hold(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected Hold to wait while the clipboard is held, got %v", err)
+	}
+
+	held := make(chan error, 1)
+	go func() { held <- cb.Hold(context.Background()) }()
+	f.copy("theirs", "text/plain")
+	select {
+	case err := <-held:
+		if err != nil {
+			t.Errorf("Hold failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Hold to return once another client copied")
+	}
+}
+
+func TestWaylandNativeClipboard_PrimarySelection(t *testing.T) {
+	for _, manager := range []string{extDataControlManagerIface, wlrDataControlManagerIface} {
+		t.Run(manager, func(t *testing.T) {
+			f := newFakeCompositor(t, wlSeatInterface, manager)
+			f.copy("copied", "text/plain")
+			f.selectText("selected", "text/plain")
+
+			cb := newTestNativeClipboard(t)
+			got, err := cb.Read(context.Background(), SelectionPrimary, MIMETypeText)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if string(got) != "selected" {
+				t.Errorf("expected %q, got %q", "selected", got)
+			}
+
+			if err := cb.Write(context.Background(), SelectionPrimary, TextContent("written")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			for selection, want := range map[Selection]string{SelectionPrimary: "written", SelectionClipboard: "copied"} {
+				got, err := newTestNativeClipboard(t).Read(context.Background(), selection, MIMETypeText)
+				if err != nil {
+					t.Fatalf("Read(%s) failed: %v", selection, err)
+				}
+				if string(got) != want {
+					t.Errorf("Read(%s): expected %q, got %q", selection, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWaylandNativeClipboard_PrimaryNeedsWlrVersion2(t *testing.T) {
+	f := newFakeCompositor(t, wlSeatInterface, wlrDataControlManagerIface)
+	f.advertise(wlrDataControlManagerIface, 1)
+
+	cb := newTestNativeClipboard(t)
+	if _, err := cb.Read(context.Background(), SelectionPrimary, MIMETypeText); err == nil {
+		t.Error("expected an error reading the primary selection")
+	}
+	if err := cb.Write(context.Background(), SelectionPrimary, TextContent("content")); err == nil {
+		t.Error("expected an error writing the primary selection")
+	}
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("content")); err != nil {
+		t.Errorf("expected the clipboard to still work, got %v", err)
+	}
+}
+
+func TestWaylandNativeClipboard_EmptySelection(t *testing.T) {
+	newFakeCompositor(t, wlSeatInterface, extDataControlManagerIface)
+
+	_, err := newTestNativeClipboard(t).Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if !errors.Is(err, ErrSelectionEmpty) {
+		t.Errorf("expected ErrSelectionEmpty, got %v", err)
+	}
+}
+
+func TestWaylandNativeClipboard_NoTextOffered(t *testing.T) {
+	f := newFakeCompositor(t, wlSeatInterface, extDataControlManagerIface)
+	f.copy("\x89PNG", "image/png")
+
+	if _, err := newTestNativeClipboard(t).Read(context.Background(), SelectionClipboard, MIMETypeText); err == nil {
+		t.Error("expected an error for a clipboard without text")
+	}
+}
+
+func TestWaylandNativeClipboard_Images(t *testing.T) {
+	f := newFakeCompositor(t, wlSeatInterface, extDataControlManagerIface)
+	f.copy("\x89PNG from another app", "image/png", "text/plain")
+
+	cb := newTestNativeClipboard(t)
+	got, err := cb.Read(context.Background(), SelectionClipboard, "image/png")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "\x89PNG from another app" {
+		t.Errorf("expected the image, got %q", got)
+	}
+	if _, err := cb.Read(context.Background(), SelectionClipboard, "image/jpeg"); err == nil {
+		t.Error("expected an error for a type that isn't offered")
+	}
+
+	image := Content{Type: "image/png", Data: []byte("\x89PNG\r\n\x1a\n\x00")}
+	if err := cb.Write(context.Background(), SelectionClipboard, image); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	f.mu.Lock()
+	offered := f.selection.mimeTypes
+	f.mu.Unlock()
+	if len(offered) != 1 || offered[0] != "image/png" {
+		t.Errorf("expected only image/png to be offered, got %v", offered)
+	}
+	got, err = newTestNativeClipboard(t).Read(context.Background(), SelectionClipboard, "image/png")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != string(image.Data) {
+		t.Errorf("expected %q, got %q", image.Data, got)
+	}
+}
+
+func TestWaylandNativeClipboard_OffersAlternatives(t *testing.T) {
+	f := newFakeCompositor(t, wlSeatInterface, extDataControlManagerIface)
+
+	content := TextContent("*hi*")
+	content.Alternatives = []Content{{Type: MIMETypeHTML, Data: []byte("<p><em>hi</em></p>")}}
+	if err := newTestNativeClipboard(t).Write(context.Background(), SelectionClipboard, content); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	f.mu.Lock()
+	offered := f.selection.mimeTypes
+	f.mu.Unlock()
+	if want := append(append([]string{}, textMIMETypes...), MIMETypeHTML); !reflect.DeepEqual(offered, want) {
+		t.Errorf("expected %v to be offered, got %v", want, offered)
+	}
+
+	reader := newTestNativeClipboard(t)
+	for mimeType, want := range map[string]string{MIMETypeText: "*hi*", MIMETypeHTML: "<p><em>hi</em></p>"} {
+		got, err := reader.Read(context.Background(), SelectionClipboard, mimeType)
+		if err != nil {
+			t.Fatalf("Read of %s failed: %v", mimeType, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", mimeType, want, got)
+		}
+	}
+}
+
+func TestWaylandNativeClipboard_RequiresDataControl(t *testing.T) {
+	newFakeCompositor(t, wlSeatInterface, "wl_data_device_manager")
+
+	_, err := newTestNativeClipboard(t).Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err == nil || !strings.Contains(err.Error(), "data-control") {
+		t.Errorf("expected a data-control error, got %v", err)
+	}
+}
+
+func TestWaylandNativeClipboard_NoCompositor(t *testing.T) {
+	t.Setenv("WAYLAND_DISPLAY", filepath.Join(t.TempDir(), "wayland-0"))
+
+	_, err := newTestNativeClipboard(t).Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing socket error, got %v", err)
+	}
+}
+
+func TestWaylandNativeClipboard_ReusesAndRestoresConnection(t *testing.T) {
+	f := newFakeCompositor(t, wlSeatInterface, extDataControlManagerIface)
+	f.copy("content", "text/plain")
+
+	cb := newTestNativeClipboard(t)
+	for i := 0; i < 3; i++ {
+		if _, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+	if n := f.connectionCount(); n != 1 {
+		t.Errorf("expected 1 connection, got %d", n)
+	}
+
+	// The first Read after losing the connection may fail, later ones
+	// reconnect.
+	f.dropClients()
+	_, _ = cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read after reconnecting failed: %v", err)
+	}
+	if string(got) != "content" {
+		t.Errorf("expected %q, got %q", "content", got)
+	}
+	if n := f.connectionCount(); n != 2 {
+		t.Errorf("expected 2 connections, got %d", n)
+	}
+}
+
+func TestWaylandSocketPath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	t.Setenv("WAYLAND_DISPLAY", "")
+	if got, _ := waylandSocketPath(); got != "/run/user/1000/wayland-0" {
+		t.Errorf("expected the default socket, got %q", got)
+	}
+	t.Setenv("WAYLAND_DISPLAY", "wayland-1")
+	if got, _ := waylandSocketPath(); got != "/run/user/1000/wayland-1" {
+		t.Errorf("expected wayland-1 in the runtime dir, got %q", got)
+	}
+	t.Setenv("WAYLAND_DISPLAY", "/tmp/wayland-2")
+	if got, _ := waylandSocketPath(); got != "/tmp/wayland-2" {
+		t.Errorf("expected an absolute socket path to be used as is, got %q", got)
+	}
+}
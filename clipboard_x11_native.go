@@ -0,0 +1,750 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// x11ConvertTimeout bounds how long a Read waits for the selection owner
+	// to answer, or to send the next chunk of a large transfer.
+	x11ConvertTimeout = 5 * time.Second
+
+	// x11MaxChunkSize caps how much is put into a single property. Larger
+	// contents are sent incrementally with the INCR protocol.
+	x11MaxChunkSize = 64 * 1024
+
+	// x11PropertyName is the property on our window that selection owners
+	// deliver converted contents to.
+	x11PropertyName = "CLIPBOARD_TXT_WATCHER"
+)
+
+var errX11ConversionRefused = errors.New("x11: the selection owner refused the conversion")
+
+// X11NativeClipboard connects to $DISPLAY itself and owns the CLIPBOARD
+// selection instead of running xclip. It keeps one connection open, which
+// answers paste requests from other clients after a Write, and reconnects if
+// it is lost.
+type X11NativeClipboard struct {
+	mu     sync.Mutex
+	client *x11Client
+}
+
+func (x *X11NativeClipboard) connect(ctx context.Context) (*x11Client, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.client != nil && !x.client.closed() {
+		return x.client, nil
+	}
+	client, err := dialX11Client(ctx, os.Getenv("DISPLAY"))
+	if err != nil {
+		return nil, err
+	}
+	x.client = client
+	return client, nil
+}
+
+func (x *X11NativeClipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	client, err := x.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	atom, err := client.selectionAtom(selection)
+	if err != nil {
+		return nil, err
+	}
+	return client.read(ctx, atom, mimeType)
+}
+
+func (x *X11NativeClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	client, err := x.connect(ctx)
+	if err != nil {
+		return err
+	}
+	atom, err := client.selectionAtom(selection)
+	if err != nil {
+		return err
+	}
+	return client.write(ctx, atom, content)
+}
+
+// Hold waits until every selection written was taken over by another
+// client.
+func (x *X11NativeClipboard) Hold(ctx context.Context) error {
+	x.mu.Lock()
+	client := x.client
+	x.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	return client.hold(ctx)
+}
+
+// Close drops the connection, and with it ownership of the selections.
+func (x *X11NativeClipboard) Close() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.client == nil {
+		return nil
+	}
+	err := x.client.close()
+	x.client = nil
+	return err
+}
+
+type x11Atoms struct {
+	clipboard  uint32
+	utf8String uint32
+	text       uint32
+	targets    uint32
+	incr       uint32
+	property   uint32
+}
+
+type x11Reply struct {
+	data []byte
+	err  error
+}
+
+type x11TransferKey struct {
+	window   uint32
+	property uint32
+}
+
+// x11Selection is the content of a selection we own: text, which is served
+// as every text target, and the representations served as targets of their
+// own.
+type x11Selection struct {
+	text    []byte
+	hasText bool
+	targets []x11Target
+}
+
+type x11Target struct {
+	atom uint32
+	data []byte
+}
+
+func (s x11Selection) target(atom uint32) (x11Target, bool) {
+	for _, t := range s.targets {
+		if t.atom == atom {
+			return t, true
+		}
+	}
+	return x11Target{}, false
+}
+
+// x11Transfer is an INCR transfer in progress: the rest of the data, sent a
+// chunk at a time whenever the requestor deletes the previous one.
+type x11Transfer struct {
+	data []byte
+	typ  uint32
+}
+
+// x11Client is one connection to the X server. Replies and events are read
+// by a goroutine of its own, so that it can serve the selection while a Read
+// or Write is waiting.
+type x11Client struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	setup     x11Setup
+	window    uint32
+	atoms     x11Atoms
+	chunkSize int
+
+	// readMu serializes reads, which share the property on our window.
+	// events passes them the SelectionNotify and PropertyNotify events for
+	// that window.
+	readMu sync.Mutex
+	events chan []byte
+
+	// owned maps the selections we own to their contents.
+	mu        sync.Mutex
+	seq       uint16
+	pending   map[uint16]chan x11Reply
+	owned     map[uint32]x11Selection
+	transfers map[x11TransferKey]*x11Transfer
+	// released is closed, and replaced, whenever a selection is lost.
+	released chan struct{}
+
+	done chan struct{}
+	err  error
+}
+
+func dialX11Client(ctx context.Context, display string) (*x11Client, error) {
+	conn, r, setup, err := dialX11(display)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &x11Client{
+		conn:  conn,
+		r:     r,
+		setup: setup,
+		// The lowest id in our range
+		window: setup.resourceIDBase | setup.resourceIDMask&-setup.resourceIDMask,
+		// ChangeProperty has a 24 byte header
+		chunkSize: min(x11MaxChunkSize, setup.maxRequestLength-24),
+		events:    make(chan []byte, 64),
+		pending:   make(map[uint16]chan x11Reply),
+		owned:     make(map[uint32]x11Selection),
+		transfers: make(map[x11TransferKey]*x11Transfer),
+		released:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go c.run()
+
+	if err := c.init(ctx); err != nil {
+		_ = c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// init creates the window that owns the selection and receives converted
+// contents, and looks up the atoms the clipboard uses.
+func (c *x11Client) init(ctx context.Context) error {
+	// An unmapped input-only window is all the selection protocol needs
+	err := c.send(newX11Request(x11OpCreateWindow, 0).
+		u32(c.window).u32(c.setup.root).
+		u16(0).u16(0).u16(1).u16(1).u16(0).
+		u16(x11WindowClassInputOnly).u32(0).
+		u32(x11CWEventMask).u32(x11PropertyChangeMask))
+	if err != nil {
+		return err
+	}
+
+	for _, atom := range []struct {
+		name string
+		atom *uint32
+	}{
+		{"CLIPBOARD", &c.atoms.clipboard},
+		{"UTF8_STRING", &c.atoms.utf8String},
+		{"TEXT", &c.atoms.text},
+		{"TARGETS", &c.atoms.targets},
+		{"INCR", &c.atoms.incr},
+		{x11PropertyName, &c.atoms.property},
+	} {
+		if *atom.atom, err = c.internAtom(ctx, atom.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// internAtom returns the atom named name, creating it if need be. MIME types
+// are used as atom names for the targets of other content than text.
+func (c *x11Client) internAtom(ctx context.Context, name string) (uint32, error) {
+	reply, err := c.call(ctx, newX11Request(x11OpInternAtom, 0).
+		u16(uint16(len(name))).u16(0).bytes([]byte(name)))
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(reply[8:]), nil
+}
+
+func (c *x11Client) send(req *x11Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeRequest(req)
+}
+
+// writeRequest sends a request. The caller holds c.mu.
+func (c *x11Client) writeRequest(req *x11Request) error {
+	c.seq++
+	if _, err := c.conn.Write(req.encode()); err != nil {
+		c.fail(fmt.Errorf("x11: %w", err))
+		return c.err
+	}
+	return nil
+}
+
+// call sends a request and waits for its reply.
+func (c *x11Client) call(ctx context.Context, req *x11Request) ([]byte, error) {
+	reply := make(chan x11Reply, 1)
+	c.mu.Lock()
+	err := c.writeRequest(req)
+	if err == nil {
+		c.pending[c.seq] = reply
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-reply:
+		return r.data, r.err
+	case <-c.done:
+		return nil, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *x11Client) run() {
+	for {
+		buf := make([]byte, 32)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			c.mu.Lock()
+			c.fail(fmt.Errorf("x11: connection lost: %w", err))
+			c.mu.Unlock()
+			return
+		}
+
+		seq := binary.LittleEndian.Uint16(buf[2:])
+		switch buf[0] {
+		case x11ResponseReply:
+			extra := int(binary.LittleEndian.Uint32(buf[4:])) * 4
+			reply := append(buf, make([]byte, extra)...)
+			if _, err := io.ReadFull(c.r, reply[32:]); err != nil {
+				c.mu.Lock()
+				c.fail(fmt.Errorf("x11: connection lost: %w", err))
+				c.mu.Unlock()
+				return
+			}
+			c.deliver(seq, x11Reply{data: reply})
+		case x11ResponseError:
+			c.deliver(seq, x11Reply{err: fmt.Errorf("x11: error %d in request %d", buf[1], buf[10])})
+		default:
+			c.handleEvent(buf)
+		}
+	}
+}
+
+// deliver hands a reply or error to the call waiting for it. Errors for
+// requests without a reply, such as writing to the window of a requestor
+// that has gone away, are dropped.
+func (c *x11Client) deliver(seq uint16, reply x11Reply) {
+	c.mu.Lock()
+	ch, ok := c.pending[seq]
+	delete(c.pending, seq)
+	c.mu.Unlock()
+	if ok {
+		ch <- reply
+	}
+}
+
+func (c *x11Client) handleEvent(ev []byte) {
+	u32 := func(offset int) uint32 { return binary.LittleEndian.Uint32(ev[offset:]) }
+
+	// The top bit marks events sent by other clients
+	switch ev[0] &^ 0x80 {
+	case x11EventSelectionRequest:
+		c.serve(u32(4), u32(12), u32(16), u32(20), u32(24))
+	case x11EventSelectionClear:
+		c.mu.Lock()
+		delete(c.owned, u32(12))
+		close(c.released)
+		c.released = make(chan struct{})
+		c.mu.Unlock()
+	case x11EventSelectionNotify:
+		if u32(8) == c.window {
+			c.queueEvent(ev)
+		}
+	case x11EventPropertyNotify:
+		// Reading our own selection, the window can be both
+		if ev[16] == x11PropertyDeleted {
+			c.continueTransfer(x11TransferKey{window: u32(4), property: u32(8)})
+		}
+		if u32(4) == c.window {
+			c.queueEvent(ev)
+		}
+	}
+}
+
+// queueEvent passes an event on to a Read. Nobody may be reading, in which
+// case it is stale anyway once the buffer is full.
+func (c *x11Client) queueEvent(ev []byte) {
+	select {
+	case c.events <- ev:
+	default:
+	}
+}
+
+// serve answers a SelectionRequest by putting the contents on the
+// requestor's window and telling it where they are.
+func (c *x11Client) serve(timestamp, requestor, selection, target, property uint32) {
+	// Obsolete clients leave the property out and expect the target's name
+	if property == 0 {
+		property = target
+	}
+
+	c.mu.Lock()
+	content, owned := c.owned[selection]
+	c.mu.Unlock()
+
+	var err error
+	switch {
+	case !owned:
+		property = 0
+	case target == c.atoms.targets:
+		targets := []uint32{c.atoms.targets}
+		if content.hasText {
+			targets = append(targets, c.atoms.utf8String, c.atoms.text, x11AtomString)
+		}
+		for _, t := range content.targets {
+			targets = append(targets, t.atom)
+		}
+		var atoms []byte
+		for _, atom := range targets {
+			atoms = binary.LittleEndian.AppendUint32(atoms, atom)
+		}
+		err = c.changeProperty(requestor, property, x11AtomAtom, 32, atoms)
+	case content.hasText && (target == c.atoms.utf8String || target == c.atoms.text):
+		err = c.sendData(requestor, property, c.atoms.utf8String, content.text)
+	case content.hasText && target == x11AtomString:
+		err = c.sendData(requestor, property, x11AtomString, toLatin1(string(content.text)))
+	default:
+		t, ok := content.target(target)
+		if !ok {
+			property = 0
+			break
+		}
+		err = c.sendData(requestor, property, t.atom, t.data)
+	}
+	if err != nil {
+		property = 0
+	}
+
+	notify := make([]byte, 32)
+	notify[0] = x11EventSelectionNotify
+	binary.LittleEndian.PutUint32(notify[4:], timestamp)
+	binary.LittleEndian.PutUint32(notify[8:], requestor)
+	binary.LittleEndian.PutUint32(notify[12:], selection)
+	binary.LittleEndian.PutUint32(notify[16:], target)
+	binary.LittleEndian.PutUint32(notify[20:], property)
+	_ = c.send(newX11Request(x11OpSendEvent, 0).u32(requestor).u32(0).bytes(notify))
+}
+
+// sendData puts the contents on the requestor's window, or starts an INCR
+// transfer if they don't fit into one property.
+func (c *x11Client) sendData(requestor, property, typ uint32, data []byte) error {
+	if len(data) <= c.chunkSize {
+		return c.changeProperty(requestor, property, typ, 8, data)
+	}
+
+	// Each chunk goes out once the requestor has deleted the previous one,
+	// so watch its window for property deletions.
+	err := c.send(newX11Request(x11OpChangeWindowAttributes, 0).
+		u32(requestor).u32(x11CWEventMask).u32(x11PropertyChangeMask))
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.transfers[x11TransferKey{window: requestor, property: property}] = &x11Transfer{data: data, typ: typ}
+	c.mu.Unlock()
+
+	size := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+	return c.changeProperty(requestor, property, c.atoms.incr, 32, size)
+}
+
+// continueTransfer sends the next chunk of an INCR transfer, and finally the
+// empty chunk that ends it.
+func (c *x11Client) continueTransfer(key x11TransferKey) {
+	c.mu.Lock()
+	t, ok := c.transfers[key]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	chunk := t.data[:min(len(t.data), c.chunkSize)]
+	t.data = t.data[len(chunk):]
+	if len(chunk) == 0 {
+		delete(c.transfers, key)
+	}
+	c.mu.Unlock()
+
+	_ = c.changeProperty(key.window, key.property, t.typ, 8, chunk)
+}
+
+func (c *x11Client) changeProperty(window, property, typ uint32, format int, data []byte) error {
+	return c.send(newX11Request(x11OpChangeProperty, x11PropModeReplace).
+		u32(window).u32(property).u32(typ).
+		u32(uint32(format)).
+		u32(uint32(len(data) / (format / 8))).
+		bytes(data))
+}
+
+func (c *x11Client) selectionAtom(selection Selection) (uint32, error) {
+	switch selection {
+	case SelectionClipboard:
+		return c.atoms.clipboard, nil
+	case SelectionPrimary:
+		return x11AtomPrimary, nil
+	case SelectionSecondary:
+		return x11AtomSecondary, nil
+	default:
+		return 0, unsupportedSelection("x11-native", selection)
+	}
+}
+
+func (c *x11Client) selectionOwner(ctx context.Context, selection uint32) (uint32, error) {
+	reply, err := c.call(ctx, newX11Request(x11OpGetSelectionOwner, 0).u32(selection))
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(reply[8:]), nil
+}
+
+func (c *x11Client) read(ctx context.Context, selection uint32, mimeType string) ([]byte, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	owner, err := c.selectionOwner(ctx, selection)
+	if err != nil {
+		return nil, err
+	}
+	if owner == 0 {
+		return nil, fmt.Errorf("x11: %w", ErrSelectionEmpty)
+	}
+
+	if !isTextType(mimeType) {
+		target, err := c.internAtom(ctx, mimeType)
+		if err != nil {
+			return nil, err
+		}
+		data, err := c.convert(ctx, selection, target)
+		if errors.Is(err, errX11ConversionRefused) {
+			return nil, fmt.Errorf("x11: the selection holds no %s", mimeType)
+		}
+		return data, err
+	}
+
+	for _, target := range []uint32{c.atoms.utf8String, x11AtomString} {
+		data, err := c.convert(ctx, selection, target)
+		if errors.Is(err, errX11ConversionRefused) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if target == x11AtomString {
+			return []byte(fromLatin1(data)), nil
+		}
+		return data, nil
+	}
+	return nil, errors.New("x11: the selection holds no text")
+}
+
+// convert asks the owner of a selection to convert it to target, and reads
+// the result, in chunks if the owner uses INCR. The caller holds c.readMu.
+func (c *x11Client) convert(ctx context.Context, selection, target uint32) ([]byte, error) {
+	// Drop events left over from an earlier Read that gave up
+	for len(c.events) > 0 {
+		<-c.events
+	}
+
+	err := c.send(newX11Request(x11OpConvertSelection, 0).
+		u32(c.window).u32(selection).u32(target).u32(c.atoms.property).u32(0))
+	if err != nil {
+		return nil, err
+	}
+	ev, err := c.waitEvent(ctx, func(ev []byte) bool {
+		return ev[0]&^0x80 == x11EventSelectionNotify
+	})
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(ev[20:]) == 0 {
+		return nil, errX11ConversionRefused
+	}
+
+	data, typ, err := c.takeProperty(ctx)
+	if err != nil || typ != c.atoms.incr {
+		return data, err
+	}
+
+	// Deleting the INCR property asked for the first chunk
+	var buf []byte
+	for {
+		_, err := c.waitEvent(ctx, func(ev []byte) bool {
+			return ev[0]&^0x80 == x11EventPropertyNotify &&
+				binary.LittleEndian.Uint32(ev[8:]) == c.atoms.property &&
+				ev[16] == x11PropertyNewValue
+		})
+		if err != nil {
+			return nil, err
+		}
+		chunk, typ, err := c.takeProperty(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case typ == 0:
+			// The chunk this event announced was already read
+		case len(chunk) == 0:
+			return buf, nil
+		default:
+			buf = append(buf, chunk...)
+		}
+	}
+}
+
+func (c *x11Client) waitEvent(ctx context.Context, match func([]byte) bool) ([]byte, error) {
+	timer := time.NewTimer(x11ConvertTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case ev := <-c.events:
+			if match(ev) {
+				return ev, nil
+			}
+		case <-c.done:
+			return nil, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, errors.New("x11: timed out waiting for the selection owner")
+		}
+	}
+}
+
+// takeProperty reads and deletes the property on our window. A type of 0
+// means there was no such property.
+func (c *x11Client) takeProperty(ctx context.Context) ([]byte, uint32, error) {
+	var data []byte
+	var typ, offset uint32
+	for {
+		reply, err := c.call(ctx, newX11Request(x11OpGetProperty, 0).
+			u32(c.window).u32(c.atoms.property).u32(0).
+			u32(offset).u32(x11MaxChunkSize/4))
+		if err != nil {
+			return nil, 0, err
+		}
+		typ = binary.LittleEndian.Uint32(reply[8:])
+		after := binary.LittleEndian.Uint32(reply[12:])
+		n := int(binary.LittleEndian.Uint32(reply[16:])) * int(reply[1]) / 8
+		if n > len(reply)-32 {
+			return nil, 0, errors.New("x11: malformed property reply")
+		}
+		data = append(data, reply[32:32+n]...)
+		offset += uint32(n / 4)
+		if after == 0 {
+			break
+		}
+	}
+
+	if typ != 0 {
+		if err := c.send(newX11Request(x11OpDeleteProperty, 0).u32(c.window).u32(c.atoms.property)); err != nil {
+			return nil, 0, err
+		}
+	}
+	return data, typ, nil
+}
+
+func (c *x11Client) write(ctx context.Context, selection uint32, content Content) error {
+	var owned x11Selection
+	for _, representation := range content.representations() {
+		if representation.IsText() {
+			if !owned.hasText {
+				owned.text, owned.hasText = representation.Data, true
+			}
+			continue
+		}
+		atom, err := c.internAtom(ctx, representation.Type)
+		if err != nil {
+			return err
+		}
+		owned.targets = append(owned.targets, x11Target{atom: atom, data: representation.Data})
+	}
+	c.mu.Lock()
+	c.owned[selection] = owned
+	c.mu.Unlock()
+
+	err := c.send(newX11Request(x11OpSetSelectionOwner, 0).u32(c.window).u32(selection).u32(0))
+	if err != nil {
+		return err
+	}
+	owner, err := c.selectionOwner(ctx, selection)
+	if err != nil {
+		return err
+	}
+	if owner != c.window {
+		c.mu.Lock()
+		delete(c.owned, selection)
+		c.mu.Unlock()
+		return errors.New("x11: could not take ownership of the selection")
+	}
+	return nil
+}
+
+// hold waits until no selection is owned anymore.
+func (c *x11Client) hold(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		held, released := len(c.owned), c.released
+		c.mu.Unlock()
+		if held == 0 {
+			return nil
+		}
+		select {
+		case <-released:
+		case <-c.done:
+			return c.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// fail shuts the connection down after an unrecoverable error. The caller
+// holds c.mu.
+func (c *x11Client) fail(err error) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	c.err = err
+	close(c.done)
+	_ = c.conn.Close()
+}
+
+func (c *x11Client) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *x11Client) close() error {
+	c.mu.Lock()
+	c.fail(net.ErrClosed)
+	c.mu.Unlock()
+	return nil
+}
+
+// STRING is ISO 8859-1, so characters beyond it are replaced when sending
+// and every byte is a character when receiving.
+func toLatin1(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+func fromLatin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
@@ -0,0 +1,669 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeXProperty struct {
+	typ    uint32
+	format byte
+	data   []byte
+}
+
+// fakeXServer implements the handful of core requests that selections
+// need, for any number of clients.
+type fakeXServer struct {
+	t        *testing.T
+	listener net.Listener
+	cookie   []byte
+
+	mu          sync.Mutex
+	clients     []*fakeXClient
+	atoms       map[string]uint32
+	windows     map[uint32]*fakeXClient
+	listeners   map[uint32]map[*fakeXClient]bool
+	properties  map[uint32]map[uint32]fakeXProperty
+	owners      map[uint32]uint32
+	incrStarted int
+}
+
+type fakeXClient struct {
+	conn net.Conn
+	seq  uint16
+}
+
+// newFakeXServer listens on a socket in a temp dir and points DISPLAY at it.
+// With a cookie, clients have to present it.
+func newFakeXServer(t *testing.T, cookie []byte) *fakeXServer {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "X:0")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DISPLAY", path)
+	t.Setenv("XAUTHORITY", filepath.Join(t.TempDir(), "missing"))
+
+	s := &fakeXServer{
+		t:        t,
+		listener: listener,
+		cookie:   cookie,
+		atoms: map[string]uint32{
+			"PRIMARY":   1,
+			"SECONDARY": 2,
+			"ATOM":      x11AtomAtom,
+			"STRING":    x11AtomString,
+		},
+		windows:    make(map[uint32]*fakeXClient),
+		listeners:  make(map[uint32]map[*fakeXClient]bool),
+		properties: make(map[uint32]map[uint32]fakeXProperty),
+		owners:     make(map[uint32]uint32),
+	}
+	go s.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, c := range s.clients {
+			_ = c.conn.Close()
+		}
+	})
+	return s
+}
+
+func (s *fakeXServer) serve() {
+	for base := uint32(1); ; base++ {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		client := &fakeXClient{conn: conn}
+		s.mu.Lock()
+		s.clients = append(s.clients, client)
+		s.mu.Unlock()
+		go s.handle(client, base<<21)
+	}
+}
+
+func (s *fakeXServer) handle(client *fakeXClient, base uint32) {
+	if !s.handshake(client, base) {
+		_ = client.conn.Close()
+		return
+	}
+
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(client.conn, header); err != nil {
+			return
+		}
+		req := make([]byte, int(binary.LittleEndian.Uint16(header[2:]))*4)
+		copy(req, header)
+		if _, err := io.ReadFull(client.conn, req[4:]); err != nil {
+			return
+		}
+		s.mu.Lock()
+		client.seq++
+		s.request(client, req)
+		s.mu.Unlock()
+	}
+}
+
+func (s *fakeXServer) handshake(client *fakeXClient, base uint32) bool {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(client.conn, header); err != nil {
+		return false
+	}
+	nameLen := int(binary.LittleEndian.Uint16(header[6:]))
+	dataLen := int(binary.LittleEndian.Uint16(header[8:]))
+	auth := make([]byte, pad4(nameLen)+pad4(dataLen))
+	if _, err := io.ReadFull(client.conn, auth); err != nil {
+		return false
+	}
+	cookie := auth[pad4(nameLen) : pad4(nameLen)+dataLen]
+
+	if s.cookie != nil && !bytes.Equal(cookie, s.cookie) {
+		reason := "No protocol specified"
+		reply := []byte{0, byte(len(reason)), 11, 0}
+		reply = binary.LittleEndian.AppendUint16(reply, 0)
+		reply = binary.LittleEndian.AppendUint16(reply, uint16(pad4(len(reason))/4))
+		reply = append(reply, reason...)
+		reply = append(reply, make([]byte, pad4(len(reason))-len(reason))...)
+		_, _ = client.conn.Write(reply)
+		return false
+	}
+
+	vendor := "fake"
+	extra := make([]byte, 32, 128)
+	binary.LittleEndian.PutUint32(extra[4:], base)
+	binary.LittleEndian.PutUint32(extra[8:], 1<<21-1)
+	binary.LittleEndian.PutUint16(extra[16:], uint16(len(vendor)))
+	// The smallest maximum request length a server may have, 16 KiB
+	binary.LittleEndian.PutUint16(extra[18:], 4096)
+	extra[20] = 1
+	extra = append(extra, vendor...)
+	screen := make([]byte, 40)
+	binary.LittleEndian.PutUint32(screen, 0x100)
+	extra = append(extra, screen...)
+
+	reply := []byte{1, 0, 11, 0}
+	reply = binary.LittleEndian.AppendUint16(reply, 0)
+	reply = binary.LittleEndian.AppendUint16(reply, uint16(len(extra)/4))
+	_, err := client.conn.Write(append(reply, extra...))
+	return err == nil
+}
+
+// fakeXRequest reads the 32-bit fields of a request.
+type fakeXRequest []byte
+
+func (r fakeXRequest) u32(offset int) uint32 { return binary.LittleEndian.Uint32(r[offset:]) }
+
+func (s *fakeXServer) request(client *fakeXClient, req []byte) {
+	r := fakeXRequest(req)
+	switch req[0] {
+	case x11OpCreateWindow:
+		s.windows[r.u32(4)] = client
+		if r.u32(28)&x11CWEventMask != 0 {
+			s.selectEvents(client, r.u32(4), r.u32(32))
+		}
+	case x11OpChangeWindowAttributes:
+		if r.u32(8)&x11CWEventMask != 0 {
+			s.selectEvents(client, r.u32(4), r.u32(12))
+		}
+	case x11OpInternAtom:
+		s.internAtom(client, r)
+	case x11OpChangeProperty:
+		s.changeProperty(r)
+	case x11OpDeleteProperty:
+		s.deleteProperty(r.u32(4), r.u32(8))
+	case x11OpGetProperty:
+		s.getProperty(client, r)
+	case x11OpSetSelectionOwner:
+		s.setSelectionOwner(r)
+	case x11OpGetSelectionOwner:
+		s.reply(client, 0, binary.LittleEndian.AppendUint32(nil, s.owners[r.u32(4)]))
+	case x11OpConvertSelection:
+		s.convertSelection(client, r)
+	case x11OpSendEvent:
+		s.sendEvent(r)
+	default:
+		s.t.Errorf("fake X server: unexpected request %d", req[0])
+	}
+}
+
+func (s *fakeXServer) internAtom(client *fakeXClient, r fakeXRequest) {
+	name := string(r[8 : 8+binary.LittleEndian.Uint16(r[4:])])
+	atom, ok := s.atoms[name]
+	if !ok {
+		atom = uint32(100 + len(s.atoms))
+		s.atoms[name] = atom
+	}
+	s.reply(client, 0, binary.LittleEndian.AppendUint32(nil, atom))
+}
+
+func (s *fakeXServer) setSelectionOwner(r fakeXRequest) {
+	owner, selection := r.u32(4), r.u32(8)
+	if old := s.owners[selection]; old != 0 && old != owner {
+		s.event(s.windows[old], x11EventSelectionClear, r.u32(12), old, selection)
+	}
+	s.owners[selection] = owner
+}
+
+func (s *fakeXServer) sendEvent(r fakeXRequest) {
+	target := s.windows[r.u32(4)]
+	if target == nil {
+		return
+	}
+	ev := append([]byte(nil), r[12:44]...)
+	ev[0] |= 0x80
+	binary.LittleEndian.PutUint16(ev[2:], target.seq)
+	_, _ = target.conn.Write(ev)
+}
+
+func (s *fakeXServer) changeProperty(r fakeXRequest) {
+	window, property := r.u32(4), r.u32(8)
+	format := r[16]
+	data := r[24 : 24+int(r.u32(20))*int(format)/8]
+	if s.properties[window] == nil {
+		s.properties[window] = make(map[uint32]fakeXProperty)
+	}
+	s.properties[window][property] = fakeXProperty{typ: r.u32(12), format: format, data: append([]byte(nil), data...)}
+	if r.u32(12) == s.atoms["INCR"] {
+		s.incrStarted++
+	}
+	s.propertyNotify(window, property, x11PropertyNewValue)
+}
+
+func (s *fakeXServer) getProperty(client *fakeXClient, r fakeXRequest) {
+	window, property := r.u32(4), r.u32(8)
+	offset, length := int(r.u32(16))*4, int(r.u32(20))*4
+	prop, ok := s.properties[window][property]
+	if !ok {
+		s.reply(client, 0, make([]byte, 24))
+		return
+	}
+	end := min(len(prop.data), offset+length)
+	value := prop.data[offset:end]
+	body := binary.LittleEndian.AppendUint32(nil, prop.typ)
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(prop.data)-end))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(value)/(int(prop.format)/8)))
+	body = append(body, make([]byte, 12)...)
+	body = append(body, value...)
+	s.reply(client, prop.format, body)
+	if r[1] == 1 && end == len(prop.data) {
+		s.deleteProperty(window, property)
+	}
+}
+
+func (s *fakeXServer) convertSelection(client *fakeXClient, r fakeXRequest) {
+	requestor, selection, target, property, timestamp := r.u32(4), r.u32(8), r.u32(12), r.u32(16), r.u32(20)
+	if owner := s.owners[selection]; owner != 0 {
+		s.event(s.windows[owner], x11EventSelectionRequest, timestamp, owner, requestor, selection, target, property)
+	} else {
+		s.event(client, x11EventSelectionNotify, timestamp, requestor, selection, target, 0)
+	}
+}
+
+func (s *fakeXServer) selectEvents(client *fakeXClient, window, mask uint32) {
+	if mask&x11PropertyChangeMask == 0 {
+		return
+	}
+	if s.listeners[window] == nil {
+		s.listeners[window] = make(map[*fakeXClient]bool)
+	}
+	s.listeners[window][client] = true
+}
+
+func (s *fakeXServer) deleteProperty(window, property uint32) {
+	if _, ok := s.properties[window][property]; !ok {
+		return
+	}
+	delete(s.properties[window], property)
+	s.propertyNotify(window, property, x11PropertyDeleted)
+}
+
+func (s *fakeXServer) propertyNotify(window, property uint32, state byte) {
+	for client := range s.listeners[window] {
+		ev := make([]byte, 32)
+		ev[0] = x11EventPropertyNotify
+		binary.LittleEndian.PutUint16(ev[2:], client.seq)
+		binary.LittleEndian.PutUint32(ev[4:], window)
+		binary.LittleEndian.PutUint32(ev[8:], property)
+		ev[16] = state
+		_, _ = client.conn.Write(ev)
+	}
+}
+
+// event sends an event whose fields from offset 4 on are all 32 bits.
+func (s *fakeXServer) event(client *fakeXClient, code byte, fields ...uint32) {
+	if client == nil {
+		return
+	}
+	ev := make([]byte, 4, 32)
+	ev[0] = code
+	binary.LittleEndian.PutUint16(ev[2:], client.seq)
+	for _, field := range fields {
+		ev = binary.LittleEndian.AppendUint32(ev, field)
+	}
+	_, _ = client.conn.Write(ev[:32])
+}
+
+func (s *fakeXServer) reply(client *fakeXClient, data byte, body []byte) {
+	if len(body) < 24 {
+		body = append(body, make([]byte, 24-len(body))...)
+	}
+	reply := []byte{x11ResponseReply, data}
+	reply = binary.LittleEndian.AppendUint16(reply, client.seq)
+	reply = binary.LittleEndian.AppendUint32(reply, uint32(pad4(len(body)-24)/4))
+	reply = append(reply, body...)
+	reply = append(reply, make([]byte, pad4(len(body))-len(body))...)
+	_, _ = client.conn.Write(reply)
+}
+
+func (s *fakeXServer) incrTransfers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.incrStarted
+}
+
+func newTestX11NativeClipboard(t *testing.T) *X11NativeClipboard {
+	t.Helper()
+	cb := &X11NativeClipboard{}
+	t.Cleanup(func() { _ = cb.Close() })
+	return cb
+}
+
+// convertTo asks for the clipboard in the given target, as a client other
+// than the owner would.
+func convertTo(t *testing.T, target string) ([]byte, error) {
+	t.Helper()
+	c, err := dialX11Client(context.Background(), os.Getenv("DISPLAY"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.close() }()
+
+	reply, err := c.call(context.Background(), newX11Request(x11OpInternAtom, 0).u16(uint16(len(target))).u16(0).bytes([]byte(target)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	return c.convert(context.Background(), c.atoms.clipboard, binary.LittleEndian.Uint32(reply[8:]))
+}
+
+func TestNewClipboard_ReturnsX11NativeWhenSpecified(t *testing.T) {
+	cb, err := NewClipboard("x11-native")
+	if err != nil {
+		t.Fatalf("NewClipboard failed: %v", err)
+	}
+
+	if _, ok := cb.(*X11NativeClipboard); !ok {
+		t.Errorf("expected *X11NativeClipboard, got %T", cb)
+	}
+}
+
+func TestX11NativeClipboard_WriteServesOtherClients(t *testing.T) {
+	newFakeXServer(t, nil)
+
+	writer := newTestX11NativeClipboard(t)
+	if err := writer.Write(context.Background(), SelectionClipboard, TextContent("hello\nwörld")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Reading through the same connection has the writer serve itself
+	for _, cb := range []*X11NativeClipboard{newTestX11NativeClipboard(t), writer} {
+		got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if string(got) != "hello\nwörld" {
+			t.Errorf("expected %q, got %q", "hello\nwörld", got)
+		}
+	}
+}
+
+func TestX11NativeClipboard_SelectionsAreIndependent(t *testing.T) {
+	newFakeXServer(t, nil)
+
+	writer := newTestX11NativeClipboard(t)
+	want := map[Selection]string{
+		SelectionClipboard: "copied",
+		SelectionPrimary:   "selected",
+		SelectionSecondary: "secondary",
+	}
+	for selection, content := range want {
+		if err := writer.Write(context.Background(), selection, TextContent(content)); err != nil {
+			t.Fatalf("Write(%s) failed: %v", selection, err)
+		}
+	}
+
+	reader := newTestX11NativeClipboard(t)
+	for selection, content := range want {
+		got, err := reader.Read(context.Background(), selection, MIMETypeText)
+		if err != nil {
+			t.Fatalf("Read(%s) failed: %v", selection, err)
+		}
+		if string(got) != content {
+			t.Errorf("Read(%s): expected %q, got %q", selection, content, got)
+		}
+	}
+
+	if _, err := reader.Read(context.Background(), SelectionBoth, MIMETypeText); err == nil {
+		t.Error("expected an error for a selection X11 does not have")
+	}
+}
+
+func TestX11NativeClipboard_LargeContentUsesINCR(t *testing.T) {
+	s := newFakeXServer(t, nil)
+
+	content := strings.Repeat("0123456789abcdef", 5000)
+	writer := newTestX11NativeClipboard(t)
+	if err := writer.Write(context.Background(), SelectionClipboard, TextContent(content)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	for _, cb := range []*X11NativeClipboard{newTestX11NativeClipboard(t), writer} {
+		got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if string(got) != content {
+			t.Errorf("expected %d bytes back, got %d", len(content), len(got))
+		}
+	}
+	if n := s.incrTransfers(); n != 2 {
+		t.Errorf("expected 2 INCR transfers, got %d", n)
+	}
+}
+
+func TestX11NativeClipboard_Targets(t *testing.T) {
+	newFakeXServer(t, nil)
+
+	if err := newTestX11NativeClipboard(t).Write(context.Background(), SelectionClipboard, TextContent("café ✓")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	targets, err := convertTo(t, "TARGETS")
+	if err != nil {
+		t.Fatalf("converting to TARGETS failed: %v", err)
+	}
+	if len(targets) != 16 {
+		t.Errorf("expected 4 targets, got %d bytes", len(targets))
+	}
+
+	for target, want := range map[string]string{
+		"UTF8_STRING": "café ✓",
+		"TEXT":        "café ✓",
+		"STRING":      "caf\xe9 ?",
+	} {
+		got, err := convertTo(t, target)
+		if err != nil {
+			t.Fatalf("converting to %s failed: %v", target, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", target, want, got)
+		}
+	}
+
+	if _, err := convertTo(t, "image/png"); err != errX11ConversionRefused {
+		t.Errorf("expected an unsupported target to be refused, got %v", err)
+	}
+}
+
+func TestX11NativeClipboard_Images(t *testing.T) {
+	newFakeXServer(t, nil)
+
+	image := Content{Type: "image/png", Data: []byte("\x89PNG\r\n\x1a\n\x00\xff")}
+	if err := newTestX11NativeClipboard(t).Write(context.Background(), SelectionClipboard, image); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	targets, err := convertTo(t, "TARGETS")
+	if err != nil {
+		t.Fatalf("converting to TARGETS failed: %v", err)
+	}
+	if len(targets) != 8 {
+		t.Errorf("expected 2 targets, got %d bytes", len(targets))
+	}
+	if _, err := convertTo(t, "UTF8_STRING"); err != errX11ConversionRefused {
+		t.Errorf("expected an image not to be converted to text, got %v", err)
+	}
+
+	cb := newTestX11NativeClipboard(t)
+	got, err := cb.Read(context.Background(), SelectionClipboard, "image/png")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != string(image.Data) {
+		t.Errorf("expected %q, got %q", image.Data, got)
+	}
+	if _, err := cb.Read(context.Background(), SelectionClipboard, "image/jpeg"); err == nil {
+		t.Error("expected an error for a type the owner doesn't have")
+	}
+}
+
+func TestX11NativeClipboard_OffersAlternatives(t *testing.T) {
+	newFakeXServer(t, nil)
+
+	content := TextContent("*hi*")
+	content.Alternatives = []Content{{Type: MIMETypeHTML, Data: []byte("<p><em>hi</em></p>")}}
+	if err := newTestX11NativeClipboard(t).Write(context.Background(), SelectionClipboard, content); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	targets, err := convertTo(t, "TARGETS")
+	if err != nil {
+		t.Fatalf("converting to TARGETS failed: %v", err)
+	}
+	if len(targets) != 20 {
+		t.Errorf("expected 5 targets, got %d bytes", len(targets))
+	}
+
+	reader := newTestX11NativeClipboard(t)
+	for mimeType, want := range map[string]string{MIMETypeText: "*hi*", MIMETypeHTML: "<p><em>hi</em></p>"} {
+		got, err := reader.Read(context.Background(), SelectionClipboard, mimeType)
+		if err != nil {
+			t.Fatalf("Read of %s failed: %v", mimeType, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", mimeType, want, got)
+		}
+	}
+}
+
+func TestX11NativeClipboard_LosesOwnership(t *testing.T) {
+	newFakeXServer(t, nil)
+
+	first, second := newTestX11NativeClipboard(t), newTestX11NativeClipboard(t)
+	if err := first.Write(context.Background(), SelectionClipboard, TextContent("first")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := second.Write(context.Background(), SelectionClipboard, TextContent("second")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	got, err := first.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "second" {
+		t.Errorf("expected %q, got %q", "second", got)
+	}
+
+	first.client.mu.Lock()
+	_, owned := first.client.owned[first.client.atoms.clipboard]
+	first.client.mu.Unlock()
+	if owned {
+		t.Error("expected the first writer to have lost the clipboard")
+	}
+}
+
+func TestX11NativeClipboard_HoldsUntilReplaced(t *testing.T) {
+	newFakeXServer(t, nil)
+
+	first, second := newTestX11NativeClipboard(t), newTestX11NativeClipboard(t)
+	for _, selection := range []Selection{SelectionClipboard, SelectionPrimary} {
+		if err := first.Write(context.Background(), selection, TextContent("first")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	held := make(chan error, 1)
+	go func() { held <- first.Hold(context.Background()) }()
+
+	// Taking one selection leaves the other held
+	if err := second.Write(context.Background(), SelectionClipboard, TextContent("second")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	select {
+	case err := <-held:
+		t.Fatalf("expected Hold to wait while PRIMARY is held, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := second.Write(context.Background(), SelectionPrimary, TextContent("second")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	select {
+	case err := <-held:
+		if err != nil {
+			t.Errorf("Hold failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Hold to return once every selection was taken")
+	}
+}
+
+func TestX11NativeClipboard_EmptyClipboard(t *testing.T) {
+	newFakeXServer(t, nil)
+
+	_, err := newTestX11NativeClipboard(t).Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if !errors.Is(err, ErrSelectionEmpty) {
+		t.Errorf("expected ErrSelectionEmpty, got %v", err)
+	}
+}
+
+func TestX11NativeClipboard_Xauthority(t *testing.T) {
+	cookie := []byte("0123456789abcdef")
+	newFakeXServer(t, cookie)
+
+	if _, err := newTestX11NativeClipboard(t).Read(context.Background(), SelectionClipboard, MIMETypeText); err == nil || !strings.Contains(err.Error(), "No protocol specified") {
+		t.Errorf("expected the connection to be refused without a cookie, got %v", err)
+	}
+
+	var xauth []byte
+	for _, field := range []string{"", "0", xauthMagicCookie, string(cookie)} {
+		xauth = binary.BigEndian.AppendUint16(xauth, uint16(len(field)))
+		xauth = append(xauth, field...)
+	}
+	xauth = append(binary.BigEndian.AppendUint16(nil, xauthFamilyWild), xauth...)
+	path := filepath.Join(t.TempDir(), "Xauthority")
+	if err := os.WriteFile(path, xauth, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XAUTHORITY", path)
+
+	if _, err := newTestX11NativeClipboard(t).Read(context.Background(), SelectionClipboard, MIMETypeText); err != nil && !errors.Is(err, ErrSelectionEmpty) {
+		t.Errorf("expected the cookie to be accepted, got %v", err)
+	}
+}
+
+func TestParseX11Display(t *testing.T) {
+	tests := []struct {
+		display string
+		want    x11Display
+	}{
+		{":0", x11Display{network: "unix", address: "/tmp/.X11-unix/X0", number: "0"}},
+		{":1.0", x11Display{network: "unix", address: "/tmp/.X11-unix/X1", number: "1"}},
+		{"unix:2", x11Display{network: "unix", address: "/tmp/.X11-unix/X2", number: "2"}},
+		{"remote:10.0", x11Display{network: "tcp", address: "remote:6010", number: "10"}},
+		{"/private/tmp/org.xquartz:0", x11Display{network: "unix", address: "/private/tmp/org.xquartz:0", number: "0"}},
+	}
+	for _, tt := range tests {
+		got, err := parseX11Display(tt.display)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.display, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %+v, got %+v", tt.display, tt.want, got)
+		}
+	}
+
+	for _, display := range []string{"", "nocolon", ":x"} {
+		if _, err := parseX11Display(display); err == nil {
+			t.Errorf("%q: expected an error", display)
+		}
+	}
+}
@@ -1,21 +1,171 @@
 package main
 
 import (
+	"time"
+
 	"github.com/BurntSushi/toml"
 )
 
+const defaultDebounce = 100 * time.Millisecond
+
+// WatchSettings are the options of a single watch. At the top level of the
+// config file they also act as defaults for every [[watch]] entry.
+type WatchSettings struct {
+	ClipboardBackend  string        `toml:"clipboard_backend"`
+	ClipboardBackends []string      `toml:"clipboard_backends"`
+	WaitForFile       bool          `toml:"wait_for_file"`
+	Debounce          time.Duration `toml:"debounce"`
+	StableCheck       bool          `toml:"stable_check"`
+	WatchMode         WatchMode     `toml:"watch_mode"`
+	PollInterval      time.Duration `toml:"poll_interval"`
+	InitialSync       InitialSync   `toml:"initial_sync"`
+	Pick              Pick          `toml:"pick"`
+	Ignore            []string      `toml:"ignore"`
+	Direction         Direction     `toml:"direction"`
+	Selection         Selection     `toml:"selection"`
+	MIMEType          string        `toml:"mime_type"`
+	Representations   []string      `toml:"representations"`
+
+	ClipboardPollInterval time.Duration `toml:"clipboard_poll_interval"`
+	ClipboardTimeout      time.Duration `toml:"clipboard_timeout"`
+
+	OSC52   OSC52Config   `toml:"osc52"`
+	Tmux    TmuxConfig    `toml:"tmux"`
+	Screen  ScreenConfig  `toml:"screen"`
+	Command CommandConfig `toml:"command"`
+
+	Retry RetryConfig `toml:"retry"`
+
+	Transforms []TransformConfig `toml:"transform"`
+	Secrets    SecretsConfig     `toml:"secrets"`
+}
+
+type WatchConfig struct {
+	Name string `toml:"name"`
+	Path string `toml:"path"`
+	WatchSettings
+}
+
 type Config struct {
-	WatchFile        string `toml:"watch_file"`
-	ClipboardBackend string `toml:"clipboard_backend"`
+	WatchFile string `toml:"watch_file"`
+	WatchSettings
+	History HistoryConfig `toml:"history"`
+	Watch   []WatchConfig `toml:"-"`
 }
 
-func LoadConfig(path string) (*Config, error) {
-	cfg := Config{
-		ClipboardBackend: "wayland",
+func DefaultConfig() *Config {
+	return &Config{
+		WatchSettings: WatchSettings{
+			ClipboardBackend: "auto",
+			Debounce:         defaultDebounce,
+			WatchMode:        WatchModeAuto,
+			PollInterval:     defaultPollInterval,
+			InitialSync:      InitialSyncFile,
+			Pick:             PickNewest,
+			Direction:        DirectionFileToClipboard,
+			Selection:        SelectionClipboard,
+			MIMEType:         MIMETypeAuto,
+
+			ClipboardPollInterval: defaultClipboardPollInterval,
+			ClipboardTimeout:      defaultClipboardTimeout,
+
+			OSC52: OSC52Config{
+				TTY:           defaultOSC52TTY,
+				Passthrough:   OSC52PassthroughAuto,
+				QueryTimeout:  defaultOSC52QueryTimeout,
+				QueryInterval: defaultOSC52QueryInterval,
+			},
+			Command: CommandConfig{
+				WriteInput: CommandInputStdin,
+				Timeout:    defaultCommandTimeout,
+			},
+			Retry: RetryConfig{
+				MaxAttempts:  defaultRetryMaxAttempts,
+				InitialDelay: defaultRetryInitialDelay,
+				MaxDelay:     defaultRetryMaxDelay,
+			},
+			Secrets: SecretsConfig{
+				Action: SecretActionOff,
+			},
+		},
+		History: HistoryConfig{
+			MaxEntries: defaultHistoryMaxEntries,
+			MaxAge:     defaultHistoryMaxAge,
+		},
 	}
-	_, err := toml.DecodeFile(path, &cfg)
+}
+
+func LoadConfig(path string) (*Config, error) {
+	// [[watch]] entries are decoded in a second pass, on top of a copy of the
+	// top-level settings, so any option they leave out is inherited.
+	raw := struct {
+		Config
+		Watch []toml.Primitive `toml:"watch"`
+	}{Config: *DefaultConfig()}
+
+	md, err := toml.DecodeFile(path, &raw)
 	if err != nil {
 		return nil, err
 	}
+
+	cfg := raw.Config
+	for _, prim := range raw.Watch {
+		wc := WatchConfig{WatchSettings: cfg.WatchSettings.withoutLists()}
+		if err := md.PrimitiveDecode(prim, &wc); err != nil {
+			return nil, err
+		}
+		wc.inheritLists(cfg.WatchSettings)
+		cfg.Watch = append(cfg.Watch, wc)
+	}
 	return &cfg, nil
 }
+
+// withoutLists returns the settings with their lists and maps left out. The
+// decoder fills those in place, which would change the top-level settings
+// that a watch entry inherits them from.
+func (s WatchSettings) withoutLists() WatchSettings {
+	s.ClipboardBackends, s.Ignore, s.Representations, s.Transforms = nil, nil, nil, nil
+	s.Command.Read, s.Command.Write, s.Command.Env = nil, nil, nil
+	s.Secrets.Patterns = nil
+	return s
+}
+
+// inheritLists takes the lists and maps a watch entry left out from top.
+func (s *WatchSettings) inheritLists(top WatchSettings) {
+	if s.ClipboardBackends == nil {
+		s.ClipboardBackends = top.ClipboardBackends
+	}
+	if s.Ignore == nil {
+		s.Ignore = top.Ignore
+	}
+	if s.Representations == nil {
+		s.Representations = top.Representations
+	}
+	if s.Transforms == nil {
+		s.Transforms = top.Transforms
+	}
+	if s.Command.Read == nil {
+		s.Command.Read = top.Command.Read
+	}
+	if s.Command.Write == nil {
+		s.Command.Write = top.Command.Write
+	}
+	if s.Command.Env == nil {
+		s.Command.Env = top.Command.Env
+	}
+	if s.Secrets.Patterns == nil {
+		s.Secrets.Patterns = top.Secrets.Patterns
+	}
+}
+
+// Watches returns every watch the config describes: the [[watch]] entries if
+// there are any, otherwise a single watch built from the top-level settings.
+func (c *Config) Watches() []WatchConfig {
+	if len(c.Watch) > 0 {
+		return c.Watch
+	}
+	if c.WatchFile == "" {
+		return nil
+	}
+	return []WatchConfig{{Path: c.WatchFile, WatchSettings: c.WatchSettings}}
+}
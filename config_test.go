@@ -3,7 +3,9 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig_ReadsWatchFile(t *testing.T) {
@@ -25,7 +27,7 @@ func TestLoadConfig_ReadsWatchFile(t *testing.T) {
 	}
 }
 
-func TestLoadConfig_ClipboardBackendDefaultsToWayland(t *testing.T) {
+func TestLoadConfig_ClipboardBackendDefaultsToAuto(t *testing.T) {
 	dir := t.TempDir()
 	configPath := filepath.Join(dir, "config.toml")
 	err := os.WriteFile(configPath, []byte(`watch_file = "/tmp/clipboard.txt"`), 0o644)
@@ -38,8 +40,8 @@ func TestLoadConfig_ClipboardBackendDefaultsToWayland(t *testing.T) {
 		t.Fatalf("LoadConfig failed: %v", err)
 	}
 
-	if cfg.ClipboardBackend != "wayland" {
-		t.Errorf("got ClipboardBackend=%q, want %q", cfg.ClipboardBackend, "wayland")
+	if cfg.ClipboardBackend != "auto" {
+		t.Errorf("got ClipboardBackend=%q, want %q", cfg.ClipboardBackend, "auto")
 	}
 }
 
@@ -69,3 +71,716 @@ func TestLoadConfig_ReturnsErrorForNonExistentFile(t *testing.T) {
 		t.Error("expected error for non-existent file, got nil")
 	}
 }
+
+func TestLoadConfig_ReadsWaitForFile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `watch_file = "/tmp/clipboard.txt"
+wait_for_file = true`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if !cfg.WaitForFile {
+		t.Error("expected WaitForFile to be true")
+	}
+}
+
+func TestLoadConfig_DebounceDefaultsTo100ms(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	err := os.WriteFile(configPath, []byte(`watch_file = "/tmp/clipboard.txt"`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.Debounce != 100*time.Millisecond {
+		t.Errorf("got Debounce=%v, want %v", cfg.Debounce, 100*time.Millisecond)
+	}
+}
+
+func TestLoadConfig_ReadsDebounceAndStableCheck(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `watch_file = "/tmp/clipboard.txt"
+debounce = "500ms"
+stable_check = true`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.Debounce != 500*time.Millisecond {
+		t.Errorf("got Debounce=%v, want %v", cfg.Debounce, 500*time.Millisecond)
+	}
+	if !cfg.StableCheck {
+		t.Error("expected StableCheck to be true")
+	}
+}
+
+func TestLoadConfig_WatchModeDefaultsToAuto(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	err := os.WriteFile(configPath, []byte(`watch_file = "/tmp/clipboard.txt"`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.WatchMode != WatchModeAuto {
+		t.Errorf("got WatchMode=%q, want %q", cfg.WatchMode, WatchModeAuto)
+	}
+	if cfg.PollInterval != time.Second {
+		t.Errorf("got PollInterval=%v, want %v", cfg.PollInterval, time.Second)
+	}
+}
+
+func TestLoadConfig_ReadsWatchModeAndPollInterval(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `watch_file = "/tmp/clipboard.txt"
+watch_mode = "poll"
+poll_interval = "250ms"`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.WatchMode != WatchModePoll {
+		t.Errorf("got WatchMode=%q, want %q", cfg.WatchMode, WatchModePoll)
+	}
+	if cfg.PollInterval != 250*time.Millisecond {
+		t.Errorf("got PollInterval=%v, want %v", cfg.PollInterval, 250*time.Millisecond)
+	}
+}
+
+func TestLoadConfig_InitialSyncDefaultsToFile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	err := os.WriteFile(configPath, []byte(`watch_file = "/tmp/clipboard.txt"`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.InitialSync != InitialSyncFile {
+		t.Errorf("got InitialSync=%q, want %q", cfg.InitialSync, InitialSyncFile)
+	}
+}
+
+func TestLoadConfig_InitialSyncCanBeOverridden(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `watch_file = "/tmp/clipboard.txt"
+initial_sync = "none"`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.InitialSync != InitialSyncNone {
+		t.Errorf("got InitialSync=%q, want %q", cfg.InitialSync, InitialSyncNone)
+	}
+}
+
+func TestLoadConfig_ReadsWatchEntries(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `clipboard_backend = "x11"
+debounce = "300ms"
+
+[[watch]]
+name = "notes"
+path = "/tmp/notes.txt"
+
+[[watch]]
+path = "/tmp/share.txt"
+clipboard_backend = "wayland"
+watch_mode = "poll"
+wait_for_file = true`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	watches := cfg.Watches()
+	if len(watches) != 2 {
+		t.Fatalf("got %d watches, want 2", len(watches))
+	}
+
+	notes := watches[0]
+	if notes.Name != "notes" || notes.Path != "/tmp/notes.txt" {
+		t.Errorf("got Name=%q Path=%q, want %q %q", notes.Name, notes.Path, "notes", "/tmp/notes.txt")
+	}
+	// Options left out of an entry are inherited from the top level
+	if notes.ClipboardBackend != "x11" {
+		t.Errorf("got ClipboardBackend=%q, want %q", notes.ClipboardBackend, "x11")
+	}
+	if notes.Debounce != 300*time.Millisecond {
+		t.Errorf("got Debounce=%v, want %v", notes.Debounce, 300*time.Millisecond)
+	}
+	if notes.WatchMode != WatchModeAuto {
+		t.Errorf("got WatchMode=%q, want %q", notes.WatchMode, WatchModeAuto)
+	}
+
+	share := watches[1]
+	if share.Path != "/tmp/share.txt" {
+		t.Errorf("got Path=%q, want %q", share.Path, "/tmp/share.txt")
+	}
+	if share.ClipboardBackend != "wayland" {
+		t.Errorf("got ClipboardBackend=%q, want %q", share.ClipboardBackend, "wayland")
+	}
+	if share.WatchMode != WatchModePoll {
+		t.Errorf("got WatchMode=%q, want %q", share.WatchMode, WatchModePoll)
+	}
+	if !share.WaitForFile {
+		t.Error("expected WaitForFile to be true")
+	}
+	if share.Debounce != 300*time.Millisecond {
+		t.Errorf("got Debounce=%v, want %v", share.Debounce, 300*time.Millisecond)
+	}
+}
+
+func TestConfig_Watches_FallsBackToWatchFile(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.WatchFile = "/tmp/clipboard.txt"
+	cfg.ClipboardBackend = "x11"
+
+	watches := cfg.Watches()
+	if len(watches) != 1 {
+		t.Fatalf("got %d watches, want 1", len(watches))
+	}
+	if watches[0].Path != "/tmp/clipboard.txt" {
+		t.Errorf("got Path=%q, want %q", watches[0].Path, "/tmp/clipboard.txt")
+	}
+	if watches[0].ClipboardBackend != "x11" {
+		t.Errorf("got ClipboardBackend=%q, want %q", watches[0].ClipboardBackend, "x11")
+	}
+}
+
+func TestConfig_Watches_EmptyWithoutWatchFile(t *testing.T) {
+	if watches := DefaultConfig().Watches(); len(watches) != 0 {
+		t.Errorf("got %d watches, want 0", len(watches))
+	}
+}
+
+func TestLoadConfig_ReadsPickAndIgnore(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `watch_file = "/tmp/snippets/*.txt"
+pick = "created"
+ignore = ["draft-*"]`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.Pick != PickCreated {
+		t.Errorf("got Pick=%q, want %q", cfg.Pick, PickCreated)
+	}
+	if len(cfg.Ignore) != 1 || cfg.Ignore[0] != "draft-*" {
+		t.Errorf("got Ignore=%v, want [draft-*]", cfg.Ignore)
+	}
+}
+
+func TestLoadConfig_WatchEntriesDontShareLists(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `ignore = ["*.bak", "*.tmp"]
+clipboard_backends = ["wayland", "x11"]
+
+[command.env]
+DISPLAY = ":0"
+
+[[watch]]
+path = "/tmp/a.txt"
+ignore = ["*.log"]
+clipboard_backends = ["osc52"]
+
+[watch.command.env]
+DISPLAY = ":1"
+
+[[watch]]
+path = "/tmp/b.txt"`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(cfg.Watch) != 2 {
+		t.Fatalf("got %d watches, want 2", len(cfg.Watch))
+	}
+
+	first := cfg.Watch[0]
+	if !reflect.DeepEqual(first.Ignore, []string{"*.log"}) || !reflect.DeepEqual(first.ClipboardBackends, []string{"osc52"}) || first.Command.Env["DISPLAY"] != ":1" {
+		t.Errorf("got first watch Ignore=%v ClipboardBackends=%v Env=%v, want its own", first.Ignore, first.ClipboardBackends, first.Command.Env)
+	}
+	for _, s := range []WatchSettings{cfg.WatchSettings, cfg.Watch[1].WatchSettings} {
+		if !reflect.DeepEqual(s.Ignore, []string{"*.bak", "*.tmp"}) || !reflect.DeepEqual(s.ClipboardBackends, []string{"wayland", "x11"}) || s.Command.Env["DISPLAY"] != ":0" {
+			t.Errorf("got Ignore=%v ClipboardBackends=%v Env=%v, want the top-level ones", s.Ignore, s.ClipboardBackends, s.Command.Env)
+		}
+	}
+}
+
+func TestLoadConfig_DirectionDefaultsToFileToClipboard(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	err := os.WriteFile(configPath, []byte(`watch_file = "/tmp/clipboard.txt"`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.Direction != DirectionFileToClipboard {
+		t.Errorf("got Direction=%q, want %q", cfg.Direction, DirectionFileToClipboard)
+	}
+	if cfg.Selection != SelectionClipboard {
+		t.Errorf("got Selection=%q, want %q", cfg.Selection, SelectionClipboard)
+	}
+}
+
+func TestLoadConfig_ReadsSelection(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `selection = "both"
+
+[[watch]]
+path = "/tmp/notes.txt"
+selection = "primary"`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.Selection != SelectionBoth {
+		t.Errorf("got Selection=%q, want %q", cfg.Selection, SelectionBoth)
+	}
+	if len(cfg.Watch) != 1 || cfg.Watch[0].Selection != SelectionPrimary {
+		t.Errorf("got watches %+v, want one with Selection=%q", cfg.Watch, SelectionPrimary)
+	}
+}
+
+func TestLoadConfig_ReadsDirection(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `watch_file = "/tmp/clipboard.txt"
+direction = "both"
+clipboard_poll_interval = "250ms"`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.Direction != DirectionBoth {
+		t.Errorf("got Direction=%q, want %q", cfg.Direction, DirectionBoth)
+	}
+	if cfg.ClipboardPollInterval != 250*time.Millisecond {
+		t.Errorf("got ClipboardPollInterval=%v, want %v", cfg.ClipboardPollInterval, 250*time.Millisecond)
+	}
+}
+
+func TestLoadConfig_ReadsClipboardTimeout(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `clipboard_timeout = "2s"
+
+[[watch]]
+path = "/tmp/a.txt"
+
+[[watch]]
+path = "/tmp/b.txt"
+clipboard_timeout = "500ms"`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.ClipboardTimeout != 2*time.Second {
+		t.Errorf("got ClipboardTimeout=%v, want %v", cfg.ClipboardTimeout, 2*time.Second)
+	}
+	if len(cfg.Watch) != 2 || cfg.Watch[0].ClipboardTimeout != 2*time.Second || cfg.Watch[1].ClipboardTimeout != 500*time.Millisecond {
+		t.Errorf("got watches %+v, want timeouts of 2s and 500ms", cfg.Watch)
+	}
+}
+
+func TestLoadConfig_ReadsMIMETypeAndRepresentations(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `[[watch]]
+path = "/tmp/a.txt"
+
+[[watch]]
+path = "/tmp/screenshot"
+mime_type = "image/png"
+
+[[watch]]
+path = "/tmp/notes.md"
+representations = ["text/html"]`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if len(cfg.Watch) != 3 || cfg.Watch[0].MIMEType != MIMETypeAuto || cfg.Watch[1].MIMEType != "image/png" {
+		t.Errorf("got watches %+v, want MIME types auto and image/png", cfg.Watch)
+	}
+	if len(cfg.Watch) == 3 && (len(cfg.Watch[2].Representations) != 1 || cfg.Watch[2].Representations[0] != MIMETypeHTML) {
+		t.Errorf("got representations %v, want [text/html]", cfg.Watch[2].Representations)
+	}
+}
+
+func TestLoadConfig_ReadsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `[secrets]
+action = "redact"
+patterns = ["corp_[a-z0-9]{32}"]
+
+[[watch]]
+path = "/tmp/a.txt"
+
+[[watch]]
+path = "/tmp/b.txt"
+
+[watch.secrets]
+action = "block"
+patterns = ["internal_[0-9]+"]`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	inherited := SecretsConfig{Action: SecretActionRedact, Patterns: []string{"corp_[a-z0-9]{32}"}}
+	if !reflect.DeepEqual(cfg.Secrets, inherited) {
+		t.Errorf("got Secrets=%+v, want %+v", cfg.Secrets, inherited)
+	}
+	if len(cfg.Watch) != 2 {
+		t.Fatalf("got %d watches, want 2", len(cfg.Watch))
+	}
+	if !reflect.DeepEqual(cfg.Watch[0].Secrets, inherited) {
+		t.Errorf("got first watch secrets %+v, want the top-level ones", cfg.Watch[0].Secrets)
+	}
+	own := SecretsConfig{Action: SecretActionBlock, Patterns: []string{"internal_[0-9]+"}}
+	if !reflect.DeepEqual(cfg.Watch[1].Secrets, own) {
+		t.Errorf("got second watch secrets %+v, want %+v", cfg.Watch[1].Secrets, own)
+	}
+}
+
+func TestLoadConfig_ReadsTransforms(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `[[transform]]
+type = "strip-ansi"
+
+[[transform]]
+type = "trim-trailing-newline"
+
+[[watch]]
+path = "/tmp/a.txt"
+
+[[watch]]
+path = "/tmp/b.txt"
+
+[[watch.transform]]
+type = "regex-replace"
+pattern = "^\\$ "
+
+[[watch.transform]]
+type = "first-lines"
+lines = 3
+
+[[watch.transform]]
+type = "filter"
+command = ["jq", "."]
+timeout = "2s"
+max_size = 65536
+on_error = "raw"`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	inherited := []TransformConfig{{Type: TransformStripANSI}, {Type: TransformTrimTrailingNewline}}
+	if !reflect.DeepEqual(cfg.Transforms, inherited) {
+		t.Errorf("got Transforms=%+v, want %+v", cfg.Transforms, inherited)
+	}
+	if len(cfg.Watch) != 2 {
+		t.Fatalf("got %d watches, want 2", len(cfg.Watch))
+	}
+	if !reflect.DeepEqual(cfg.Watch[0].Transforms, inherited) {
+		t.Errorf("got first watch transforms %+v, want the top-level ones", cfg.Watch[0].Transforms)
+	}
+	own := []TransformConfig{
+		{Type: TransformRegexReplace, Pattern: `^\$ `},
+		{Type: TransformFirstLines, Lines: 3},
+		{Type: TransformFilter, Command: []string{"jq", "."}, Timeout: 2 * time.Second, MaxSize: 65536, OnError: FilterOnErrorRaw},
+	}
+	if !reflect.DeepEqual(cfg.Watch[1].Transforms, own) {
+		t.Errorf("got second watch transforms %+v, want %+v", cfg.Watch[1].Transforms, own)
+	}
+}
+
+func TestLoadConfig_ReadsRetry(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `[retry]
+max_attempts = 1`
+	err := os.WriteFile(configPath, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	want := RetryConfig{MaxAttempts: 1, InitialDelay: defaultRetryInitialDelay, MaxDelay: defaultRetryMaxDelay}
+	if cfg.Retry != want {
+		t.Errorf("got Retry=%+v, want %+v", cfg.Retry, want)
+	}
+}
+
+func TestLoadConfig_ReadsOSC52(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `clipboard_backend = "osc52"
+
+[osc52]
+tty = "/dev/pts/3"
+query = true
+
+[[watch]]
+path = "/tmp/notes.txt"
+
+[watch.osc52]
+passthrough = "screen"`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.OSC52.TTY != "/dev/pts/3" || !cfg.OSC52.Query || cfg.OSC52.Passthrough != OSC52PassthroughAuto {
+		t.Errorf("got OSC52=%+v", cfg.OSC52)
+	}
+	if cfg.OSC52.QueryTimeout != defaultOSC52QueryTimeout {
+		t.Errorf("got QueryTimeout=%v, want %v", cfg.OSC52.QueryTimeout, defaultOSC52QueryTimeout)
+	}
+	// A [watch.osc52] table only overrides the keys it sets
+	if len(cfg.Watch) != 1 {
+		t.Fatalf("got %d watches, want 1", len(cfg.Watch))
+	}
+	if got := cfg.Watch[0].OSC52; got.Passthrough != OSC52PassthroughScreen || got.TTY != "/dev/pts/3" {
+		t.Errorf("got watch OSC52=%+v", got)
+	}
+}
+
+func TestLoadConfig_ReadsClipboardBackends(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `clipboard_backend = "fallback"
+clipboard_backends = ["wayland-native", "x11"]`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.ClipboardBackend != "fallback" {
+		t.Errorf("got ClipboardBackend=%q, want %q", cfg.ClipboardBackend, "fallback")
+	}
+	if len(cfg.ClipboardBackends) != 2 || cfg.ClipboardBackends[0] != "wayland-native" || cfg.ClipboardBackends[1] != "x11" {
+		t.Errorf("got ClipboardBackends=%v, want [wayland-native x11]", cfg.ClipboardBackends)
+	}
+}
+
+func TestLoadConfig_ReadsCommand(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `clipboard_backend = "command"
+
+[command]
+read = ["powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"]
+write = ["clip.exe"]
+env = { WSLENV = "PATH/l" }`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if len(cfg.Command.Read) != 4 || cfg.Command.Read[3] != "Get-Clipboard" {
+		t.Errorf("got Command.Read=%v", cfg.Command.Read)
+	}
+	if len(cfg.Command.Write) != 1 || cfg.Command.Write[0] != "clip.exe" {
+		t.Errorf("got Command.Write=%v", cfg.Command.Write)
+	}
+	if cfg.Command.Env["WSLENV"] != "PATH/l" {
+		t.Errorf("got Command.Env=%v", cfg.Command.Env)
+	}
+	if cfg.Command.WriteInput != CommandInputStdin || cfg.Command.Timeout != defaultCommandTimeout {
+		t.Errorf("got WriteInput=%q Timeout=%v, want the defaults", cfg.Command.WriteInput, cfg.Command.Timeout)
+	}
+}
+
+func TestLoadConfig_ReadsMultiplexerBuffers(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `[tmux]
+buffer = "clipboard"
+
+[screen]
+session = "work"
+register = "c"`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.Tmux.Buffer != "clipboard" {
+		t.Errorf("got Tmux.Buffer=%q, want %q", cfg.Tmux.Buffer, "clipboard")
+	}
+	if cfg.Screen != (ScreenConfig{Session: "work", Register: "c"}) {
+		t.Errorf("got Screen=%+v", cfg.Screen)
+	}
+}
+
+func TestLoadConfig_HistoryDefaults(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(configPath, []byte(`watch_file = "/tmp/clipboard.txt"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.History.Enabled {
+		t.Error("expected history to be disabled by default")
+	}
+	if cfg.History.MaxEntries != defaultHistoryMaxEntries || cfg.History.MaxAge != defaultHistoryMaxAge {
+		t.Errorf("expected default limits, got %d entries and %v", cfg.History.MaxEntries, cfg.History.MaxAge)
+	}
+}
+
+func TestLoadConfig_ReadsHistory(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.toml")
+	content := `watch_file = "/tmp/clipboard.txt"
+
+[history]
+enabled = true
+path = "/tmp/history.jsonl"
+max_entries = 50
+max_age = "24h"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	want := HistoryConfig{Enabled: true, Path: "/tmp/history.jsonl", MaxEntries: 50, MaxAge: 24 * time.Hour}
+	if cfg.History != want {
+		t.Errorf("got History=%+v, want %+v", cfg.History, want)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// MIMETypeAuto picks the type from the watch file.
+	MIMETypeAuto = "auto"
+	// MIMETypeText is plain text, which backends put on the clipboard as
+	// every text type they know.
+	MIMETypeText = "text/plain"
+)
+
+// Content is what a clipboard holds: data of a MIME type, such as text or
+// a PNG image.
+type Content struct {
+	Type string
+	Data []byte
+	// Alternatives are other representations of the same content, such as
+	// HTML rendered from Markdown text, offered with it by backends that can
+	// hold several types at once. Others only write Type and Data.
+	Alternatives []Content
+}
+
+// TextContent returns plain text content.
+func TextContent(text string) Content {
+	return Content{Type: MIMETypeText, Data: []byte(text)}
+}
+
+// IsText reports whether the content is plain text.
+func (c Content) IsText() bool {
+	return isTextType(c.Type)
+}
+
+// representations returns the content followed by its alternatives.
+func (c Content) representations() []Content {
+	first := c
+	first.Alternatives = nil
+	return append([]Content{first}, c.Alternatives...)
+}
+
+// isTextType reports whether mimeType is plain text, with or without a
+// charset.
+func isTextType(mimeType string) bool {
+	base, _, _ := strings.Cut(mimeType, ";")
+	return strings.TrimSpace(base) == MIMETypeText
+}
+
+// isImageType reports whether mimeType is an image, as opposed to text of
+// any kind such as Markdown, JSON or HTML.
+func isImageType(mimeType string) bool {
+	return strings.HasPrefix(strings.TrimSpace(mimeType), "image/")
+}
+
+// contentType picks the MIME type of a watch file's content. Unless one is
+// configured, images are recognized from their data or else from the file
+// extension, and anything else is synced as text.
+func contentType(configured, path string, data []byte) string {
+	if configured != "" && configured != MIMETypeAuto {
+		return configured
+	}
+	if sniffed, _, _ := strings.Cut(http.DetectContentType(data), ";"); strings.HasPrefix(sniffed, "image/") {
+		return sniffed
+	}
+	return extensionType(path)
+}
+
+// watchType is the MIME type a watch reads from the clipboard, before there
+// is any file content to look at.
+func watchType(configured, path string) string {
+	if configured != "" && configured != MIMETypeAuto {
+		return configured
+	}
+	return extensionType(path)
+}
+
+// extensionType returns the image type a file extension stands for, and
+// plain text for any other.
+func extensionType(path string) string {
+	byExt, _, _ := strings.Cut(mime.TypeByExtension(strings.ToLower(filepath.Ext(path))), ";")
+	if strings.HasPrefix(byExt, "image/") {
+		return byExt
+	}
+	return MIMETypeText
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name       string
+		configured string
+		path       string
+		data       []byte
+		want       string
+	}{
+		{"text", MIMETypeAuto, "/tmp/notes.txt", []byte("hello"), MIMETypeText},
+		{"sniffed image", MIMETypeAuto, "/tmp/screenshot", png, "image/png"},
+		{"sniffed over extension", MIMETypeAuto, "/tmp/screenshot.jpg", png, "image/png"},
+		{"image extension", MIMETypeAuto, "/tmp/shot.PNG", []byte("not yet written"), "image/png"},
+		{"other extension", MIMETypeAuto, "/tmp/page.html", []byte("<html>"), MIMETypeText},
+		{"unset", "", "/tmp/notes.txt", png, "image/png"},
+		{"configured", "text/html", "/tmp/notes.txt", png, "text/html"},
+	}
+	for _, tt := range tests {
+		if got := contentType(tt.configured, tt.path, tt.data); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestWatchType(t *testing.T) {
+	if got := watchType(MIMETypeAuto, "/tmp/shot.png"); got != "image/png" {
+		t.Errorf("expected image/png for a .png file, got %q", got)
+	}
+	if got := watchType(MIMETypeAuto, "/tmp/notes.md"); got != MIMETypeText {
+		t.Errorf("expected text for a .md file, got %q", got)
+	}
+	if got := watchType("image/jpeg", "/tmp/notes.md"); got != "image/jpeg" {
+		t.Errorf("expected the configured type, got %q", got)
+	}
+}
+
+func TestIsTextType(t *testing.T) {
+	for mimeType, want := range map[string]bool{
+		"text/plain":                true,
+		"text/plain;charset=utf-8":  true,
+		"text/plain; charset=utf-8": true,
+		"text/html":                 false,
+		"image/png":                 false,
+		"":                          false,
+	} {
+		if got := isTextType(mimeType); got != want {
+			t.Errorf("%q: expected %v, got %v", mimeType, want, got)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"os/exec"
+)
+
+// commandContext prepares a command that is killed when ctx is done.
+func commandContext(ctx context.Context, cmd string, args ...string) *exec.Cmd {
+	c := exec.CommandContext(ctx, cmd, args...)
+	c.WaitDelay = commandWaitDelay
+	return c
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// commandContext prepares a command that is killed when ctx is done. It runs
+// in a process group of its own and the whole group is killed, so helpers it
+// started don't keep a timed out command's output open.
+func commandContext(ctx context.Context, cmd string, args ...string) *exec.Cmd {
+	c := exec.CommandContext(ctx, cmd, args...)
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Cancel = func() error {
+		return syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+	}
+	c.WaitDelay = commandWaitDelay
+	return c
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestDefaultExec_KillsProcessGroup(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("no sleep")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The background sleep inherits stdout, which only closes when it dies too
+	start := time.Now()
+	_, err := defaultExec(ctx, "sh", "-c", "sleep 10 & sleep 10")
+	if err == nil {
+		t.Fatal("expected the command to be killed")
+	}
+	if elapsed := time.Since(start); elapsed >= commandWaitDelay {
+		t.Errorf("expected the whole process group to be killed, took %v", elapsed)
+	}
+}
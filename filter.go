@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFilterTimeout = 5 * time.Second
+	defaultFilterMaxSize = 10 << 20
+)
+
+// What a filter that fails does.
+const (
+	// FilterOnErrorSkip leaves the clipboard as it is, so content a filter
+	// was meant to redact never gets there unfiltered.
+	FilterOnErrorSkip = "skip"
+	// FilterOnErrorRaw syncs the text the filter was given.
+	FilterOnErrorRaw = "raw"
+)
+
+// errFilterOutputTooLarge is a filter that printed more than its max_size.
+var errFilterOutputTooLarge = errors.New("output too large")
+
+// filterError is a filter that failed, and whether the text it was given is
+// synced anyway.
+type filterError struct {
+	syncRaw bool
+	err     error
+}
+
+func (e *filterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *filterError) Unwrap() error {
+	return e.err
+}
+
+// syncRaw reports whether err is a failed filter whose input should be
+// synced instead of skipping the update.
+func syncRaw(err error) bool {
+	var fe *filterError
+	return errors.As(err, &fe) && fe.syncRaw
+}
+
+// filter pipes text through an external command, such as jq or a script
+// that redacts secrets.
+type filter struct {
+	command []string
+	timeout time.Duration
+	maxSize int
+	syncRaw bool
+
+	execFilter CommandFilterExecutor
+}
+
+func newFilter(cfg TransformConfig) (*filter, error) {
+	if len(cfg.Command) == 0 || cfg.Command[0] == "" {
+		return nil, errors.New("filter: no command")
+	}
+	f := &filter{command: cfg.Command, timeout: cfg.Timeout, maxSize: cfg.MaxSize}
+	if f.timeout <= 0 {
+		f.timeout = defaultFilterTimeout
+	}
+	if f.maxSize <= 0 {
+		f.maxSize = defaultFilterMaxSize
+	}
+	switch cfg.OnError {
+	case "", FilterOnErrorSkip:
+	case FilterOnErrorRaw:
+		f.syncRaw = true
+	default:
+		return nil, fmt.Errorf("filter: unknown on_error %q (use skip or raw)", cfg.OnError)
+	}
+	return f, nil
+}
+
+func (f *filter) apply(ctx context.Context, text string) (string, error) {
+	ctx, cancel := operationContext(ctx, "filter", f.command[0], f.timeout)
+	defer cancel()
+	executor := f.execFilter
+	if executor == nil {
+		executor = f.exec
+	}
+	out, err := executor(ctx, f.command[0], text, f.command[1:]...)
+	if err == nil && len(out) > f.maxSize {
+		err = f.tooLarge()
+	}
+	if err != nil {
+		return "", &filterError{syncRaw: f.syncRaw, err: contextError(ctx, err)}
+	}
+	return string(out), nil
+}
+
+func (f *filter) exec(ctx context.Context, cmd string, stdin string, args ...string) ([]byte, error) {
+	out := &cappedBuffer{max: f.maxSize}
+	command := commandContext(ctx, cmd, args...)
+	command.Stdin = strings.NewReader(stdin)
+	command.Stdout = out
+	err := runTool(command)
+	if out.exceeded {
+		return nil, f.tooLarge()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	return out.buf.Bytes(), nil
+}
+
+func (f *filter) tooLarge() error {
+	return fmt.Errorf("filter: %s: %w (over %d bytes)", f.command[0], errFilterOutputTooLarge, f.maxSize)
+}
+
+// cappedBuffer refuses to grow past max bytes. The command writing to it
+// then gets a broken pipe instead of filling memory. The buffer isn't
+// embedded, as io.Copy would use its ReadFrom and read past the cap.
+type cappedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	exceeded bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.max {
+		b.exceeded = true
+		return 0, errFilterOutputTooLarge
+	}
+	return b.buf.Write(p)
+}
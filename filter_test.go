@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilter_PipesTextThroughCommand(t *testing.T) {
+	var gotCmd, gotStdin string
+	var gotArgs []string
+
+	f, err := newFilter(TransformConfig{Type: TransformFilter, Command: []string{"jq", "-c", "."}})
+	if err != nil {
+		t.Fatalf("newFilter failed: %v", err)
+	}
+	f.execFilter = func(_ context.Context, cmd string, stdin string, args ...string) ([]byte, error) {
+		gotCmd, gotStdin, gotArgs = cmd, stdin, args
+		return []byte(`{"a":1}`), nil
+	}
+
+	out, err := f.apply(context.Background(), "{\n  \"a\": 1\n}\n")
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if gotCmd != "jq" || !reflect.DeepEqual(gotArgs, []string{"-c", "."}) {
+		t.Errorf("expected jq -c ., got %s %v", gotCmd, gotArgs)
+	}
+	if gotStdin != "{\n  \"a\": 1\n}\n" {
+		t.Errorf("expected the text on stdin, got %q", gotStdin)
+	}
+	if out != `{"a":1}` {
+		t.Errorf("expected %q, got %q", `{"a":1}`, out)
+	}
+}
+
+func TestFilter_OnError(t *testing.T) {
+	failing := func(context.Context, string, string, ...string) ([]byte, error) {
+		return nil, &CommandError{Cmd: "redact", Stderr: "bad input", ExitCode: 1, Err: errors.New("exit status 1")}
+	}
+	tests := []struct {
+		onError string
+		syncRaw bool
+	}{
+		{"", false},
+		{FilterOnErrorSkip, false},
+		{FilterOnErrorRaw, true},
+	}
+	for _, tt := range tests {
+		f, err := newFilter(TransformConfig{Type: TransformFilter, Command: []string{"redact"}, OnError: tt.onError})
+		if err != nil {
+			t.Fatalf("on_error %q: newFilter failed: %v", tt.onError, err)
+		}
+		f.execFilter = failing
+
+		_, err = f.apply(context.Background(), "text")
+		if err == nil {
+			t.Fatalf("on_error %q: expected an error", tt.onError)
+		}
+		if syncRaw(err) != tt.syncRaw {
+			t.Errorf("on_error %q: expected syncRaw %v, got %v", tt.onError, tt.syncRaw, syncRaw(err))
+		}
+		if !strings.Contains(err.Error(), "bad input") {
+			t.Errorf("on_error %q: expected the error to include stderr, got %q", tt.onError, err)
+		}
+	}
+}
+
+func TestFilter_RejectsOutputOverMaxSize(t *testing.T) {
+	f, err := newFilter(TransformConfig{Type: TransformFilter, Command: []string{"cat"}, MaxSize: 4})
+	if err != nil {
+		t.Fatalf("newFilter failed: %v", err)
+	}
+	f.execFilter = func(_ context.Context, _ string, stdin string, _ ...string) ([]byte, error) {
+		return []byte(stdin), nil
+	}
+
+	if out, err := f.apply(context.Background(), "four"); err != nil || out != "four" {
+		t.Errorf("expected %q, got %q, %v", "four", out, err)
+	}
+	if _, err := f.apply(context.Background(), "fives"); !errors.Is(err, errFilterOutputTooLarge) {
+		t.Errorf("expected an output too large error, got %v", err)
+	}
+}
+
+func TestFilter_RunsCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	f, err := newFilter(TransformConfig{Type: TransformFilter, Command: []string{"sh", "-c", "tr a-z A-Z"}})
+	if err != nil {
+		t.Fatalf("newFilter failed: %v", err)
+	}
+
+	out, err := f.apply(context.Background(), "quiet please\n")
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if out != "QUIET PLEASE\n" {
+		t.Errorf("expected %q, got %q", "QUIET PLEASE\n", out)
+	}
+
+	f.command = []string{"sh", "-c", "echo invalid JSON >&2; exit 2"}
+	var cmdErr *CommandError
+	if _, err := f.apply(context.Background(), "{"); !errors.As(err, &cmdErr) || cmdErr.ExitCode != 2 || cmdErr.Stderr != "invalid JSON" {
+		t.Errorf("expected a command error with stderr, got %v", err)
+	}
+}
+
+func TestFilter_StopsCommandThatPrintsTooMuch(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	f, err := newFilter(TransformConfig{Type: TransformFilter, Command: []string{"sh", "-c", "while :; do echo y; done"}, MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("newFilter failed: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := f.apply(context.Background(), ""); !errors.Is(err, errFilterOutputTooLarge) {
+		t.Errorf("expected an output too large error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the command to be stopped, took %v", elapsed)
+	}
+}
+
+func TestFilter_TimesOut(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("no sleep")
+	}
+	f, err := newFilter(TransformConfig{Type: TransformFilter, Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("newFilter failed: %v", err)
+	}
+
+	start := time.Now()
+	_, err = f.apply(context.Background(), "")
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.Backend != "filter" || timeout.Timeout != 50*time.Millisecond {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the command to be stopped, took %v", elapsed)
+	}
+}
@@ -73,11 +73,17 @@
             };
 
             clipboardBackend = lib.mkOption {
-              type = lib.types.enum [ "wayland" "x11" ];
+              type = lib.types.enum [ "auto" "wayland" "wayland-native" "x11" "x11-native" "osc52" "tmux" "screen" ];
               default = "wayland";
               description = "Clipboard backend to use";
             };
 
+            waitForFile = lib.mkOption {
+              type = lib.types.bool;
+              default = false;
+              description = "Wait for the watch file (and its parent directories) to appear instead of exiting";
+            };
+
             package = lib.mkOption {
               type = lib.types.package;
               default = mkPackage pkgs;
@@ -102,7 +108,7 @@
 
               Service = {
                 Type = "simple";
-                ExecStart = "${cfg.package}/bin/clipboard-txt-watcher --file ${cfg.watchFile} --backend ${cfg.clipboardBackend}";
+                ExecStart = "${cfg.package}/bin/clipboard-txt-watcher --file ${cfg.watchFile} --backend ${cfg.clipboardBackend}${lib.optionalString cfg.waitForFile " --wait"}";
                 Restart = "on-failure";
                 RestartSec = 5;
               };
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// Filesystems whose changes can originate on another machine, where the local
+// kernel never generates inotify events for them. virtiofs reports as FUSE.
+var remoteFilesystems = map[int64]string{
+	unix.NFS_SUPER_MAGIC:  "nfs",
+	unix.V9FS_MAGIC:       "9p",
+	unix.FUSE_SUPER_MAGIC: "fuse",
+	unix.CIFS_SUPER_MAGIC: "cifs",
+	unix.SMB_SUPER_MAGIC:  "smb",
+	unix.SMB2_SUPER_MAGIC: "smb2",
+	unix.CEPH_SUPER_MAGIC: "ceph",
+	unix.AFS_SUPER_MAGIC:  "afs",
+	unix.CODA_SUPER_MAGIC: "coda",
+}
+
+func remoteFilesystem(path string) (string, bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return "", false
+	}
+	name, ok := remoteFilesystems[int64(st.Type)] //nolint:unconvert // Statfs_t.Type is int32 on some architectures
+	return name, ok
+}
@@ -6,6 +6,5 @@ require (
 	github.com/BurntSushi/toml v1.3.2
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/spf13/pflag v1.0.10
+	golang.org/x/sys v0.13.0
 )
-
-require golang.org/x/sys v0.13.0 // indirect
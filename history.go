@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHistoryMaxEntries = 1000
+	defaultHistoryMaxAge     = 30 * 24 * time.Hour
+)
+
+type HistoryConfig struct {
+	Enabled    bool          `toml:"enabled"`
+	Path       string        `toml:"path"`
+	MaxEntries int           `toml:"max_entries"`
+	MaxAge     time.Duration `toml:"max_age"`
+}
+
+// Sources of a history entry.
+const (
+	HistorySourceFile      = "file"
+	HistorySourceClipboard = "clipboard"
+)
+
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Watch   string    `json:"watch,omitempty"`
+	Hash    string    `json:"hash"`
+	Content string    `json:"content"`
+}
+
+// History is an append-only log of synced clipboard contents, stored as one
+// JSON object per line. Entries beyond the configured count or age are
+// dropped in batches, once there are a tenth more than are kept, so most
+// adds only append a line. Only the newest entry and the file's size are
+// kept in memory; the file is read again when another process changed it.
+type History struct {
+	path       string
+	maxEntries int
+	maxAge     time.Duration
+	now        func() time.Time
+
+	mu sync.Mutex
+	// What is known about the file, as of when it had this size and time
+	known   bool
+	size    int64
+	modTime time.Time
+	count   int
+	oldest  time.Time
+	last    string
+	// broken is set when the file ends in a partial line
+	broken bool
+}
+
+func NewHistory(cfg HistoryConfig) (*History, error) {
+	path := cfg.Path
+	if path == "" {
+		var err error
+		path, err = defaultHistoryPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &History{
+		path:       path,
+		maxEntries: cfg.MaxEntries,
+		maxAge:     cfg.MaxAge,
+		now:        time.Now,
+	}, nil
+}
+
+func defaultHistoryPath() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dataHome, "clipboard-txt-watcher", "history.jsonl"), nil
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Add records content, unless it is the same as the most recent entry. A nil
+// History records nothing, so callers don't need to check whether history is
+// enabled.
+func (h *History) Add(source, watch, content string) error {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(h.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := h.refresh(); err != nil {
+		return err
+	}
+
+	now := h.now()
+	entry := HistoryEntry{
+		Time:    now,
+		Source:  source,
+		Watch:   watch,
+		Hash:    contentHash(content),
+		Content: content,
+	}
+	if h.count > 0 && h.last == entry.Hash {
+		return nil
+	}
+	if err := h.append(entry); err != nil {
+		h.known = false
+		return err
+	}
+	if h.count == 0 {
+		h.oldest = now
+	}
+	h.count++
+	h.last = entry.Hash
+
+	if !h.overdue(now) {
+		return nil
+	}
+	entries, err := h.load()
+	if err != nil {
+		return err
+	}
+	return h.rewrite(h.prune(entries, now))
+}
+
+// overdue reports whether enough entries are past the limits to prune them.
+func (h *History) overdue(now time.Time) bool {
+	if h.maxEntries > 0 && h.count > h.maxEntries+pruneBatch(h.maxEntries) {
+		return true
+	}
+	return h.maxAge > 0 && now.Sub(h.oldest) > h.maxAge+h.maxAge/10
+}
+
+// pruneBatch is how many entries past the maximum are kept before pruning.
+func pruneBatch(maxEntries int) int {
+	return maxEntries / 10
+}
+
+// refresh reads what Add needs to know about the file, unless it hasn't
+// changed since.
+func (h *History) refresh() error {
+	info, err := os.Stat(h.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		h.known, h.size, h.modTime, h.count, h.last, h.broken = true, 0, time.Time{}, 0, "", false
+		return nil
+	case err != nil:
+		return err
+	case h.known && info.Size() == h.size && info.ModTime().Equal(h.modTime):
+		return nil
+	}
+
+	entries, err := h.load()
+	if err != nil {
+		return err
+	}
+	h.count, h.last = len(entries), ""
+	if len(entries) > 0 {
+		h.oldest, h.last = entries[0].Time, entries[len(entries)-1].Hash
+	}
+	h.broken = false
+	if info.Size() > 0 {
+		f, err := os.Open(h.path)
+		if err != nil {
+			return err
+		}
+		end := make([]byte, 1)
+		_, err = f.ReadAt(end, info.Size()-1)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		h.broken = end[0] != '\n'
+	}
+	return h.remember()
+}
+
+// remember notes the file's current size and time, which tell whether
+// another process changed it.
+func (h *History) remember() error {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+	h.known, h.size, h.modTime = true, info.Size(), info.ModTime()
+	return nil
+}
+
+// prune drops entries that are too old, then the oldest ones beyond the
+// maximum count.
+func (h *History) prune(entries []HistoryEntry, now time.Time) []HistoryEntry {
+	if h.maxAge > 0 {
+		cutoff := now.Add(-h.maxAge)
+		first := 0
+		for first < len(entries) && entries[first].Time.Before(cutoff) {
+			first++
+		}
+		entries = entries[first:]
+	}
+	if h.maxEntries > 0 && len(entries) > h.maxEntries {
+		entries = entries[len(entries)-h.maxEntries:]
+	}
+	return entries
+}
+
+func (h *History) append(entry HistoryEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if h.broken {
+		// End the partial line, so it doesn't take the entry with it
+		line = append([]byte{'\n'}, line...)
+	}
+
+	// Clipboard contents can be sensitive, so the log is private
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	h.broken = false
+	return h.remember()
+}
+
+func (h *History) rewrite(entries []HistoryEntry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return err
+		}
+	}
+	// Only reached when pruning dropped existing entries, so the file exists
+	// and writeFileAtomic keeps its private permissions.
+	if err := writeFileAtomic(h.path, buf.Bytes()); err != nil {
+		h.known = false
+		return err
+	}
+	h.count = len(entries)
+	if len(entries) > 0 {
+		h.oldest = entries[0].Time
+	}
+	return h.remember()
+}
+
+// load reads every entry, oldest first. Lines that can't be parsed, such as a
+// line cut short by a crash, are skipped.
+func (h *History) load() ([]HistoryEntry, error) {
+	f, err := os.Open(h.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var entries []HistoryEntry
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry HistoryEntry
+			if json.Unmarshal(line, &entry) == nil {
+				entries = append(entries, entry)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// List returns the entries newest first, so entry N is List()[N-1]. Entries
+// past the limits that haven't been pruned yet are left out.
+func (h *History) List() ([]HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries, err := h.load()
+	if err != nil {
+		return nil, err
+	}
+	entries = h.prune(entries, h.now())
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Get returns entry n, counting from 1 for the most recent one.
+func (h *History) Get(n int) (HistoryEntry, error) {
+	entries, err := h.List()
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	if n < 1 || n > len(entries) {
+		return HistoryEntry{}, fmt.Errorf("no history entry %d (have %d)", n, len(entries))
+	}
+	return entries[n-1], nil
+}
+
+// runHistoryCommand implements the history subcommands: list, show N and
+// restore N. restore puts content back on the clipboard.
+func runHistoryCommand(args []string, h *History, restore func(content string) error, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: history list | history show N | history restore N")
+	}
+
+	switch args[0] {
+	case "list":
+		entries, err := h.List()
+		if err != nil {
+			return err
+		}
+		for i, entry := range entries {
+			_, err := fmt.Fprintf(out, "%4d  %s  %-9s  %s\n", i+1, entry.Time.Local().Format(time.DateTime), entry.Source, preview(entry.Content, 60))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "show", "restore":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: history %s N", args[0])
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid history entry %q: %w", args[1], err)
+		}
+		entry, err := h.Get(n)
+		if err != nil {
+			return err
+		}
+		if args[0] == "show" {
+			_, err := io.WriteString(out, entry.Content)
+			return err
+		}
+		return restore(entry.Content)
+	default:
+		return fmt.Errorf("unknown history command %q (use list, show or restore)", args[0])
+	}
+}
+
+// preview squashes content onto one line of at most width runes.
+func preview(content string, width int) string {
+	line := strings.Join(strings.Fields(content), " ")
+	runes := []rune(line)
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return line
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHistory returns a history in a temp dir whose clock advances by a
+// minute on every call.
+func newTestHistory(t *testing.T, cfg HistoryConfig) *History {
+	t.Helper()
+	cfg.Path = filepath.Join(t.TempDir(), "history", "history.jsonl")
+	h, err := NewHistory(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	return h
+}
+
+func addAll(t *testing.T, h *History, contents ...string) {
+	t.Helper()
+	for _, content := range contents {
+		if err := h.Add(HistorySourceFile, "notes", content); err != nil {
+			t.Fatalf("Add(%q) failed: %v", content, err)
+		}
+	}
+}
+
+func historyContents(t *testing.T, h *History) []string {
+	t.Helper()
+	entries, err := h.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make([]string, 0, len(entries))
+	for i := range entries {
+		contents = append(contents, entries[i].Content)
+	}
+	return contents
+}
+
+func TestHistory_ListsNewestFirst(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{})
+	addAll(t, h, "first", "second", "third")
+
+	if got := strings.Join(historyContents(t, h), ","); got != "third,second,first" {
+		t.Errorf("expected third,second,first, got %s", got)
+	}
+
+	entry, err := h.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Source != HistorySourceFile || entry.Watch != "notes" || entry.Hash != contentHash("third") {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+}
+
+func TestHistory_SkipsRepeatedContent(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{})
+	addAll(t, h, "a", "a", "b", "a")
+
+	if got := strings.Join(historyContents(t, h), ","); got != "a,b,a" {
+		t.Errorf("expected a,b,a, got %s", got)
+	}
+}
+
+func TestHistory_PrunesByCount(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{MaxEntries: 2})
+	addAll(t, h, "a", "b", "c")
+
+	if got := strings.Join(historyContents(t, h), ","); got != "c,b" {
+		t.Errorf("expected c,b, got %s", got)
+	}
+}
+
+func TestHistory_PrunesByAge(t *testing.T) {
+	// Each Add, and the List, moves the clock on by a minute
+	h := newTestHistory(t, HistoryConfig{MaxAge: 150 * time.Second})
+	addAll(t, h, "a", "b", "c")
+
+	if got := strings.Join(historyContents(t, h), ","); got != "c,b" {
+		t.Errorf("expected c,b, got %s", got)
+	}
+}
+
+func TestHistory_PrunesInBatches(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{MaxEntries: 10})
+	for i := 0; i < 11; i++ {
+		addAll(t, h, strconv.Itoa(i))
+	}
+	if lines := historyLines(t, h); lines != 11 {
+		t.Errorf("expected entries to be appended up to a tenth past the maximum, got %d lines", lines)
+	}
+	if got := len(historyContents(t, h)); got != 10 {
+		t.Errorf("expected 10 entries listed, got %d", got)
+	}
+
+	addAll(t, h, "11")
+	if lines := historyLines(t, h); lines != 10 {
+		t.Errorf("expected the file to be pruned to 10 lines, got %d", lines)
+	}
+	if got := historyContents(t, h); got[0] != "11" || got[9] != "2" {
+		t.Errorf("expected entries 11 to 2, got %v", got)
+	}
+}
+
+func TestHistory_SeesEntriesOfOtherProcesses(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{})
+	other, err := NewHistory(HistoryConfig{Path: h.path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addAll(t, h, "a")
+	addAll(t, other, "b")
+	// Same as the newest entry, which h only knows from reading the file
+	addAll(t, h, "b", "c")
+
+	if got := strings.Join(historyContents(t, h), ","); got != "c,b,a" {
+		t.Errorf("expected c,b,a, got %s", got)
+	}
+}
+
+func historyLines(t *testing.T, h *History) int {
+	t.Helper()
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestHistory_FileIsPrivate(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{MaxEntries: 1})
+	addAll(t, h, "secret")
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected permissions 0600, got %o", perm)
+	}
+
+	// Pruning rewrites the file, which must keep it private
+	addAll(t, h, "another secret")
+	info, err = os.Stat(h.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected permissions 0600 after pruning, got %o", perm)
+	}
+}
+
+func TestHistory_SkipsCorruptLines(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{})
+	addAll(t, h, "first")
+
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"time":"2024-01-01T`); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(historyContents(t, h), ","); got != "first" {
+		t.Errorf("expected only the valid entry, got %s", got)
+	}
+
+	// An entry added after the partial line isn't lost with it
+	addAll(t, h, "second")
+	if got := strings.Join(historyContents(t, h), ","); got != "second,first" {
+		t.Errorf("expected second,first, got %s", got)
+	}
+}
+
+func TestHistory_Get_OutOfRange(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{})
+	addAll(t, h, "only")
+
+	for _, n := range []int{0, 2} {
+		if _, err := h.Get(n); err == nil {
+			t.Errorf("expected an error for entry %d", n)
+		}
+	}
+}
+
+func TestHistory_NilRecordsNothing(t *testing.T) {
+	var h *History
+	if err := h.Add(HistorySourceFile, "", "content"); err != nil {
+		t.Errorf("expected nil history to ignore Add, got %v", err)
+	}
+}
+
+func TestRunHistoryCommand_List(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{})
+	addAll(t, h, "first line\nsecond line", "newer")
+
+	var out bytes.Buffer
+	if err := runHistoryCommand([]string{"list"}, h, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", out.String())
+	}
+	if !strings.HasPrefix(lines[0], "   1  ") || !strings.HasSuffix(lines[0], "newer") {
+		t.Errorf("unexpected first line %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "first line second line") {
+		t.Errorf("unexpected second line %q", lines[1])
+	}
+}
+
+func TestRunHistoryCommand_Show(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{})
+	addAll(t, h, "older\n", "newer")
+
+	var out bytes.Buffer
+	if err := runHistoryCommand([]string{"show", "2"}, h, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "older\n" {
+		t.Errorf("expected %q, got %q", "older\n", out.String())
+	}
+}
+
+func TestRunHistoryCommand_Restore(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{})
+	addAll(t, h, "older", "newer")
+
+	cb := newRecordingClipboard("")
+	restore := func(content string) error {
+		return cb.Write(context.Background(), SelectionClipboard, TextContent(content))
+	}
+	if err := runHistoryCommand([]string{"restore", "2"}, h, restore, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); string(content) != "older" {
+		t.Errorf("expected clipboard to be %q, got %q", "older", content)
+	}
+}
+
+func TestRunHistoryCommand_Errors(t *testing.T) {
+	h := newTestHistory(t, HistoryConfig{})
+	addAll(t, h, "only")
+
+	for _, args := range [][]string{nil, {"frobnicate"}, {"show"}, {"show", "x"}, {"restore", "5"}} {
+		if err := runHistoryCommand(args, h, nil, &bytes.Buffer{}); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	if got := preview("  a\n\tb  ", 10); got != "a b" {
+		t.Errorf("expected %q, got %q", "a b", got)
+	}
+	if got := preview("héllo world", 5); got != "héll…" {
+		t.Errorf("expected %q, got %q", "héll…", got)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -21,8 +23,16 @@ func main() {
 		return
 	}
 
-	// Load config from file if it exists
-	var cfg *Config
+	cfg := loadConfig(opts)
+	if len(opts.Args) > 0 {
+		runCommand(opts, cfg)
+		return
+	}
+	runWatches(opts, cfg)
+}
+
+// loadConfig loads the config file if it exists, or else the defaults.
+func loadConfig(opts *CLIOptions) *Config {
 	cfgPath := opts.ConfigPath
 	if cfgPath == "" {
 		homeDir, err := os.UserHomeDir()
@@ -32,42 +42,56 @@ func main() {
 		cfgPath = filepath.Join(homeDir, ".config", "clipboard-txt-watcher", "config.toml")
 	}
 
-	if loadedCfg, err := LoadConfig(cfgPath); err == nil {
-		cfg = loadedCfg
-	} else {
-		cfg = &Config{ClipboardBackend: "wayland"}
+	if cfg, err := LoadConfig(cfgPath); err == nil {
+		return cfg
 	}
+	return DefaultConfig()
+}
 
-	// CLI flags override config file
-	if opts.WatchFile != "" {
-		cfg.WatchFile = opts.WatchFile
+// runWatches runs every configured watch until interrupted.
+func runWatches(opts *CLIOptions, cfg *Config) {
+	var history *History
+	if cfg.History.Enabled {
+		var err error
+		history, err = NewHistory(cfg.History)
+		if err != nil {
+			log.Fatalf("Failed to open history: %v", err)
+		}
 	}
-	if opts.ClipboardBackend != "" {
-		cfg.ClipboardBackend = opts.ClipboardBackend
+
+	// A file given on the command line replaces whatever the config watches
+	watches := cfg.Watches()
+	if opts.WatchFile != "" {
+		watches = []WatchConfig{{Path: opts.WatchFile, WatchSettings: cfg.WatchSettings}}
 	}
 
-	if cfg.WatchFile == "" {
+	if len(watches) == 0 {
 		log.Fatal("No watch file specified. Use --file or config file.")
 	}
 
-	log.Printf("Watching file: %s", cfg.WatchFile)
-	log.Printf("Clipboard backend: %s", cfg.ClipboardBackend)
+	var running []Watcher
+	for i := range watches {
+		wc := &watches[i]
 
-	// Create clipboard
-	cb := NewClipboard(cfg.ClipboardBackend)
+		// CLI flags override config file
+		opts.ApplyTo(&wc.WatchSettings)
 
-	// Create watcher
-	w, err := NewWatcher(cfg.WatchFile, func(content string) {
-		if err := SyncToClipboard(cb, content); err != nil {
-			log.Printf("Failed to sync clipboard: %v", err)
-		} else {
-			log.Printf("Clipboard updated from file")
+		logger := log.Default()
+		if len(watches) > 1 {
+			logger = log.New(log.Writer(), "["+wc.Label()+"] ", log.Flags())
 		}
-	})
-	if err != nil {
-		log.Fatalf("Failed to create watcher: %v", err)
+		if w, ok := runWatch(wc, history, logger); ok {
+			running = append(running, w)
+		}
+	}
+	if len(running) == 0 {
+		log.Fatal("No watches could be started")
 	}
-	defer func() { _ = w.Close() }()
+	defer func() {
+		for _, w := range running {
+			_ = w.Close()
+		}
+	}()
 
 	// Wait for interrupt signal
 	sigCh := make(chan os.Signal, 1)
@@ -76,3 +100,89 @@ func main() {
 
 	log.Println("Shutting down...")
 }
+
+// runWatch creates the clipboard of a watch and starts it, logging why if
+// it can't.
+func runWatch(wc *WatchConfig, history *History, logger *log.Logger) (Watcher, bool) {
+	if err := resolveBackend(&wc.WatchSettings, logger); err != nil {
+		logger.Printf("Failed to create clipboard: %v", err)
+		return nil, false
+	}
+	cb, err := clipboardFor(wc.WatchSettings)
+	if err != nil {
+		logger.Printf("Failed to create clipboard: %v", err)
+		return nil, false
+	}
+
+	w, err := startWatch(*wc, cb, history, logger)
+	if err != nil {
+		logger.Printf("Failed to create watcher: %v", err)
+		if c, ok := cb.(io.Closer); ok {
+			_ = c.Close()
+		}
+		return nil, false
+	}
+	// Closed once the watch no longer uses it, which removes the screen
+	// exchange files and drops the native connections
+	if c, ok := cb.(io.Closer); ok {
+		w = watchGroup{w, c}
+	}
+	return w, true
+}
+
+func runCommand(opts *CLIOptions, cfg *Config) {
+	switch opts.Args[0] {
+	case "history":
+		history, err := NewHistory(cfg.History)
+		if err != nil {
+			log.Fatalf("Failed to open history: %v", err)
+		}
+		restore := func(content string) error {
+			return restoreEntry(opts, cfg, content)
+		}
+		if err := runHistoryCommand(opts.Args[1:], history, restore, os.Stdout); err != nil {
+			log.Fatalf("history: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown command %q", opts.Args[0])
+	}
+}
+
+// restoreEntry puts a history entry back on the clipboard.
+func restoreEntry(opts *CLIOptions, cfg *Config, content string) error {
+	settings := cfg.WatchSettings
+	opts.ApplyTo(&settings)
+	selections, err := settings.Selection.expand()
+	if err != nil {
+		return err
+	}
+	if err := resolveBackend(&settings, log.Default()); err != nil {
+		return err
+	}
+	cb, err := clipboardFor(settings)
+	if err != nil {
+		return err
+	}
+	if c, ok := cb.(io.Closer); ok {
+		defer func() { _ = c.Close() }()
+	}
+	for _, selection := range selections {
+		if err := cb.Write(context.Background(), selection, TextContent(content)); err != nil {
+			return err
+		}
+	}
+
+	// The native backends serve the clipboard from this process, so
+	// exiting would take the restored entry with it
+	h, ok := cb.(Holder)
+	if !ok || !holds(cb) {
+		return nil
+	}
+	log.Printf("Keeping the entry on the clipboard until something else is copied")
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := h.Hold(ctx); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdHeading     = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	mdRule        = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdBullet      = regexp.MustCompile(`^ {0,3}[-*+](?:[ \t]+|$)`)
+	mdOrdered     = regexp.MustCompile(`^ {0,3}\d{1,9}[.)](?:[ \t]+|$)`)
+	mdFence       = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([^ \t`]*)")
+	mdQuote       = regexp.MustCompile(`^ {0,3}> ?`)
+	mdIndentation = regexp.MustCompile(`^(?: {1,4}|\t)`)
+)
+
+// markdownEscapable are the characters a backslash keeps as they are.
+const markdownEscapable = "\\`*_{}[]()#+-.!<>|~"
+
+// markdownToHTML renders the part of Markdown that notes tend to use:
+// headings, paragraphs, nested lists, block quotes, fenced code, rules,
+// emphasis, code spans and links. Anything else is kept as text.
+func markdownToHTML(src string) string {
+	var b strings.Builder
+	renderMarkdownBlocks(&b, strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n"))
+	return b.String()
+}
+
+func renderMarkdownBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case mdFence.MatchString(line):
+			i = renderMarkdownFence(b, lines, i)
+		case mdHeading.MatchString(strings.TrimLeft(line, " ")):
+			m := mdHeading.FindStringSubmatch(strings.TrimLeft(line, " "))
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">" + renderMarkdownInline(m[2]) + "</h" + level + ">\n")
+			i++
+		case mdRule.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case mdQuote.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && mdQuote.MatchString(lines[i]); i++ {
+				quoted = append(quoted, mdQuote.ReplaceAllString(lines[i], ""))
+			}
+			b.WriteString("<blockquote>\n")
+			renderMarkdownBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+		case mdBullet.MatchString(line):
+			i = renderMarkdownList(b, lines, i, mdBullet, "ul")
+		case mdOrdered.MatchString(line):
+			i = renderMarkdownList(b, lines, i, mdOrdered, "ol")
+		default:
+			start := i
+			for i++; i < len(lines) && !startsMarkdownBlock(lines[i]); i++ {
+			}
+			b.WriteString("<p>" + renderMarkdownParagraph(lines[start:i]) + "</p>\n")
+		}
+	}
+}
+
+// startsMarkdownBlock reports whether line ends the paragraph before it.
+func startsMarkdownBlock(line string) bool {
+	return strings.TrimSpace(line) == "" ||
+		mdFence.MatchString(line) ||
+		mdHeading.MatchString(strings.TrimLeft(line, " ")) ||
+		mdRule.MatchString(line) ||
+		mdQuote.MatchString(line) ||
+		mdBullet.MatchString(line) ||
+		mdOrdered.MatchString(line)
+}
+
+// renderMarkdownFence renders the code block opened at lines[i] and returns
+// the index of the line after it. An unclosed block runs to the end.
+func renderMarkdownFence(b *strings.Builder, lines []string, i int) int {
+	m := mdFence.FindStringSubmatch(lines[i])
+	fence, lang := m[1], m[2]
+	if lang != "" {
+		b.WriteString(`<pre><code class="language-` + html.EscapeString(lang) + `">`)
+	} else {
+		b.WriteString("<pre><code>")
+	}
+	for i++; i < len(lines); i++ {
+		if trimmed := strings.TrimSpace(lines[i]); strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		b.WriteString(html.EscapeString(lines[i]) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+// renderMarkdownList renders the list whose first item is at lines[i] and
+// returns the index of the line after it.
+func renderMarkdownList(b *strings.Builder, lines []string, i int, marker *regexp.Regexp, tag string) int {
+	b.WriteString("<" + tag + ">\n")
+	for i < len(lines) && marker.MatchString(lines[i]) {
+		var item []string
+		item, i = markdownListItem(lines, i, marker)
+		renderMarkdownListItem(b, item)
+		for i < len(lines) && strings.TrimSpace(lines[i]) == "" && i+1 < len(lines) && marker.MatchString(lines[i+1]) {
+			i++
+		}
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// markdownListItem returns the lines of the list item at lines[i], without
+// its marker and indentation, and the index of the line after it. Lines
+// indented below an item belong to it, and may hold a nested list.
+func markdownListItem(lines []string, i int, marker *regexp.Regexp) ([]string, int) {
+	item := []string{marker.ReplaceAllString(lines[i], "")}
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			// A blank line only continues the item if indented text follows
+			if i+1 < len(lines) && mdIndentation.MatchString(lines[i+1]) && strings.TrimSpace(lines[i+1]) != "" {
+				item = append(item, "")
+				continue
+			}
+			break
+		}
+		if mdIndentation.MatchString(line) {
+			item = append(item, mdIndentation.ReplaceAllString(line, ""))
+			continue
+		}
+		if startsMarkdownBlock(line) {
+			break
+		}
+		item = append(item, line)
+	}
+	return item, i
+}
+
+// renderMarkdownListItem renders the item's own text, then any blocks
+// nested in it.
+func renderMarkdownListItem(b *strings.Builder, item []string) {
+	text := 1
+	for text < len(item) && !startsMarkdownBlock(item[text]) {
+		text++
+	}
+	b.WriteString("<li>" + renderMarkdownParagraph(item[:text]))
+	if text < len(item) {
+		b.WriteString("\n")
+		renderMarkdownBlocks(b, item[text:])
+	}
+	b.WriteString("</li>\n")
+}
+
+func renderMarkdownParagraph(lines []string) string {
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return renderMarkdownInline(strings.Join(lines, "\n"))
+}
+
+// renderMarkdownInline renders emphasis, code spans, links and backslash
+// escapes, and escapes everything else for HTML.
+func renderMarkdownInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if n, ok := renderMarkdownSpan(&b, s, i); ok {
+			i += n
+			continue
+		}
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// renderMarkdownSpan renders the escape, code span, link or emphasis that
+// starts at s[i], if there is one, returning how many bytes it takes up.
+func renderMarkdownSpan(b *strings.Builder, s string, i int) (int, bool) {
+	switch s[i] {
+	case '\\':
+		if i+1 < len(s) && strings.IndexByte(markdownEscapable, s[i+1]) >= 0 {
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			return 2, true
+		}
+	case '`':
+		return renderMarkdownCode(b, s[i:]), true
+	case '[':
+		if text, url, n, ok := markdownLink(s[i:]); ok {
+			b.WriteString(`<a href="` + html.EscapeString(url) + `">` + renderMarkdownInline(text) + "</a>")
+			return n, true
+		}
+	case '<':
+		if end := strings.IndexByte(s[i:], '>'); end > 0 && strings.Contains(s[i+1:i+end], "://") && !strings.ContainsAny(s[i+1:i+end], " <") {
+			url := s[i+1 : i+end]
+			b.WriteString(`<a href="` + html.EscapeString(url) + `">` + html.EscapeString(url) + "</a>")
+			return end + 1, true
+		}
+	case '*', '_':
+		return renderMarkdownEmphasis(b, s, i)
+	}
+	return 0, false
+}
+
+// renderMarkdownCode renders the code span opened by the backticks at the
+// start of s, returning how many bytes it takes up. Backticks that are never
+// closed are kept as they are.
+func renderMarkdownCode(b *strings.Builder, s string) int {
+	run := len(s) - len(strings.TrimLeft(s, "`"))
+	delim := s[:run]
+	end := strings.Index(s[run:], delim)
+	if end < 0 {
+		b.WriteString(delim)
+		return run
+	}
+	b.WriteString("<code>" + html.EscapeString(strings.TrimSpace(s[run:run+end])) + "</code>")
+	return run + end + run
+}
+
+// markdownLink parses a [text](url) link at the start of s, returning how
+// many bytes it takes up.
+func markdownLink(s string) (text, url string, n int, ok bool) {
+	closing := strings.Index(s, "](")
+	if closing < 0 || strings.Contains(s[:closing], "\n") {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(s[closing+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	url = strings.TrimSpace(s[closing+2 : closing+2+end])
+	if url, _, _ = strings.Cut(url, " "); url == "" {
+		return "", "", 0, false
+	}
+	return s[1:closing], strings.Trim(url, "<>"), closing + 2 + end + 1, true
+}
+
+// renderMarkdownEmphasis renders the emphasis opened at s[i], returning how
+// many bytes it takes up.
+func renderMarkdownEmphasis(b *strings.Builder, s string, i int) (int, bool) {
+	delim, ok := openMarkdownEmphasis(s, i)
+	if !ok {
+		return 0, false
+	}
+	start := i + len(delim)
+	end := strings.Index(s[start:], delim)
+	for end >= 0 {
+		closeAt := start + end
+		after := closeAt + len(delim)
+		if end > 0 && closesMarkdownEmphasis(s, closeAt, delim) {
+			tag := "em"
+			if len(delim) == 2 {
+				tag = "strong"
+			}
+			b.WriteString("<" + tag + ">" + renderMarkdownInline(s[start:closeAt]) + "</" + tag + ">")
+			return after - i, true
+		}
+		next := strings.Index(s[after:], delim)
+		if next < 0 {
+			break
+		}
+		end = after - start + next
+	}
+	return 0, false
+}
+
+// openMarkdownEmphasis returns the delimiter of the emphasis opened at s[i],
+// if it opens one. Underscores inside words are left alone, as in
+// snake_case names.
+func openMarkdownEmphasis(s string, i int) (string, bool) {
+	delim := s[i : i+1]
+	if i+1 < len(s) && s[i+1] == s[i] {
+		delim = s[i : i+2]
+	}
+	if delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", false
+	}
+	start := i + len(delim)
+	if start >= len(s) || s[start] == ' ' || s[start] == '\n' {
+		return "", false
+	}
+	return delim, true
+}
+
+// closesMarkdownEmphasis reports whether the delim at s[closeAt] closes
+// emphasis.
+func closesMarkdownEmphasis(s string, closeAt int, delim string) bool {
+	after := closeAt + len(delim)
+	valid := s[closeAt-1] != ' ' && (delim[0] != '_' || after >= len(s) || !isWordByte(s[after]))
+	if len(delim) == 1 {
+		// Half of a ** or __ inside single emphasis doesn't close it
+		valid = valid && s[closeAt-1] != delim[0] && (after >= len(s) || s[after] != delim[0])
+	}
+	return valid
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
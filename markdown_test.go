@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>\n"},
+		{"headings", "# Title\n### Sub ###", "<h1>Title</h1>\n<h3>Sub</h3>\n"},
+		{"not a heading", "#hashtag", "<p>#hashtag</p>\n"},
+		{"emphasis", "*a* **b** _c_ __d__", "<p><em>a</em> <strong>b</strong> <em>c</em> <strong>d</strong></p>\n"},
+		{"nested emphasis", "*a **b** c*", "<p><em>a <strong>b</strong> c</em></p>\n"},
+		{"snake case", "snake_case_name and 2 * 3", "<p>snake_case_name and 2 * 3</p>\n"},
+		{"code span", "run `a < b` now", "<p>run <code>a &lt; b</code> now</p>\n"},
+		{"link", "see [the *docs*](https://example.com/a?b=1&c=2)", `<p>see <a href="https://example.com/a?b=1&amp;c=2">the <em>docs</em></a></p>` + "\n"},
+		{"autolink", "<https://example.com>", `<p><a href="https://example.com">https://example.com</a></p>` + "\n"},
+		{"escapes", `\*not em\* & <b>`, "<p>*not em* &amp; &lt;b&gt;</p>\n"},
+		{"rule", "a\n\n---\n\nb", "<p>a</p>\n<hr>\n<p>b</p>\n"},
+		{"fenced code", "```go\nif a < b {}\n```\nafter", `<pre><code class="language-go">if a &lt; b {}` + "\n</code></pre>\n<p>after</p>\n"},
+		{"unclosed fence", "~~~\ncode", "<pre><code>code\n</code></pre>\n"},
+		{"quote", "> quoted\n> *text*", "<blockquote>\n<p>quoted\n<em>text</em></p>\n</blockquote>\n"},
+		{"bullets", "- one\n- two\n  continued", "<ul>\n<li>one</li>\n<li>two\ncontinued</li>\n</ul>\n"},
+		{"ordered", "1. one\n2) two", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{"nested list", "- a\n  - b\n  - c\n- d", "<ul>\n<li>a\n<ul>\n<li>b</li>\n<li>c</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n"},
+		{"list after paragraph", "Todo:\n- milk", "<p>Todo:</p>\n<ul>\n<li>milk</li>\n</ul>\n"},
+		{"crlf", "# A\r\nb\r\n", "<h1>A</h1>\n<p>b</p>\n"},
+	}
+	for _, tt := range tests {
+		if got := markdownToHTML(tt.markdown); got != tt.want {
+			t.Errorf("%s: expected\n%q, got\n%q", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOSC52TTY          = "/dev/tty"
+	defaultOSC52QueryTimeout = time.Second
+	// Every query shows up on the terminal, so the clipboard isn't polled
+	// at the watcher's pace
+	defaultOSC52QueryInterval = 10 * time.Second
+	// GNU screen drops DCS strings longer than this
+	defaultOSC52ScreenChunkSize = 768
+)
+
+// Ways to get an OSC 52 sequence through a terminal multiplexer to the
+// terminal it runs in.
+const (
+	OSC52PassthroughAuto   = "auto"
+	OSC52PassthroughNone   = "none"
+	OSC52PassthroughTmux   = "tmux"
+	OSC52PassthroughScreen = "screen"
+)
+
+type OSC52Config struct {
+	TTY         string `toml:"tty"`
+	Passthrough string `toml:"passthrough"`
+	// ChunkSize is the most bytes sent in one screen passthrough string.
+	ChunkSize int `toml:"chunk_size"`
+	// MaxSize is the longest sequence the terminal accepts, 0 for no
+	// limit. Longer content is refused rather than cut short.
+	MaxSize      int           `toml:"max_size"`
+	Query        bool          `toml:"query"`
+	QueryTimeout time.Duration `toml:"query_timeout"`
+	// QueryInterval is how long a queried selection is taken as it was.
+	QueryInterval time.Duration `toml:"query_interval"`
+}
+
+var (
+	errOSC52NoReply = errors.New("osc52: the terminal did not answer the clipboard query")
+	errOSC52TooLong = errors.New("osc52: content is longer than the terminal accepts")
+)
+
+// OSC52Clipboard sets the clipboard of the terminal the watcher runs in,
+// wherever that terminal is, by writing an OSC 52 escape sequence to the
+// TTY. This works over SSH where no clipboard tools are installed.
+//
+// Terminals that answer OSC 52 queries can be read from with Query set, at
+// most once per QueryInterval. Otherwise Read returns what was last written
+// or queried, which is enough for SyncToClipboard to skip unchanged content.
+type OSC52Clipboard struct {
+	config OSC52Config
+	getenv func(string) string
+	now    func() time.Time
+
+	mu      sync.Mutex
+	written map[Selection][]byte
+	queried map[Selection]time.Time
+}
+
+func NewOSC52Clipboard(cfg OSC52Config) *OSC52Clipboard {
+	return &OSC52Clipboard{
+		config:  cfg,
+		getenv:  os.Getenv,
+		now:     time.Now,
+		written: make(map[Selection][]byte),
+		queried: make(map[Selection]time.Time),
+	}
+}
+
+// osc52SelectionParam returns the selection parameter of the sequence.
+func osc52SelectionParam(selection Selection) (string, error) {
+	switch selection {
+	case SelectionClipboard:
+		return "c", nil
+	case SelectionPrimary:
+		return "p", nil
+	case SelectionSecondary:
+		return "q", nil
+	default:
+		return "", unsupportedSelection("osc52", selection)
+	}
+}
+
+func (o *OSC52Clipboard) Read(ctx context.Context, selection Selection, mimeType string) ([]byte, error) {
+	param, err := osc52SelectionParam(selection)
+	if err != nil {
+		return nil, err
+	}
+	if !isTextType(mimeType) {
+		return nil, unsupportedType("osc52", mimeType)
+	}
+	if !o.config.Query {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return o.written[selection], nil
+	}
+
+	interval := o.config.QueryInterval
+	if interval <= 0 {
+		interval = defaultOSC52QueryInterval
+	}
+	o.mu.Lock()
+	now := o.now()
+	if last, ok := o.queried[selection]; ok && now.Sub(last) < interval {
+		defer o.mu.Unlock()
+		return o.written[selection], nil
+	}
+	// A terminal that doesn't answer isn't asked again right away either
+	o.queried[selection] = now
+	o.mu.Unlock()
+
+	timeout := o.config.QueryTimeout
+	if timeout <= 0 {
+		timeout = defaultOSC52QueryTimeout
+	}
+	reply, err := queryTerminal(ctx, o.tty(), o.wrap("\x1b]52;"+param+";?\a"), timeout)
+	if err != nil {
+		return nil, err
+	}
+	content, err := parseOSC52Reply(reply)
+	if err != nil {
+		return nil, err
+	}
+	o.mu.Lock()
+	o.written[selection] = content
+	o.mu.Unlock()
+	return content, nil
+}
+
+func (o *OSC52Clipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	param, err := osc52SelectionParam(selection)
+	if err != nil {
+		return err
+	}
+	if !content.IsText() {
+		return unsupportedType("osc52", content.Type)
+	}
+	seq := o.wrap("\x1b]52;" + param + ";" + base64.StdEncoding.EncodeToString(content.Data) + "\a")
+	if o.config.MaxSize > 0 && len(seq) > o.config.MaxSize {
+		return fmt.Errorf("%w (%d bytes encoded, max_size is %d)", errOSC52TooLong, len(seq), o.config.MaxSize)
+	}
+
+	tty, err := os.OpenFile(o.tty(), os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("osc52: %w", err)
+	}
+	if _, err := tty.Write(seq); err != nil {
+		_ = tty.Close()
+		return fmt.Errorf("osc52: %w", err)
+	}
+	if err := tty.Close(); err != nil {
+		return fmt.Errorf("osc52: %w", err)
+	}
+
+	o.mu.Lock()
+	o.written[selection] = content.Data
+	o.mu.Unlock()
+	return nil
+}
+
+func (o *OSC52Clipboard) tty() string {
+	if o.config.TTY != "" {
+		return o.config.TTY
+	}
+	return defaultOSC52TTY
+}
+
+// passthrough resolves "auto" (or nothing) to the multiplexer the watcher
+// runs in, if any.
+func (o *OSC52Clipboard) passthrough() string {
+	switch o.config.Passthrough {
+	case "", OSC52PassthroughAuto:
+		switch {
+		case o.getenv("TMUX") != "":
+			return OSC52PassthroughTmux
+		case o.getenv("STY") != "":
+			return OSC52PassthroughScreen
+		default:
+			return OSC52PassthroughNone
+		}
+	default:
+		return o.config.Passthrough
+	}
+}
+
+// wrap packs seq into the DCS strings that tmux or screen pass on to the
+// outer terminal unchanged.
+func (o *OSC52Clipboard) wrap(seq string) []byte {
+	var b bytes.Buffer
+	switch o.passthrough() {
+	case OSC52PassthroughTmux:
+		// tmux needs allow-passthrough, and escapes inside doubled
+		b.WriteString("\x1bPtmux;")
+		b.WriteString(strings.ReplaceAll(seq, "\x1b", "\x1b\x1b"))
+		b.WriteString("\x1b\\")
+	case OSC52PassthroughScreen:
+		// screen limits the length of a DCS string, but the terminal
+		// sees the pieces as one sequence
+		size := o.config.ChunkSize
+		if size <= 0 {
+			size = defaultOSC52ScreenChunkSize
+		}
+		for len(seq) > 0 {
+			n := min(size, len(seq))
+			b.WriteString("\x1bP")
+			b.WriteString(seq[:n])
+			b.WriteString("\x1b\\")
+			seq = seq[n:]
+		}
+	default:
+		b.WriteString(seq)
+	}
+	return b.Bytes()
+}
+
+// parseOSC52Reply decodes the terminal's answer to a query,
+// "ESC ] 52 ; c ; <base64>" ended by BEL or ST. Anything the terminal sent
+// before it, such as key presses, is skipped.
+func parseOSC52Reply(reply []byte) ([]byte, error) {
+	start := bytes.Index(reply, []byte("\x1b]52;"))
+	if start < 0 {
+		return nil, errOSC52NoReply
+	}
+	body := reply[start+len("\x1b]52;"):]
+	end := bytes.IndexByte(body, '\a')
+	if st := bytes.Index(body, []byte("\x1b\\")); st >= 0 && (end < 0 || st < end) {
+		end = st
+	}
+	if end < 0 {
+		return nil, errOSC52NoReply
+	}
+	_, data, ok := bytes.Cut(body[:end], []byte(";"))
+	if !ok {
+		return nil, fmt.Errorf("osc52: malformed reply %q", body[:end])
+	}
+	content, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("osc52: malformed reply: %w", err)
+	}
+	return content, nil
+}
+
+// osc52ReplyComplete reports whether reply holds a whole answer.
+func osc52ReplyComplete(reply []byte) bool {
+	start := bytes.Index(reply, []byte("\x1b]52;"))
+	if start < 0 {
+		return false
+	}
+	body := reply[start:]
+	return bytes.IndexByte(body, '\a') >= 0 || bytes.Contains(body, []byte("\x1b\\"))
+}
@@ -0,0 +1,63 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// queryTerminal writes query to the TTY at path and collects the reply. The
+// TTY is switched to non-canonical mode without echo while waiting, so the
+// reply arrives byte by byte instead of after a newline and is not shown.
+func queryTerminal(ctx context.Context, path string, query []byte, timeout time.Duration) ([]byte, error) {
+	tty, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("osc52: %w", err)
+	}
+	defer func() { _ = tty.Close() }()
+
+	fd := int(tty.Fd())
+	old, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, fmt.Errorf("osc52: %s is not a terminal: %w", path, err)
+	}
+	raw := *old
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	// Reads return after a tenth of a second without input
+	raw.Cc[unix.VMIN] = 0
+	raw.Cc[unix.VTIME] = 1
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, fmt.Errorf("osc52: %w", err)
+	}
+	defer func() { _ = unix.IoctlSetTermios(fd, ioctlSetTermios, old) }()
+
+	if _, err := tty.Write(query); err != nil {
+		return nil, fmt.Errorf("osc52: %w", err)
+	}
+
+	var reply []byte
+	buf := make([]byte, 4096)
+	deadline := time.Now().Add(timeout)
+	for !osc52ReplyComplete(reply) {
+		if time.Now().After(deadline) {
+			return nil, errOSC52NoReply
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := tty.Read(buf)
+		reply = append(reply, buf[:n]...)
+		// A read that timed out comes back as EOF
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("osc52: %w", err)
+		}
+	}
+	return reply, nil
+}
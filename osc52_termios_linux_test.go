@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY returns the controlling side of a new pseudo terminal and the path
+// of the terminal side.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo terminals: %v", err)
+	}
+	t.Cleanup(func() { _ = ptmx.Close() })
+
+	fd := int(ptmx.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Skipf("unlocking the pseudo terminal: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Skipf("finding the pseudo terminal: %v", err)
+	}
+	return ptmx, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestOSC52Clipboard_QueriesTerminal(t *testing.T) {
+	ptmx, tty := openPTY(t)
+
+	// Play the terminal: answer the query once it arrives
+	go func() {
+		var seen []byte
+		buf := make([]byte, 256)
+		for !bytes.Contains(seen, []byte("\x1b]52;c;?\a")) {
+			n, err := ptmx.Read(buf)
+			if err != nil {
+				return
+			}
+			seen = append(seen, buf[:n]...)
+		}
+		_, _ = ptmx.WriteString("\x1b]52;c;aGVsbG8=\x1b\\")
+	}()
+
+	cb := NewOSC52Clipboard(OSC52Config{TTY: tty, Passthrough: OSC52PassthroughNone, Query: true, QueryTimeout: 5 * time.Second})
+	got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", got)
+	}
+
+	// The terminal answers only once, so another query would time out
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("world")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	got, err = cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "world" {
+		t.Errorf("expected %q without a query, got %q", "world", got)
+	}
+}
+
+func TestOSC52Clipboard_QueryTimesOut(t *testing.T) {
+	_, tty := openPTY(t)
+
+	cb := NewOSC52Clipboard(OSC52Config{TTY: tty, Passthrough: OSC52PassthroughNone, Query: true, QueryTimeout: 200 * time.Millisecond})
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+	if _, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); !errors.Is(err, errOSC52NoReply) {
+		t.Errorf("expected errOSC52NoReply, got %v", err)
+	}
+
+	// A terminal that didn't answer is asked again after the interval only
+	if _, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); err != nil {
+		t.Errorf("expected no query within the interval, got %v", err)
+	}
+	now = now.Add(defaultOSC52QueryInterval)
+	if _, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); !errors.Is(err, errOSC52NoReply) {
+		t.Errorf("expected another query after the interval, got %v", err)
+	}
+}
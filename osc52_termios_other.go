@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+func queryTerminal(ctx context.Context, path string, query []byte, timeout time.Duration) ([]byte, error) {
+	return nil, errors.New("osc52: querying the terminal is not supported on this platform")
+}
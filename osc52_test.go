@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestOSC52Clipboard(t *testing.T, cfg OSC52Config, env map[string]string) (*OSC52Clipboard, string) {
+	t.Helper()
+	if cfg.TTY == "" {
+		cfg.TTY = filepath.Join(t.TempDir(), "tty")
+		if err := os.WriteFile(cfg.TTY, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cb := NewOSC52Clipboard(cfg)
+	cb.getenv = func(key string) string { return env[key] }
+	return cb, cfg.TTY
+}
+
+func readTTY(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNewClipboard_ReturnsOSC52WhenSpecified(t *testing.T) {
+	cb, err := NewClipboard("osc52")
+	if err != nil {
+		t.Fatalf("NewClipboard failed: %v", err)
+	}
+
+	if _, ok := cb.(*OSC52Clipboard); !ok {
+		t.Errorf("expected *OSC52Clipboard, got %T", cb)
+	}
+}
+
+func TestOSC52Clipboard_WritesSequence(t *testing.T) {
+	cb, tty := newTestOSC52Clipboard(t, OSC52Config{}, nil)
+
+	for _, tt := range []struct {
+		selection Selection
+		want      string
+	}{
+		{SelectionClipboard, "\x1b]52;c;aGVsbG8=\a"},
+		{SelectionPrimary, "\x1b]52;p;aGVsbG8=\a"},
+		{SelectionSecondary, "\x1b]52;q;aGVsbG8=\a"},
+	} {
+		if err := cb.Write(context.Background(), tt.selection, TextContent("hello")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if got := readTTY(t, tty); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.selection, tt.want, got)
+		}
+	}
+}
+
+func TestOSC52Clipboard_TmuxPassthrough(t *testing.T) {
+	cb, tty := newTestOSC52Clipboard(t, OSC52Config{}, map[string]string{"TMUX": "/tmp/tmux-1000/default,1,0"})
+
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("hello")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := "\x1bPtmux;\x1b\x1b]52;c;aGVsbG8=\a\x1b\\"
+	if got := readTTY(t, tty); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestOSC52Clipboard_ScreenPassthroughIsChunked(t *testing.T) {
+	cb, tty := newTestOSC52Clipboard(t, OSC52Config{ChunkSize: 10}, map[string]string{"STY": "1234.pts-0.host"})
+
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("hello")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := "\x1bP\x1b]52;c;aGV\x1b\\\x1bPsbG8=\a\x1b\\"
+	if got := readTTY(t, tty); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestOSC52Clipboard_ConfiguredPassthroughWins(t *testing.T) {
+	cb, tty := newTestOSC52Clipboard(t, OSC52Config{Passthrough: OSC52PassthroughNone}, map[string]string{"TMUX": "/tmp/tmux"})
+
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("hello")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if got := readTTY(t, tty); strings.Contains(got, "tmux") {
+		t.Errorf("expected no tmux wrapping, got %q", got)
+	}
+}
+
+func TestOSC52Clipboard_RefusesContentOverMaxSize(t *testing.T) {
+	cb, tty := newTestOSC52Clipboard(t, OSC52Config{MaxSize: 32}, nil)
+
+	err := cb.Write(context.Background(), SelectionClipboard, TextContent(strings.Repeat("x", 100)))
+	if !errors.Is(err, errOSC52TooLong) {
+		t.Errorf("expected errOSC52TooLong, got %v", err)
+	}
+	if got := readTTY(t, tty); got != "" {
+		t.Errorf("expected nothing written, got %q", got)
+	}
+}
+
+func TestOSC52Clipboard_ReadReturnsLastWriteWithoutQuery(t *testing.T) {
+	cb, _ := newTestOSC52Clipboard(t, OSC52Config{}, nil)
+
+	if got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); err != nil || string(got) != "" {
+		t.Errorf("expected an empty clipboard, got %q, %v", got, err)
+	}
+	if err := cb.Write(context.Background(), SelectionClipboard, TextContent("hello")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); err != nil || string(got) != "hello" {
+		t.Errorf("expected %q, got %q, %v", "hello", got, err)
+	}
+	if got, _ := cb.Read(context.Background(), SelectionPrimary, MIMETypeText); string(got) != "" {
+		t.Errorf("expected the primary selection to be empty, got %q", got)
+	}
+}
+
+func TestOSC52Clipboard_RejectsUnknownSelection(t *testing.T) {
+	cb, _ := newTestOSC52Clipboard(t, OSC52Config{}, nil)
+
+	if err := cb.Write(context.Background(), SelectionBoth, TextContent("hello")); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestParseOSC52Reply(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("héllo\n"))
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{"BEL", "\x1b]52;c;" + encoded + "\a", "héllo\n"},
+		{"ST", "\x1b]52;c;" + encoded + "\x1b\\", "héllo\n"},
+		{"typed ahead", "ab\x1b]52;c;" + encoded + "\a", "héllo\n"},
+		{"empty", "\x1b]52;c;\a", ""},
+	}
+	for _, tt := range tests {
+		got, err := parseOSC52Reply([]byte(tt.reply))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	for _, reply := range []string{"", "\x1b]52;c;aGVs", "\x1b]52;c;!!!\a"} {
+		if _, err := parseOSC52Reply([]byte(reply)); err == nil {
+			t.Errorf("%q: expected an error", reply)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	MIMETypeHTML    = "text/html"
+	MIMETypeURIList = "text/uri-list"
+)
+
+// representationDerivers make the representations a watch can offer besides
+// its text, from that text and the directory relative paths are in.
+var representationDerivers = map[string]func(text, dir string) []byte{
+	MIMETypeHTML: func(text, _ string) []byte {
+		return []byte(markdownToHTML(text))
+	},
+	MIMETypeURIList: uriList,
+}
+
+// checkRepresentations reports a representation no deriver makes.
+func checkRepresentations(mimeTypes []string) error {
+	for _, mimeType := range mimeTypes {
+		if _, ok := representationDerivers[mimeType]; ok {
+			continue
+		}
+		var known []string
+		for derived := range representationDerivers {
+			known = append(known, derived)
+		}
+		sort.Strings(known)
+		return fmt.Errorf("unknown representation %q (use %s)", mimeType, strings.Join(known, " or "))
+	}
+	return nil
+}
+
+// deriveRepresentations returns the representations of text as each of
+// mimeTypes, to be offered alongside it.
+func deriveRepresentations(text string, mimeTypes []string, dir string) []Content {
+	var representations []Content
+	for _, mimeType := range mimeTypes {
+		if derive, ok := representationDerivers[mimeType]; ok {
+			representations = append(representations, Content{Type: mimeType, Data: derive(text, dir)})
+		}
+	}
+	return representations
+}
+
+// uriList turns a list of paths and URLs, one per line, into a
+// text/uri-list. Relative paths are taken to be in dir, and blank lines and
+// # comments are dropped.
+func uriList(text, dir string) []byte {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// A one letter scheme is a Windows drive rather than a URL
+		if u, err := url.Parse(line); err == nil && len(u.Scheme) > 1 {
+			b.WriteString(line + "\r\n")
+			continue
+		}
+		path := line
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		b.WriteString((&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String() + "\r\n")
+	}
+	return []byte(b.String())
+}
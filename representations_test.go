@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestURIList(t *testing.T) {
+	got := string(uriList("# screenshots\n/tmp/a b.png\n\nshots/c.png\nhttps://example.com/d.png\n", "/home/me"))
+	want := "file:///tmp/a%20b.png\r\nfile:///home/me/shots/c.png\r\nhttps://example.com/d.png\r\n"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestDeriveRepresentations(t *testing.T) {
+	got := deriveRepresentations("*hi*", []string{MIMETypeHTML, MIMETypeURIList}, "/notes")
+	want := []Content{
+		{Type: MIMETypeHTML, Data: []byte("<p><em>hi</em></p>\n")},
+		{Type: MIMETypeURIList, Data: []byte("file:///notes/%2Ahi%2A\r\n")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := deriveRepresentations("hi", nil, "/notes"); got != nil {
+		t.Errorf("expected no representations, got %+v", got)
+	}
+}
+
+func TestCheckRepresentations(t *testing.T) {
+	if err := checkRepresentations([]string{MIMETypeHTML, MIMETypeURIList}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkRepresentations([]string{"application/pdf"}); err == nil {
+		t.Error("expected an error for a representation that can't be derived")
+	}
+}
+
+func TestContent_Representations(t *testing.T) {
+	html := Content{Type: MIMETypeHTML, Data: []byte("<p>hi</p>")}
+	content := TextContent("hi")
+	content.Alternatives = []Content{html}
+
+	got := content.representations()
+	if len(got) != 2 || !reflect.DeepEqual(got[0], TextContent("hi")) || !reflect.DeepEqual(got[1], html) {
+		t.Errorf("expected the text and then its HTML, got %+v", got)
+	}
+}
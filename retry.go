@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts  = 5
+	defaultRetryInitialDelay = 250 * time.Millisecond
+	defaultRetryMaxDelay     = 10 * time.Second
+)
+
+type RetryConfig struct {
+	// MaxAttempts is how often a sync is tried in all, 1 to never retry.
+	MaxAttempts  int           `toml:"max_attempts"`
+	InitialDelay time.Duration `toml:"initial_delay"`
+	MaxDelay     time.Duration `toml:"max_delay"`
+}
+
+// delay returns how long to wait after the given number of failed attempts.
+// It doubles from InitialDelay up to MaxDelay, less a random part of up to
+// half, so watches that failed together don't retry in lockstep.
+func (c RetryConfig) delay(failed int) time.Duration {
+	d := c.InitialDelay
+	if d <= 0 {
+		d = defaultRetryInitialDelay
+	}
+	for i := 1; i < failed && (c.MaxDelay <= 0 || d < c.MaxDelay); i++ {
+		d *= 2
+	}
+	if c.MaxDelay > 0 {
+		d = min(d, c.MaxDelay)
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// permanentErrors are failures that trying again won't fix.
+var permanentErrors = []error{ErrBackendNotInstalled, ErrUnsupportedSelection, ErrUnsupportedType, errOSC52TooLong}
+
+func retryable(err error) bool {
+	for _, permanent := range permanentErrors {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// retryingSync syncs file content into the clipboard in the background and
+// tries again with backoff when that fails, as it does while a compositor
+// restarts. Content that arrives in the meantime replaces content waiting to
+// be retried, so the clipboard never goes back to an older version.
+type retryingSync struct {
+	config RetryConfig
+	sync   func(ctx context.Context, content string) error
+	// retrying is called before waiting to try again, failed on giving up.
+	retrying func(err error, wait time.Duration)
+	failed   func(err error)
+
+	latest chan string
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newRetryingSync(cfg RetryConfig, sync func(ctx context.Context, content string) error, retrying func(error, time.Duration), failed func(error)) *retryingSync {
+	r := &retryingSync{
+		config:   cfg,
+		sync:     sync,
+		retrying: retrying,
+		failed:   failed,
+		latest:   make(chan string, 1),
+		done:     make(chan struct{}),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	go r.run()
+
+	return r
+}
+
+// Submit queues content to be synced, replacing any that hasn't been yet.
+func (r *retryingSync) Submit(content string) {
+	for {
+		select {
+		case r.latest <- content:
+			return
+		default:
+		}
+		select {
+		case <-r.latest:
+		default:
+		}
+	}
+}
+
+func (r *retryingSync) run() {
+	defer close(r.done)
+
+	var (
+		content string
+		failed  int
+		retry   *time.Timer
+		retryC  <-chan time.Time
+	)
+	for {
+		select {
+		case content = <-r.latest:
+			failed = 0
+			if retry != nil {
+				retry.Stop()
+			}
+		case <-retryC:
+		case <-r.ctx.Done():
+			if retry != nil {
+				retry.Stop()
+			}
+			return
+		}
+		retryC = nil
+
+		err := r.sync(r.ctx, content)
+		if err == nil || r.ctx.Err() != nil {
+			continue
+		}
+		failed++
+		if !retryable(err) || failed >= max(r.config.MaxAttempts, 1) {
+			r.failed(err)
+			continue
+		}
+		wait := r.config.delay(failed)
+		r.retrying(err, wait)
+		retry = time.NewTimer(wait)
+		retryC = retry.C
+	}
+}
+
+// Close gives up on content not synced yet, and on a sync in progress.
+func (r *retryingSync) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncRecorder is a sync function that fails as long as fail says so, and
+// remembers what it was asked to sync.
+type syncRecorder struct {
+	mu       sync.Mutex
+	fail     func(content string, attempt int) error
+	attempts []string
+	synced   chan string
+}
+
+func newSyncRecorder(fail func(content string, attempt int) error) *syncRecorder {
+	return &syncRecorder{fail: fail, synced: make(chan string, 10)}
+}
+
+func (s *syncRecorder) sync(_ context.Context, content string) error {
+	s.mu.Lock()
+	s.attempts = append(s.attempts, content)
+	attempt := len(s.attempts)
+	s.mu.Unlock()
+	if err := s.fail(content, attempt); err != nil {
+		return err
+	}
+	s.synced <- content
+	return nil
+}
+
+func (s *syncRecorder) attemptsMade() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.attempts...)
+}
+
+var testRetryConfig = RetryConfig{MaxAttempts: 3, InitialDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond}
+
+func TestRetryingSync_RetriesTransientFailures(t *testing.T) {
+	rec := newSyncRecorder(func(content string, attempt int) error {
+		if attempt < 3 {
+			return errors.New("compositor went away")
+		}
+		return nil
+	})
+	var retries int
+	r := newRetryingSync(testRetryConfig, rec.sync, func(error, time.Duration) { retries++ }, func(err error) {
+		t.Errorf("expected the sync to succeed, gave up with %v", err)
+	})
+	defer func() { _ = r.Close() }()
+
+	r.Submit("content")
+	waitForContent(t, rec.synced, "content")
+	if retries != 2 {
+		t.Errorf("expected 2 retries, got %d", retries)
+	}
+}
+
+func TestRetryingSync_GivesUpAfterMaxAttempts(t *testing.T) {
+	rec := newSyncRecorder(func(string, int) error { return errors.New("still broken") })
+	failed := make(chan error, 1)
+	r := newRetryingSync(testRetryConfig, rec.sync, func(error, time.Duration) {}, func(err error) { failed <- err })
+	defer func() { _ = r.Close() }()
+
+	r.Submit("content")
+	select {
+	case <-failed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the sync to be given up on")
+	}
+	if got := len(rec.attemptsMade()); got != testRetryConfig.MaxAttempts {
+		t.Errorf("expected %d attempts, got %d", testRetryConfig.MaxAttempts, got)
+	}
+}
+
+func TestRetryingSync_DoesNotRetryPermanentErrors(t *testing.T) {
+	for _, err := range []error{ErrBackendNotInstalled, unsupportedSelection("wayland", SelectionSecondary), errOSC52TooLong} {
+		rec := newSyncRecorder(func(string, int) error { return err })
+		failed := make(chan error, 1)
+		r := newRetryingSync(testRetryConfig, rec.sync, func(error, time.Duration) {
+			t.Errorf("%v: expected no retry", err)
+		}, func(err error) { failed <- err })
+
+		r.Submit("content")
+		<-failed
+		_ = r.Close()
+		if got := len(rec.attemptsMade()); got != 1 {
+			t.Errorf("%v: expected 1 attempt, got %d", err, got)
+		}
+	}
+}
+
+func TestRetryingSync_NewerContentWins(t *testing.T) {
+	rec := newSyncRecorder(func(content string, _ int) error {
+		if content == "old" {
+			return errors.New("compositor went away")
+		}
+		return nil
+	})
+	retrying := make(chan struct{}, 10)
+	config := testRetryConfig
+	config.InitialDelay = 200 * time.Millisecond
+	r := newRetryingSync(config, rec.sync, func(error, time.Duration) { retrying <- struct{}{} }, func(error) {})
+	defer func() { _ = r.Close() }()
+
+	r.Submit("old")
+	<-retrying
+	r.Submit("new")
+	waitForContent(t, rec.synced, "new")
+
+	// The retry of the old content is dropped
+	time.Sleep(2 * config.InitialDelay)
+	if got := rec.attemptsMade(); len(got) != 2 || got[0] != "old" || got[1] != "new" {
+		t.Errorf("expected attempts [old new], got %v", got)
+	}
+}
+
+func TestRetryConfig_DelayBacksOffWithJitter(t *testing.T) {
+	config := RetryConfig{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for failed, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			if d := config.delay(failed); d < want/2 || d > want {
+				t.Errorf("delay after %d failures: expected %v to %v, got %v", failed, want/2, want, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// What to do with text that holds a secret.
+const (
+	SecretActionOff    = "off"
+	SecretActionWarn   = "warn"
+	SecretActionRedact = "redact"
+	SecretActionBlock  = "block"
+)
+
+// secretRedacted is what a redacted secret is replaced with.
+const secretRedacted = "[REDACTED]"
+
+type SecretsConfig struct {
+	Action string `toml:"action"`
+	// Patterns are regular expressions to look for besides the built-in
+	// kinds of secret. Of a pattern with groups, only the first is redacted.
+	Patterns []string `toml:"patterns"`
+}
+
+// secretPattern is a kind of secret. Of a match, group 1 is the secret if
+// the pattern has groups, otherwise all of it.
+type secretPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+var builtinSecrets = []secretPattern{
+	{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`)},
+	// \b would miss keys ending in / or +
+	{"AWS secret access key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+]|$)`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{"JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+	// A key cut off before its end line is still a secret
+	{"private key", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----(?s:.*?)(?:-----END (?:[A-Z0-9]+ )*PRIVATE KEY-----|\z)`)},
+}
+
+// secretScanner looks for secrets in text before it is synced. One that is
+// off has no patterns, so it finds none.
+type secretScanner struct {
+	action   string
+	patterns []secretPattern
+}
+
+func newSecretScanner(cfg SecretsConfig) (*secretScanner, error) {
+	switch cfg.Action {
+	case "", SecretActionOff:
+		return &secretScanner{action: SecretActionOff}, nil
+	case SecretActionWarn, SecretActionRedact, SecretActionBlock:
+	default:
+		return nil, fmt.Errorf("secrets: unknown action %q (use off, warn, redact or block)", cfg.Action)
+	}
+
+	s := &secretScanner{action: cfg.Action, patterns: slices.Clone(builtinSecrets)}
+	for _, pattern := range cfg.Patterns {
+		if pattern == "" {
+			return nil, errors.New("secrets: empty pattern")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: %w", err)
+		}
+		s.patterns = append(s.patterns, secretPattern{name: "pattern " + pattern, re: re})
+	}
+	return s, nil
+}
+
+// secretMatch is where a secret is in the text, as byte offsets.
+type secretMatch struct {
+	name       string
+	start, end int
+}
+
+// find returns the secrets in text, in order and without overlaps.
+func (s *secretScanner) find(text string) []secretMatch {
+	var matches []secretMatch
+	for _, p := range s.patterns {
+		for _, loc := range p.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) > 2 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			if start < end {
+				matches = append(matches, secretMatch{name: p.name, start: start, end: end})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+
+	// A secret inside another, such as a token in a user pattern, is
+	// merged into it
+	merged := matches[:0]
+	for _, m := range matches {
+		if last := len(merged) - 1; last >= 0 && m.start < merged[last].end {
+			merged[last].end = max(merged[last].end, m.end)
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+// check applies the action to the secrets in text, which is on its way to
+// being synced or recorded as doing says. It returns the text to use, and
+// false if it is blocked. Only the names of secrets are logged, never the
+// secrets.
+func (s *secretScanner) check(text, doing string, logger *log.Logger) (string, bool) {
+	matches := s.find(text)
+	if len(matches) == 0 {
+		return text, true
+	}
+	switch s.action {
+	case SecretActionBlock:
+		logger.Printf("Not %s content that holds secrets: %s", doing, secretNames(matches))
+		return "", false
+	case SecretActionRedact:
+		logger.Printf("Redacted secrets: %s", secretNames(matches))
+		return redact(text, matches), true
+	default:
+		logger.Printf("Warning: %s content that holds secrets: %s", doing, secretNames(matches))
+		return text, true
+	}
+}
+
+// redact replaces the matches in text.
+func redact(text string, matches []secretMatch) string {
+	var b strings.Builder
+	prev := 0
+	for _, m := range matches {
+		b.WriteString(text[prev:m.start])
+		b.WriteString(secretRedacted)
+		prev = m.end
+	}
+	b.WriteString(text[prev:])
+	return b.String()
+}
+
+// secretNames lists the kinds of secret found, each once, for log output.
+func secretNames(matches []secretMatch) string {
+	var names []string
+	for _, m := range matches {
+		if !slices.Contains(names, m.name) {
+			names = append(names, m.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -1,14 +1,136 @@
 package main
 
-func SyncToClipboard(cb Clipboard, fileContent string) error {
-	currentClipboard, err := cb.Read()
-	if err != nil {
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type InitialSync string
+
+const (
+	InitialSyncFile      InitialSync = "file"
+	InitialSyncClipboard InitialSync = "clipboard"
+	InitialSyncNone      InitialSync = "none"
+)
+
+type Direction string
+
+const (
+	DirectionFileToClipboard Direction = "file-to-clipboard"
+	DirectionClipboardToFile Direction = "clipboard-to-file"
+	DirectionBoth            Direction = "both"
+)
+
+func SyncToClipboard(ctx context.Context, cb Clipboard, selection Selection, fileContent Content) error {
+	if s, ok := cb.(Syncer); ok {
+		return s.Sync(ctx, selection, fileContent)
+	}
+
+	// An empty selection still gets the file's content
+	currentClipboard, err := cb.Read(ctx, selection, fileContent.Type)
+	if err != nil && !errors.Is(err, ErrSelectionEmpty) {
 		return err
 	}
 
-	if currentClipboard != fileContent {
-		return cb.Write(fileContent)
+	if !bytes.Equal(currentClipboard, fileContent.Data) {
+		return cb.Write(ctx, selection, fileContent)
 	}
 
 	return nil
 }
+
+// SyncClipboardToFile seeds the file with the current clipboard contents as
+// mimeType, leaving it untouched if it already holds them or the clipboard
+// is empty.
+func SyncClipboardToFile(ctx context.Context, cb Clipboard, selection Selection, mimeType, filePath string) error {
+	data, err := cb.Read(ctx, selection, mimeType)
+	if errors.Is(err, ErrSelectionEmpty) || err == nil && len(data) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileIfChanged(filePath, data)
+}
+
+func writeFileIfChanged(filePath string, data []byte) error {
+	current, err := os.ReadFile(filePath)
+	if err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return writeFileAtomic(filePath, data)
+}
+
+// writeFileAtomic replaces the file by renaming a fully written temp file
+// over it, so readers never observe partial content. An existing file's
+// permissions are kept. A symlink is kept too, and the file it points to
+// replaced instead.
+func writeFileAtomic(filePath string, data []byte) error {
+	filePath, err := resolveSymlinks(filePath)
+	if err != nil {
+		return err
+	}
+
+	perm := fs.FileMode(0o644)
+	if info, err := os.Stat(filePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// maxSymlinks is how many links in a row are followed, as Linux does.
+const maxSymlinks = 40
+
+// resolveSymlinks returns the file filePath ends up at. A link to a file that
+// doesn't exist yet resolves to where that file would be.
+func resolveSymlinks(filePath string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(filePath)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	for i := 0; i < maxSymlinks; i++ {
+		info, err := os.Lstat(filePath)
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			return filePath, nil
+		}
+		target, err := os.Readlink(filePath)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(filePath), target)
+		}
+		filePath = target
+	}
+	return "", fmt.Errorf("%s: too many levels of symbolic links", filePath)
+}
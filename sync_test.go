@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -11,22 +15,26 @@ type mockClipboard struct {
 	writeErr     error
 	writeCalled  bool
 	writeContent string
+	readType     string
+	writeType    string
 }
 
-func (m *mockClipboard) Read() (string, error) {
-	return m.content, m.readErr
+func (m *mockClipboard) Read(_ context.Context, selection Selection, mimeType string) ([]byte, error) {
+	m.readType = mimeType
+	return []byte(m.content), m.readErr
 }
 
-func (m *mockClipboard) Write(content string) error {
+func (m *mockClipboard) Write(_ context.Context, selection Selection, content Content) error {
 	m.writeCalled = true
-	m.writeContent = content
+	m.writeContent = string(content.Data)
+	m.writeType = content.Type
 	return m.writeErr
 }
 
 func TestSyncToClipboard_UpdatesWhenDifferent(t *testing.T) {
 	cb := &mockClipboard{content: "old content"}
 
-	err := SyncToClipboard(cb, "new content")
+	err := SyncToClipboard(context.Background(), cb, SelectionClipboard, TextContent("new content"))
 	if err != nil {
 		t.Fatalf("SyncToClipboard failed: %v", err)
 	}
@@ -39,10 +47,23 @@ func TestSyncToClipboard_UpdatesWhenDifferent(t *testing.T) {
 	}
 }
 
+func TestSyncToClipboard_UsesContentType(t *testing.T) {
+	cb := &mockClipboard{content: "old content"}
+
+	image := Content{Type: "image/png", Data: []byte("\x89PNG")}
+	if err := SyncToClipboard(context.Background(), cb, SelectionClipboard, image); err != nil {
+		t.Fatalf("SyncToClipboard failed: %v", err)
+	}
+
+	if cb.readType != "image/png" || cb.writeType != "image/png" {
+		t.Errorf("expected the clipboard to be read and written as image/png, got %q and %q", cb.readType, cb.writeType)
+	}
+}
+
 func TestSyncToClipboard_SkipsWhenSame(t *testing.T) {
 	cb := &mockClipboard{content: "same content"}
 
-	err := SyncToClipboard(cb, "same content")
+	err := SyncToClipboard(context.Background(), cb, SelectionClipboard, TextContent("same content"))
 	if err != nil {
 		t.Fatalf("SyncToClipboard failed: %v", err)
 	}
@@ -55,20 +76,184 @@ func TestSyncToClipboard_SkipsWhenSame(t *testing.T) {
 func TestSyncToClipboard_ReturnsReadError(t *testing.T) {
 	cb := &mockClipboard{readErr: errors.New("read failed")}
 
-	err := SyncToClipboard(cb, "content")
+	err := SyncToClipboard(context.Background(), cb, SelectionClipboard, TextContent("content"))
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
 }
 
+func TestSyncToClipboard_WritesEmptySelection(t *testing.T) {
+	cb := &mockClipboard{readErr: &CommandError{Cmd: "wl-paste", Stderr: "Nothing is copied", ExitCode: 1, Err: errors.New("exit status 1"), reason: ErrSelectionEmpty}}
+
+	err := SyncToClipboard(context.Background(), cb, SelectionClipboard, TextContent("content"))
+	if err != nil {
+		t.Fatalf("SyncToClipboard failed: %v", err)
+	}
+	if cb.writeContent != "content" {
+		t.Errorf("expected writeContent %q, got %q", "content", cb.writeContent)
+	}
+}
+
 func TestSyncToClipboard_ReturnsWriteError(t *testing.T) {
 	cb := &mockClipboard{
 		content:  "old content",
 		writeErr: errors.New("write failed"),
 	}
 
-	err := SyncToClipboard(cb, "new content")
+	err := SyncToClipboard(context.Background(), cb, SelectionClipboard, TextContent("new content"))
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
 }
+
+func TestSyncClipboardToFile_WritesClipboardContent(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cb := &mockClipboard{content: "from clipboard"}
+
+	err := SyncClipboardToFile(context.Background(), cb, SelectionClipboard, MIMETypeText, watchFile)
+	if err != nil {
+		t.Fatalf("SyncClipboardToFile failed: %v", err)
+	}
+
+	got, err := os.ReadFile(watchFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "from clipboard" {
+		t.Errorf("expected file content %q, got %q", "from clipboard", got)
+	}
+
+	info, err := os.Stat(watchFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected permissions to be kept as %o, got %o", 0o600, info.Mode().Perm())
+	}
+}
+
+func TestSyncClipboardToFile_EmptyClipboardLeavesFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		readErr error
+	}{
+		{"cleared", fmt.Errorf("x11: %w", ErrSelectionEmpty)},
+		{"empty text", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watchFile := filepath.Join(t.TempDir(), "test.txt")
+			if err := os.WriteFile(watchFile, []byte("important"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cb := &mockClipboard{readErr: tt.readErr}
+			if err := SyncClipboardToFile(context.Background(), cb, SelectionClipboard, MIMETypeText, watchFile); err != nil {
+				t.Fatalf("SyncClipboardToFile failed: %v", err)
+			}
+
+			got, err := os.ReadFile(watchFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "important" {
+				t.Errorf("expected file content %q, got %q", "important", got)
+			}
+		})
+	}
+}
+
+func TestSyncClipboardToFile_CreatesMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	cb := &mockClipboard{content: "from clipboard"}
+
+	err := SyncClipboardToFile(context.Background(), cb, SelectionClipboard, MIMETypeText, watchFile)
+	if err != nil {
+		t.Fatalf("SyncClipboardToFile failed: %v", err)
+	}
+
+	got, err := os.ReadFile(watchFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "from clipboard" {
+		t.Errorf("expected file content %q, got %q", "from clipboard", got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the watch file in the directory, got %d entries", len(entries))
+	}
+}
+
+func TestSyncClipboardToFile_KeepsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"shared", "links"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := filepath.Join(dir, "shared", "clipboard.txt")
+	if err := os.WriteFile(target, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		link   string
+		target string
+	}{
+		{"existing target", filepath.Join("..", "shared", "clipboard.txt"), target},
+		{"missing target", filepath.Join("..", "shared", "new.txt"), filepath.Join(dir, "shared", "new.txt")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watchFile := filepath.Join(dir, "links", "test.txt")
+			_ = os.Remove(watchFile)
+			if err := os.Symlink(tt.link, watchFile); err != nil {
+				t.Skipf("can't create symlinks: %v", err)
+			}
+
+			cb := &mockClipboard{content: "from clipboard"}
+			if err := SyncClipboardToFile(context.Background(), cb, SelectionClipboard, MIMETypeText, watchFile); err != nil {
+				t.Fatalf("SyncClipboardToFile failed: %v", err)
+			}
+
+			if info, err := os.Lstat(watchFile); err != nil || info.Mode()&os.ModeSymlink == 0 {
+				t.Errorf("expected the watch file to still be a symlink, got %v, %v", info, err)
+			}
+			if got, _ := os.ReadFile(tt.target); string(got) != "from clipboard" {
+				t.Errorf("expected the link target to hold %q, got %q", "from clipboard", got)
+			}
+			if entries, _ := os.ReadDir(filepath.Join(dir, "links")); len(entries) != 1 {
+				t.Errorf("expected only the link in its directory, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestSyncClipboardToFile_ReturnsReadError(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	cb := &mockClipboard{readErr: errors.New("read failed")}
+
+	err := SyncClipboardToFile(context.Background(), cb, SelectionClipboard, MIMETypeText, watchFile)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if _, err := os.Stat(watchFile); err == nil {
+		t.Error("expected file NOT to be created")
+	}
+}
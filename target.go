@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Pick decides which file of a directory or glob watch ends up in the
+// clipboard.
+type Pick string
+
+const (
+	// PickNewest syncs the most recently modified matching file.
+	PickNewest Pick = "newest"
+	// PickCreated syncs every newly created matching file, in order.
+	PickCreated Pick = "created"
+)
+
+// Hidden files, editor swap and backup files, and partial downloads are
+// never picked up by directory and glob watches.
+var defaultIgnorePatterns = []string{
+	".*",
+	"*~",
+	"#*#",
+	"*.swp",
+	"*.swo",
+	"*.swx",
+	"4913",
+	"*.tmp",
+	"*.part",
+	"*.crdownload",
+}
+
+// watchTarget describes what a watcher looks at: either a single file, or
+// every file in a directory whose name matches a glob pattern.
+type watchTarget struct {
+	path    string
+	dir     string
+	pattern string
+	pick    Pick
+	ignore  []string
+}
+
+func newWatchTarget(path string, o watcherOptions) (*watchTarget, error) {
+	trailingSlash := strings.HasSuffix(path, string(filepath.Separator))
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &watchTarget{
+		path:   path,
+		dir:    filepath.Dir(path),
+		pick:   o.pick,
+		ignore: append(append([]string{}, defaultIgnorePatterns...), o.ignore...),
+	}
+	if t.pick == "" {
+		t.pick = PickNewest
+	}
+	if t.pick != PickNewest && t.pick != PickCreated {
+		return nil, fmt.Errorf("unknown pick %q (use newest or created)", t.pick)
+	}
+	for _, pattern := range t.ignore {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+		}
+	}
+
+	if hasGlobMeta(t.dir) {
+		return nil, fmt.Errorf("wildcards are only supported in the file name: %s", path)
+	}
+
+	switch {
+	case hasGlobMeta(filepath.Base(path)):
+		t.pattern = filepath.Base(path)
+		if _, err := filepath.Match(t.pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", path, err)
+		}
+	case trailingSlash || isDir(path):
+		t.dir = path
+		t.pattern = "*"
+	}
+
+	return t, nil
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// multi reports whether the target is a directory or glob rather than a
+// single file.
+func (t *watchTarget) multi() bool {
+	return t.pattern != ""
+}
+
+func (t *watchTarget) matches(path string) bool {
+	if !t.multi() {
+		return path == t.path
+	}
+	if filepath.Dir(path) != t.dir {
+		return false
+	}
+	name := filepath.Base(path)
+	if ok, _ := filepath.Match(t.pattern, name); !ok {
+		return false
+	}
+	for _, pattern := range t.ignore {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// exists reports whether there is anything to watch yet: the file itself, or
+// the directory for directory and glob watches.
+func (t *watchTarget) exists() bool {
+	if t.multi() {
+		return isDir(t.dir)
+	}
+	_, err := os.Stat(t.path)
+	return err == nil
+}
+
+// files returns the regular files currently matching a directory or glob
+// target.
+func (t *watchTarget) files() (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]os.FileInfo, len(entries))
+	for _, entry := range entries {
+		path := filepath.Join(t.dir, entry.Name())
+		if !t.matches(path) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files[path] = info
+	}
+	return files, nil
+}
+
+// newest returns the most recently modified matching file. Ties are broken by
+// name so the result is stable.
+func (t *watchTarget) newest() (string, bool) {
+	files, err := t.files()
+	if err != nil || len(files) == 0 {
+		return "", false
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		a, b := files[paths[i]].ModTime(), files[paths[j]].ModTime()
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return paths[i] > paths[j]
+	})
+	return paths[0], true
+}
+
+// current returns the file whose content the watch stands for right now: the
+// watch file itself, or the newest matching file of a directory or glob.
+func (t *watchTarget) current() (string, bool) {
+	if !t.multi() {
+		return t.path, true
+	}
+	return t.newest()
+}
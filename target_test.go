@@ -0,0 +1,170 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewWatchTarget_SingleFile(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	target, err := newWatchTarget(watchFile, watcherOptions{})
+	if err != nil {
+		t.Fatalf("newWatchTarget failed: %v", err)
+	}
+
+	if target.multi() {
+		t.Error("expected a single file target")
+	}
+	if target.dir != dir {
+		t.Errorf("expected dir %q, got %q", dir, target.dir)
+	}
+	if !target.matches(watchFile) {
+		t.Error("expected the watch file to match")
+	}
+	if target.matches(filepath.Join(dir, "other.txt")) {
+		t.Error("expected another file not to match")
+	}
+}
+
+func TestNewWatchTarget_Directory(t *testing.T) {
+	dir := t.TempDir()
+
+	target, err := newWatchTarget(dir, watcherOptions{})
+	if err != nil {
+		t.Fatalf("newWatchTarget failed: %v", err)
+	}
+
+	if !target.multi() {
+		t.Fatal("expected a directory target")
+	}
+	if target.dir != dir {
+		t.Errorf("expected dir %q, got %q", dir, target.dir)
+	}
+	if target.pick != PickNewest {
+		t.Errorf("expected pick %q by default, got %q", PickNewest, target.pick)
+	}
+	if !target.matches(filepath.Join(dir, "snippet-1.txt")) {
+		t.Error("expected a file in the directory to match")
+	}
+	if target.matches(filepath.Join(dir, "nested", "snippet-1.txt")) {
+		t.Error("expected a file in a subdirectory not to match")
+	}
+}
+
+func TestNewWatchTarget_MissingDirectoryWithTrailingSlash(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "not-yet") + string(filepath.Separator)
+
+	target, err := newWatchTarget(dir, watcherOptions{})
+	if err != nil {
+		t.Fatalf("newWatchTarget failed: %v", err)
+	}
+
+	if !target.multi() {
+		t.Error("expected a directory target")
+	}
+}
+
+func TestNewWatchTarget_Glob(t *testing.T) {
+	dir := t.TempDir()
+
+	target, err := newWatchTarget(filepath.Join(dir, "*.txt"), watcherOptions{})
+	if err != nil {
+		t.Fatalf("newWatchTarget failed: %v", err)
+	}
+
+	if target.dir != dir {
+		t.Errorf("expected dir %q, got %q", dir, target.dir)
+	}
+	if !target.matches(filepath.Join(dir, "snippet.txt")) {
+		t.Error("expected snippet.txt to match")
+	}
+	if target.matches(filepath.Join(dir, "snippet.md")) {
+		t.Error("expected snippet.md not to match")
+	}
+}
+
+func TestNewWatchTarget_RejectsWildcardsInDirectory(t *testing.T) {
+	_, err := newWatchTarget("/tmp/*/clipboard.txt", watcherOptions{})
+	if err == nil {
+		t.Error("expected error for wildcard in directory, got nil")
+	}
+}
+
+func TestNewWatchTarget_RejectsUnknownPick(t *testing.T) {
+	_, err := newWatchTarget(t.TempDir(), watcherOptions{pick: "oldest"})
+	if err == nil {
+		t.Error("expected error for unknown pick, got nil")
+	}
+}
+
+func TestNewWatchTarget_RejectsInvalidIgnorePattern(t *testing.T) {
+	_, err := newWatchTarget(t.TempDir(), watcherOptions{ignore: []string{"[unclosed"}})
+	if err == nil {
+		t.Error("expected error for invalid ignore pattern, got nil")
+	}
+}
+
+func TestWatchTarget_Matches_SkipsIgnoredFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	target, err := newWatchTarget(dir, watcherOptions{ignore: []string{"*.bak"}})
+	if err != nil {
+		t.Fatalf("newWatchTarget failed: %v", err)
+	}
+
+	ignored := []string{".hidden", ".snippet.txt.swp", "snippet.txt~", "#snippet.txt#", "4913", "snippet.txt.tmp", "snippet.bak"}
+	for _, name := range ignored {
+		if target.matches(filepath.Join(dir, name)) {
+			t.Errorf("expected %q to be ignored", name)
+		}
+	}
+}
+
+func TestWatchTarget_Newest(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	files := map[string]time.Time{
+		"old.txt":    now.Add(-2 * time.Hour),
+		"newest.txt": now.Add(-time.Minute),
+		"middle.txt": now.Add(-time.Hour),
+		".hidden":    now,
+	}
+	for name, mtime := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target, err := newWatchTarget(dir, watcherOptions{})
+	if err != nil {
+		t.Fatalf("newWatchTarget failed: %v", err)
+	}
+
+	newest, ok := target.newest()
+	if !ok {
+		t.Fatal("expected a newest file")
+	}
+	if newest != filepath.Join(dir, "newest.txt") {
+		t.Errorf("expected %q, got %q", filepath.Join(dir, "newest.txt"), newest)
+	}
+}
+
+func TestWatchTarget_Newest_EmptyDirectory(t *testing.T) {
+	target, err := newWatchTarget(t.TempDir(), watcherOptions{})
+	if err != nil {
+		t.Fatalf("newWatchTarget failed: %v", err)
+	}
+
+	if _, ok := target.newest(); ok {
+		t.Error("expected no newest file in an empty directory")
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Transform steps.
+const (
+	TransformTrimTrailingNewline = "trim-trailing-newline"
+	TransformTrimWhitespace      = "trim-whitespace"
+	TransformLineEndings         = "line-endings"
+	TransformStripANSI           = "strip-ansi"
+	TransformDedent              = "dedent"
+	TransformFirstLines          = "first-lines"
+	TransformLastLines           = "last-lines"
+	TransformRegexReplace        = "regex-replace"
+	TransformFilter              = "filter"
+)
+
+// TransformConfig is one step of the pipeline that file content goes
+// through before it is synced. Which options apply depends on the type.
+type TransformConfig struct {
+	Type string `toml:"type"`
+	// To is the line ending of line-endings: "lf" or "crlf".
+	To string `toml:"to"`
+	// Lines is how many lines first-lines and last-lines keep.
+	Lines int `toml:"lines"`
+	// Pattern and Replacement are the regular expression and its
+	// replacement, which may refer to groups as $1 or ${name}.
+	Pattern     string `toml:"pattern"`
+	Replacement string `toml:"replacement"`
+
+	// Command is the program filter runs, with its arguments. It gets the
+	// text on stdin and prints what to sync on stdout.
+	Command []string      `toml:"command"`
+	Timeout time.Duration `toml:"timeout"`
+	// MaxSize is the most filter output in bytes that is synced.
+	MaxSize int `toml:"max_size"`
+	// OnError is what a failing filter does: FilterOnErrorSkip or
+	// FilterOnErrorRaw.
+	OnError string `toml:"on_error"`
+}
+
+// transform changes text on its way from the file to the clipboard. Only
+// external filters can fail.
+type transform func(ctx context.Context, text string) (string, error)
+
+// textTransform makes a transform of a step that can't fail.
+func textTransform(f func(string) string) transform {
+	return func(_ context.Context, text string) (string, error) {
+		return f(text), nil
+	}
+}
+
+// ansiEscape matches CSI sequences such as colors and cursor movement, OSC
+// sequences such as window titles and hyperlinks, and two character escapes
+// such as saving the cursor.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9:;<=>?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[0-Z\\-_]`)
+
+// newTransformPipeline returns the steps chained in order. Without any it
+// leaves text as it is.
+func newTransformPipeline(steps []TransformConfig) (transform, error) {
+	transforms := make([]transform, 0, len(steps))
+	for i, step := range steps {
+		t, err := newTransform(step)
+		if err != nil {
+			return nil, fmt.Errorf("transform %d: %w", i+1, err)
+		}
+		transforms = append(transforms, t)
+	}
+	return func(ctx context.Context, text string) (string, error) {
+		for i, t := range transforms {
+			var err error
+			if text, err = t(ctx, text); err != nil {
+				return "", fmt.Errorf("transform %d: %w", i+1, err)
+			}
+		}
+		return text, nil
+	}, nil
+}
+
+func newTransform(cfg TransformConfig) (transform, error) {
+	if cfg.Type == "" {
+		return nil, errors.New("no type")
+	}
+	build, ok := transformBuilders[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", cfg.Type)
+	}
+	return build(cfg)
+}
+
+// transformBuilders make the transform of each step type from its options.
+var transformBuilders = map[string]func(cfg TransformConfig) (transform, error){
+	TransformTrimTrailingNewline: fixedTransform(trimTrailingNewline),
+	TransformTrimWhitespace:      fixedTransform(strings.TrimSpace),
+	TransformLineEndings:         newLineEndingsTransform,
+	TransformStripANSI:           fixedTransform(stripANSI),
+	TransformDedent:              fixedTransform(dedent),
+	TransformFirstLines:          newLineCountTransform,
+	TransformLastLines:           newLineCountTransform,
+	TransformRegexReplace:        newRegexReplaceTransform,
+	TransformFilter: func(cfg TransformConfig) (transform, error) {
+		f, err := newFilter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return f.apply, nil
+	},
+}
+
+// fixedTransform builds a step that has no options.
+func fixedTransform(f func(string) string) func(TransformConfig) (transform, error) {
+	return func(TransformConfig) (transform, error) {
+		return textTransform(f), nil
+	}
+}
+
+func newLineEndingsTransform(cfg TransformConfig) (transform, error) {
+	switch cfg.To {
+	case "lf":
+		return textTransform(toLF), nil
+	case "crlf":
+		return textTransform(toCRLF), nil
+	default:
+		return nil, fmt.Errorf("line-endings: unknown line ending %q (use lf or crlf)", cfg.To)
+	}
+}
+
+func newLineCountTransform(cfg TransformConfig) (transform, error) {
+	if cfg.Lines < 1 {
+		return nil, fmt.Errorf("%s: lines must be at least 1", cfg.Type)
+	}
+	if cfg.Type == TransformFirstLines {
+		return textTransform(func(text string) string { return firstLines(text, cfg.Lines) }), nil
+	}
+	return textTransform(func(text string) string { return lastLines(text, cfg.Lines) }), nil
+}
+
+func newRegexReplaceTransform(cfg TransformConfig) (transform, error) {
+	if cfg.Pattern == "" {
+		return nil, errors.New("regex-replace: no pattern")
+	}
+	re, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("regex-replace: %w", err)
+	}
+	return textTransform(func(text string) string { return re.ReplaceAllString(text, cfg.Replacement) }), nil
+}
+
+// trimTrailingNewline drops the newlines editors add at the end of a file,
+// which would run a command pasted into a shell.
+func trimTrailingNewline(text string) string {
+	return strings.TrimRight(text, "\r\n")
+}
+
+func toLF(text string) string {
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
+
+func toCRLF(text string) string {
+	return strings.ReplaceAll(toLF(text), "\n", "\r\n")
+}
+
+func stripANSI(text string) string {
+	return ansiEscape.ReplaceAllString(text, "")
+}
+
+// dedent removes the indentation all non-blank lines share, as when a
+// snippet was copied out of a function body.
+func dedent(text string) string {
+	lines := strings.Split(text, "\n")
+	prefix, found := "", false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if !found {
+			prefix, found = indent, true
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if prefix == "" {
+		return text
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+		if strings.TrimSpace(lines[i]) == "" {
+			lines[i] = strings.TrimLeft(lines[i], " \t")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// firstLines keeps the first n lines. The newline ending the last of them
+// is kept too.
+func firstLines(text string, n int) string {
+	end := 0
+	for i := 0; i < n; i++ {
+		next := strings.IndexByte(text[end:], '\n')
+		if next < 0 {
+			return text
+		}
+		end += next + 1
+	}
+	return text[:end]
+}
+
+// lastLines keeps the last n lines. A newline at the very end doesn't start
+// another line.
+func lastLines(text string, n int) string {
+	start := len(strings.TrimSuffix(text, "\n"))
+	for i := 0; i < n; i++ {
+		prev := strings.LastIndexByte(text[:start], '\n')
+		if prev < 0 {
+			return text
+		}
+		start = prev
+	}
+	return text[start+1:]
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// applyTransform runs a single step on text.
+func applyTransform(t *testing.T, cfg TransformConfig, text string) string {
+	t.Helper()
+	transform, err := newTransform(cfg)
+	if err != nil {
+		t.Fatalf("%s: unexpected error: %v", cfg.Type, err)
+	}
+	out, err := transform(context.Background(), text)
+	if err != nil {
+		t.Fatalf("%s of %q: unexpected error: %v", cfg.Type, text, err)
+	}
+	return out
+}
+
+type transformCase struct {
+	text string
+	want string
+}
+
+func checkTransform(t *testing.T, cfg TransformConfig, cases []transformCase) {
+	t.Helper()
+	for _, tc := range cases {
+		if got := applyTransform(t, cfg, tc.text); got != tc.want {
+			t.Errorf("%s of %q: expected %q, got %q", cfg.Type, tc.text, tc.want, got)
+		}
+	}
+}
+
+func TestTransform_TrimTrailingNewline(t *testing.T) {
+	checkTransform(t, TransformConfig{Type: TransformTrimTrailingNewline}, []transformCase{
+		{"ls -l\n", "ls -l"},
+		{"ls -l\r\n\n", "ls -l"},
+		{"  two\nlines  \n", "  two\nlines  "},
+		{"none", "none"},
+		{"", ""},
+	})
+}
+
+func TestTransform_TrimWhitespace(t *testing.T) {
+	checkTransform(t, TransformConfig{Type: TransformTrimWhitespace}, []transformCase{
+		{"  \t padded \n\n", "padded"},
+		{"\n  two\n lines \n", "two\n lines"},
+		{"   ", ""},
+	})
+}
+
+func TestTransform_LineEndings(t *testing.T) {
+	checkTransform(t, TransformConfig{Type: TransformLineEndings, To: "lf"}, []transformCase{
+		{"a\r\nb\r\n", "a\nb\n"},
+		{"a\nb\r\n", "a\nb\n"},
+		{"lone\rcarriage return", "lone\rcarriage return"},
+	})
+	checkTransform(t, TransformConfig{Type: TransformLineEndings, To: "crlf"}, []transformCase{
+		{"a\nb\n", "a\r\nb\r\n"},
+		{"a\r\nb\n", "a\r\nb\r\n"},
+	})
+	if _, err := newTransform(TransformConfig{Type: TransformLineEndings, To: "cr"}); err == nil {
+		t.Error("expected an error for an unknown line ending")
+	}
+}
+
+func TestTransform_StripANSI(t *testing.T) {
+	checkTransform(t, TransformConfig{Type: TransformStripANSI}, []transformCase{
+		{"\x1b[1;31merror\x1b[0m: failed", "error: failed"},
+		{"\x1b[2K\x1b[1Gprogress", "progress"},
+		{"\x1b]0;title\x07prompt$ ", "prompt$ "},
+		{"\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"\x1b7saved\x1b8", "saved"},
+		{"plain", "plain"},
+	})
+}
+
+func TestTransform_Dedent(t *testing.T) {
+	checkTransform(t, TransformConfig{Type: TransformDedent}, []transformCase{
+		{"    if x {\n        y()\n    }\n", "if x {\n    y()\n}\n"},
+		{"\t\ta\n\n\t\t\tb", "a\n\n\tb"},
+		{"    a\n  \n    b", "a\n\nb"},
+		{"  a\n b", " a\nb"},
+		{"a\n  b", "a\n  b"},
+		{"\t a\n  b", "\t a\n  b"},
+	})
+}
+
+func TestTransform_FirstLines(t *testing.T) {
+	checkTransform(t, TransformConfig{Type: TransformFirstLines, Lines: 2}, []transformCase{
+		{"1\n2\n3\n", "1\n2\n"},
+		{"1\n2", "1\n2"},
+		{"1", "1"},
+		{"", ""},
+	})
+	if _, err := newTransform(TransformConfig{Type: TransformFirstLines}); err == nil {
+		t.Error("expected an error without a line count")
+	}
+}
+
+func TestTransform_LastLines(t *testing.T) {
+	checkTransform(t, TransformConfig{Type: TransformLastLines, Lines: 2}, []transformCase{
+		{"1\n2\n3\n", "2\n3\n"},
+		{"1\n2\n3", "2\n3"},
+		{"1\n2\n", "1\n2\n"},
+		{"", ""},
+	})
+	if _, err := newTransform(TransformConfig{Type: TransformLastLines, Lines: -1}); err == nil {
+		t.Error("expected an error for a negative line count")
+	}
+}
+
+func TestTransform_RegexReplace(t *testing.T) {
+	checkTransform(t, TransformConfig{Type: TransformRegexReplace, Pattern: `(?m)^\$ `}, []transformCase{
+		{"$ make\n$ make test", "make\nmake test"},
+	})
+	checkTransform(t, TransformConfig{Type: TransformRegexReplace, Pattern: `(\w+)@(?P<host>\w+)`, Replacement: "${host}:$1"}, []transformCase{
+		{"me@box", "box:me"},
+	})
+	for _, cfg := range []TransformConfig{
+		{Type: TransformRegexReplace},
+		{Type: TransformRegexReplace, Pattern: "("},
+	} {
+		if _, err := newTransform(cfg); err == nil {
+			t.Errorf("pattern %q: expected an error", cfg.Pattern)
+		}
+	}
+}
+
+func TestNewTransformPipeline_AppliesStepsInOrder(t *testing.T) {
+	pipeline, err := newTransformPipeline([]TransformConfig{
+		{Type: TransformStripANSI},
+		{Type: TransformLastLines, Lines: 1},
+		{Type: TransformTrimTrailingNewline},
+		{Type: TransformRegexReplace, Pattern: `^\$ `},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := pipeline(context.Background(), "output\n\x1b[32m$ \x1b[0mmake test\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "make test" {
+		t.Errorf("expected %q, got %q", "make test", got)
+	}
+
+	none, err := newTransformPipeline(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, _ := none(context.Background(), "as is\n"); got != "as is\n" {
+		t.Errorf("expected text to be left alone, got %q", got)
+	}
+}
+
+func TestNewTransformPipeline_RejectsInvalidSteps(t *testing.T) {
+	for _, steps := range [][]TransformConfig{
+		{{}},
+		{{Type: "upper-case"}},
+		{{Type: TransformTrimWhitespace}, {Type: TransformRegexReplace, Pattern: "["}},
+		{{Type: TransformFilter}},
+		{{Type: TransformFilter, Command: []string{"jq", "."}, OnError: "ignore"}},
+	} {
+		if _, err := newTransformPipeline(steps); err == nil {
+			t.Errorf("%+v: expected an error", steps)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Label identifies the watch in log output.
+func (wc WatchConfig) Label() string {
+	if wc.Name != "" {
+		return wc.Name
+	}
+	return wc.Path
+}
+
+// watchGroup closes several watchers as one.
+type watchGroup []Watcher
+
+func (g watchGroup) Close() error {
+	var errs []error
+	for _, w := range g {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// closeFunc closes by calling itself.
+type closeFunc func()
+
+func (f closeFunc) Close() error {
+	f()
+	return nil
+}
+
+// startWatch runs a single watch: the initial sync, then watchers that sync
+// every change in the configured direction and record it in history, which
+// may be nil. Failures are reported through logger only, so one broken watch
+// never affects the others.
+func startWatch(wc WatchConfig, cb Clipboard, history *History, logger *log.Logger) (Watcher, error) {
+	if wc.Path == "" {
+		return nil, errors.New("no path specified")
+	}
+
+	logger.Printf("Watching file: %s", wc.Path)
+	if len(wc.ClipboardBackends) > 0 && (wc.ClipboardBackend == "fallback" || wc.ClipboardBackend == "fan-out") {
+		logger.Printf("Clipboard backend: %s (%s)", wc.ClipboardBackend, strings.Join(wc.ClipboardBackends, ", "))
+	} else {
+		logger.Printf("Clipboard backend: %s", wc.ClipboardBackend)
+	}
+
+	w, err := newWatch(wc, cb, history, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.seed(); err != nil {
+		return nil, err
+	}
+	return w.start()
+}
+
+// watch is what the two directions of a running watch share.
+type watch struct {
+	wc      WatchConfig
+	cb      Clipboard
+	history *History
+	logger  *log.Logger
+
+	// Files are synced into every selection, and read back from the first
+	selections  []Selection
+	target      *watchTarget
+	pipeline    transform
+	secrets     *secretScanner
+	toClipboard bool
+	toFile      bool
+
+	// Only needed when both directions are active; a nil guard lets
+	// everything through.
+	guard *syncGuard
+
+	// Cancelled on close, which stops a filter still running
+	ctx           context.Context
+	clipboardSync *retryingSync
+
+	// Reports the selections of "both" that the backend lacks, once
+	unsupported sync.Once
+}
+
+// newWatch checks the settings of a watch.
+func newWatch(wc WatchConfig, cb Clipboard, history *History, logger *log.Logger) (*watch, error) {
+	w := &watch{wc: wc, cb: cb, history: history, logger: logger}
+
+	var err error
+	if w.selections, err = wc.Selection.expand(); err != nil {
+		return nil, err
+	}
+	if w.target, err = newWatchTarget(wc.Path, watcherOptions{pick: wc.Pick, ignore: wc.Ignore}); err != nil {
+		return nil, err
+	}
+	if err := checkRepresentations(wc.Representations); err != nil {
+		return nil, err
+	}
+	if w.pipeline, err = newTransformPipeline(wc.Transforms); err != nil {
+		return nil, err
+	}
+	if w.secrets, err = newSecretScanner(wc.Secrets); err != nil {
+		return nil, err
+	}
+
+	switch wc.Direction {
+	case DirectionFileToClipboard, DirectionClipboardToFile, DirectionBoth:
+	default:
+		return nil, fmt.Errorf("unknown direction %q (use file-to-clipboard, clipboard-to-file or both)", wc.Direction)
+	}
+	w.toClipboard = wc.Direction != DirectionClipboardToFile
+	w.toFile = wc.Direction != DirectionFileToClipboard
+	if w.toFile && w.target.multi() {
+		return nil, errors.New("syncing the clipboard into the file needs a single watch file, not a directory or glob")
+	}
+	if w.toClipboard && w.toFile {
+		w.guard = &syncGuard{}
+	}
+	return w, nil
+}
+
+// seed checks the initial sync mode, and seeds the file from the clipboard
+// if asked to. That happens before the file is watched so the write doesn't
+// echo back.
+func (w *watch) seed() error {
+	switch w.wc.InitialSync {
+	case InitialSyncFile, InitialSyncNone:
+		return nil
+	case InitialSyncClipboard:
+	default:
+		return fmt.Errorf("unknown initial sync mode %q (use file, clipboard or none)", w.wc.InitialSync)
+	}
+	if w.target.multi() {
+		return errors.New("initial sync from the clipboard needs a single watch file, not a directory or glob")
+	}
+	if err := SyncClipboardToFile(context.Background(), w.cb, w.selections[0], watchType(w.wc.MIMEType, w.wc.Path), w.wc.Path); err != nil {
+		w.logger.Printf("Failed to seed file from clipboard: %v", err)
+	} else {
+		w.logger.Printf("File seeded from clipboard")
+	}
+	return nil
+}
+
+// start runs the watchers of the configured directions.
+func (w *watch) start() (Watcher, error) {
+	// Syncs are retried in the background, and a newer file content
+	// replaces an older one still waiting for its retry
+	w.clipboardSync = newRetryingSync(w.wc.Retry, w.syncClipboard, func(err error, wait time.Duration) {
+		w.logger.Printf("Failed to sync clipboard, retrying in %s: %v", wait.Round(time.Millisecond), err)
+	}, func(err error) {
+		w.logger.Printf("Failed to sync clipboard: %v", err)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	w.ctx = ctx
+
+	group := watchGroup{closeFunc(cancel)}
+	if w.toClipboard {
+		// The initial sync runs on the watcher's event loop, once it
+		// watches, so no change is missed and none is synced before it
+		opts := append(watcherOptionsFor(w.wc, w.target, w.logger), WithOnStart(w.initialSync))
+		fw, err := NewWatcher(w.wc.Path, w.syncFile, opts...)
+		if err != nil {
+			cancel()
+			_ = w.clipboardSync.Close()
+			return nil, err
+		}
+		group = append(group, fw)
+	}
+	if w.toFile {
+		group = append(group, NewClipboardWatcher(w.cb, w.selections[0], watchType(w.wc.MIMEType, w.wc.Path), w.wc.ClipboardPollInterval, w.clipboardChanged, func(err error) {
+			w.logger.Printf("Failed to read clipboard: %v", err)
+		}))
+	}
+	group = append(group, w.clipboardSync)
+	return group, nil
+}
+
+// initialSync puts the watch file on the clipboard, if asked to. It only
+// runs for a watch that syncs the file to the clipboard, so a one-way
+// clipboard-to-file watch never writes the clipboard.
+func (w *watch) initialSync() {
+	current, ok := w.target.current()
+	if !ok || w.wc.InitialSync != InitialSyncFile {
+		return
+	}
+	content, err := os.ReadFile(current)
+	switch {
+	case err == nil:
+		w.syncFile(string(content))
+	case !errors.Is(err, fs.ErrNotExist):
+		w.logger.Printf("Failed to sync clipboard on startup: %v", err)
+	}
+}
+
+// syncFile hands new file content to the clipboard sync, once it went
+// through the transforms and the secret check.
+func (w *watch) syncFile(content string) {
+	if w.guard.isEcho(content) {
+		return
+	}
+	content, ok := w.prepare(content)
+	if !ok {
+		return
+	}
+	w.guard.mark(content)
+	w.clipboardSync.Submit(content)
+}
+
+// prepare transforms text content and checks anything but images for
+// secrets. It returns false if the content is not to be synced.
+func (w *watch) prepare(content string) (string, bool) {
+	mimeType := contentType(w.wc.MIMEType, w.wc.Path, []byte(content))
+	if isTextType(mimeType) {
+		transformed, err := w.pipeline(w.ctx, content)
+		switch {
+		case err == nil:
+			content = transformed
+		case syncRaw(err):
+			w.logger.Printf("Failed to transform content, syncing it as it is: %v", err)
+		default:
+			w.logger.Printf("Failed to transform content, skipping update: %v", err)
+			return "", false
+		}
+	}
+
+	// Any text may hold secrets, such as JSON or HTML, only images can't
+	if isImageType(mimeType) {
+		return content, true
+	}
+	return w.secrets.check(content, "syncing", w.logger)
+}
+
+// syncClipboard writes file content into every selection and records it.
+func (w *watch) syncClipboard(ctx context.Context, text string) error {
+	defer recoverSync(w.logger)
+	content := Content{Type: contentType(w.wc.MIMEType, w.wc.Path, []byte(text)), Data: []byte(text)}
+	if content.IsText() {
+		content.Alternatives = deriveRepresentations(text, w.wc.Representations, w.target.dir)
+	}
+	// A selection that fails doesn't keep the others from being synced,
+	// and one the backend lacks is only an error if nothing was synced
+	var errs, unsupported []error
+	for _, selection := range w.selections {
+		err := SyncToClipboard(ctx, w.cb, selection, content)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrUnsupportedSelection):
+			unsupported = append(unsupported, fmt.Errorf("%s selection: %w", selection, err))
+		default:
+			errs = append(errs, fmt.Errorf("%s selection: %w", selection, err))
+		}
+	}
+	if len(unsupported) == len(w.selections) {
+		return errors.Join(unsupported...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if len(unsupported) > 0 {
+		w.unsupported.Do(func() {
+			w.logger.Printf("Skipping selections the clipboard backend doesn't support: %v", errors.Join(unsupported...))
+		})
+	}
+	if !content.IsText() {
+		w.logger.Printf("Clipboard updated from file (%s, %d bytes)", content.Type, len(content.Data))
+		return nil
+	}
+	w.logger.Printf("Clipboard updated from file")
+	if err := w.history.Add(HistorySourceFile, w.wc.Label(), text); err != nil {
+		w.logger.Printf("Failed to record history: %v", err)
+	}
+	return nil
+}
+
+// clipboardChanged writes new clipboard content into the file and records
+// it.
+func (w *watch) clipboardChanged(content string) {
+	defer recoverSync(w.logger)
+	if w.guard.isEcho(content) {
+		return
+	}
+	w.guard.mark(content)
+	if err := writeFileIfChanged(w.wc.Path, []byte(content)); err != nil {
+		w.logger.Printf("Failed to update file from clipboard: %v", err)
+		return
+	}
+	w.logger.Printf("File updated from clipboard")
+	if !isTextType(watchType(w.wc.MIMEType, w.wc.Path)) {
+		return
+	}
+	// The file holds what was copied, but the history keeps it around, so
+	// it gets the same check as the clipboard does
+	content, ok := w.secrets.check(content, "recording", w.logger)
+	if !ok {
+		return
+	}
+	if err := w.history.Add(HistorySourceClipboard, w.wc.Label(), content); err != nil {
+		w.logger.Printf("Failed to record history: %v", err)
+	}
+}
+
+func watcherOptionsFor(wc WatchConfig, target *watchTarget, logger *log.Logger) []WatcherOption {
+	watchMode := wc.WatchMode
+	if watchMode == WatchModeAuto {
+		var reason string
+		watchMode, reason = DetectWatchMode(wc.Path)
+		logger.Printf("Watch mode: %s (auto: %s)", watchMode, reason)
+	} else {
+		logger.Printf("Watch mode: %s", watchMode)
+	}
+
+	opts := []WatcherOption{
+		WithWatchMode(watchMode),
+		WithPollInterval(wc.PollInterval),
+		WithPick(wc.Pick),
+		WithIgnore(wc.Ignore...),
+	}
+	if wc.WaitForFile {
+		if !target.exists() {
+			logger.Printf("Watch file does not exist yet, waiting for it to appear")
+		}
+		opts = append(opts, WithWaitForFile())
+	}
+	if wc.Debounce > 0 {
+		opts = append(opts, WithDebounce(wc.Debounce))
+	}
+	if wc.StableCheck {
+		opts = append(opts, WithStableCheck())
+	}
+	return opts
+}
+
+func recoverSync(logger *log.Logger) {
+	if r := recover(); r != nil {
+		logger.Printf("Panic while syncing clipboard: %v", r)
+	}
+}
@@ -0,0 +1,897 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingClipboard is safe for use from watcher goroutines and reports
+// every write on a channel.
+type recordingClipboard struct {
+	mu         sync.Mutex
+	content    map[Selection]string
+	selections []Selection
+	contents   []Content
+	writes     chan string
+}
+
+func newRecordingClipboard(content string) *recordingClipboard {
+	return &recordingClipboard{
+		content: map[Selection]string{SelectionClipboard: content},
+		writes:  make(chan string, 10),
+	}
+}
+
+func (r *recordingClipboard) Read(_ context.Context, selection Selection, mimeType string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return []byte(r.content[selection]), nil
+}
+
+func (r *recordingClipboard) Write(_ context.Context, selection Selection, content Content) error {
+	r.mu.Lock()
+	r.content[selection] = string(content.Data)
+	r.selections = append(r.selections, selection)
+	r.contents = append(r.contents, content)
+	r.mu.Unlock()
+	r.writes <- string(content.Data)
+	return nil
+}
+
+func testWatchConfig(path string) WatchConfig {
+	wc := WatchConfig{Path: path, WatchSettings: DefaultConfig().WatchSettings}
+	wc.WatchMode = WatchModeInotify
+	wc.Debounce = 0
+	return wc
+}
+
+func TestWatchConfig_Label(t *testing.T) {
+	if got := (WatchConfig{Path: "/tmp/a.txt"}).Label(); got != "/tmp/a.txt" {
+		t.Errorf("expected label %q, got %q", "/tmp/a.txt", got)
+	}
+	if got := (WatchConfig{Name: "notes", Path: "/tmp/a.txt"}).Label(); got != "notes" {
+		t.Errorf("expected label %q, got %q", "notes", got)
+	}
+}
+
+func TestStartWatch_SyncsOnStartupAndOnChange(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cb := newRecordingClipboard("")
+	w, err := startWatch(testWatchConfig(watchFile), cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, "initial")
+
+	if err := os.WriteFile(watchFile, []byte("updated"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, cb.writes, "updated")
+}
+
+func TestStartWatch_InitialSyncNone(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.InitialSync = InitialSyncNone
+
+	cb := newRecordingClipboard("")
+	w, err := startWatch(wc, cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	select {
+	case content := <-cb.writes:
+		t.Errorf("expected no write on startup, got %q", content)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestStartWatch_SyncsEverySelection(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Selection = SelectionBoth
+
+	cb := newRecordingClipboard("")
+	w, err := startWatch(wc, cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, "initial")
+	waitForContent(t, cb.writes, "initial")
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	want := []Selection{SelectionClipboard, SelectionPrimary}
+	if !reflect.DeepEqual(cb.selections, want) {
+		t.Errorf("expected writes to %v, got %v", want, cb.selections)
+	}
+}
+
+// failingSelectionClipboard fails every write to one selection.
+type failingSelectionClipboard struct {
+	*recordingClipboard
+	failing Selection
+}
+
+func (f *failingSelectionClipboard) Write(ctx context.Context, selection Selection, content Content) error {
+	if selection == f.failing {
+		return fmt.Errorf("cannot write %s", selection)
+	}
+	return f.recordingClipboard.Write(ctx, selection, content)
+}
+
+func TestStartWatch_SyncsOtherSelectionsWhenOneFails(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Selection = SelectionBoth
+	wc.Retry.MaxAttempts = 1
+
+	cb := &failingSelectionClipboard{recordingClipboard: newRecordingClipboard(""), failing: SelectionClipboard}
+	var logs syncBuffer
+	w, err := startWatch(wc, cb, nil, log.New(&logs, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, "initial")
+
+	cb.mu.Lock()
+	if want := []Selection{SelectionPrimary}; !reflect.DeepEqual(cb.selections, want) {
+		t.Errorf("expected writes to %v, got %v", want, cb.selections)
+	}
+	cb.mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(logs.String(), "clipboard selection: cannot write clipboard") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the failed selection to be logged, got %q", logs.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStartWatch_Both_SkipsSelectionsTheBackendLacks(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	history, err := NewHistory(HistoryConfig{Path: filepath.Join(dir, "history.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Selection = SelectionBoth
+
+	// tmux has no PRIMARY selection
+	writes := make(chan string, 10)
+	cb := &TmuxClipboard{
+		execCommand: func(context.Context, string, ...string) ([]byte, error) {
+			return nil, nil
+		},
+		execCommandWithStdin: func(_ context.Context, _ string, stdin string, _ ...string) error {
+			writes <- stdin
+			return nil
+		},
+	}
+	var logs syncBuffer
+	w, err := startWatch(wc, cb, history, log.New(&logs, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, writes, "initial")
+
+	var entry HistoryEntry
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entry, err = history.Get(1)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("expected the sync to be recorded, got %v", err)
+	}
+	if entry.Content != "initial" {
+		t.Errorf("expected history entry %q, got %q", "initial", entry.Content)
+	}
+	out := logs.String()
+	if !strings.Contains(out, "Skipping selections") || strings.Contains(out, "Failed to sync clipboard") {
+		t.Errorf("expected the missing selection to be skipped, not failed, got:\n%s", out)
+	}
+}
+
+func TestStartWatch_ReturnsErrorForUnknownSelection(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Selection = "tertiary"
+
+	if _, err := startWatch(wc, newRecordingClipboard(""), nil, log.New(&bytes.Buffer{}, "", 0)); err == nil {
+		t.Error("expected error for unknown selection, got nil")
+	}
+}
+
+func TestStartWatch_ReturnsErrorForUnknownInitialSync(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.InitialSync = "sometimes"
+
+	_, err := startWatch(wc, newRecordingClipboard(""), nil, log.New(&bytes.Buffer{}, "", 0))
+	if err == nil {
+		t.Error("expected error for unknown initial sync mode, got nil")
+	}
+}
+
+func TestStartWatch_IsolatesWatchesFromEachOther(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	for _, f := range []string{first, second} {
+		if err := os.WriteFile(f, []byte("initial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var firstLog, secondLog syncBuffer
+	firstWC := testWatchConfig(first)
+	firstWC.InitialSync = InitialSyncNone
+	secondWC := testWatchConfig(second)
+	secondWC.InitialSync = InitialSyncNone
+
+	// The first clipboard panics on every write; the second must keep working
+	panicking := &panickingClipboard{}
+	w1, err := startWatch(firstWC, panicking, nil, log.New(&firstLog, "[first] ", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w1.Close() }()
+
+	cb := newRecordingClipboard("")
+	w2, err := startWatch(secondWC, cb, nil, log.New(&secondLog, "[second] ", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w2.Close() }()
+
+	if err := os.WriteFile(first, []byte("boom"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("fine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, cb.writes, "fine")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(firstLog.String(), "[first] Panic while syncing clipboard") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the panic to be logged with the watch prefix, got %q", firstLog.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type panickingClipboard struct{}
+
+func (p *panickingClipboard) Read(_ context.Context, selection Selection, mimeType string) ([]byte, error) {
+	return nil, nil
+}
+
+func (p *panickingClipboard) Write(context.Context, Selection, Content) error {
+	panic("clipboard exploded")
+}
+
+// syncBuffer lets tests inspect log output written from watcher goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestStartWatch_Directory_SyncsNewestFileOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.txt")
+	if err := os.WriteFile(old, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cb := newRecordingClipboard("")
+	w, err := startWatch(testWatchConfig(dir), cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, "new")
+}
+
+func TestStartWatch_Directory_RejectsInitialSyncFromClipboard(t *testing.T) {
+	wc := testWatchConfig(t.TempDir())
+	wc.InitialSync = InitialSyncClipboard
+
+	_, err := startWatch(wc, newRecordingClipboard(""), nil, log.New(&bytes.Buffer{}, "", 0))
+	if err == nil {
+		t.Error("expected error for seeding a directory from the clipboard, got nil")
+	}
+}
+
+func TestStartWatch_ClipboardToFile_WritesClipboardChanges(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Direction = DirectionClipboardToFile
+	wc.InitialSync = InitialSyncNone
+	wc.ClipboardPollInterval = testClipboardPollInterval
+
+	cb := &pollableClipboard{content: "initial"}
+	w, err := startWatch(wc, cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	cb.set("copied on the host", nil)
+	waitForFileContent(t, watchFile, "copied on the host")
+}
+
+func TestStartWatch_ClipboardToFile_EmptyClipboardLeavesFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		readErr error
+	}{
+		{"cleared", fmt.Errorf("wayland: %w", ErrSelectionEmpty)},
+		{"empty text", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			watchFile := filepath.Join(dir, "test.txt")
+			if err := os.WriteFile(watchFile, []byte("important"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			wc := testWatchConfig(watchFile)
+			wc.Direction = DirectionClipboardToFile
+			wc.InitialSync = InitialSyncNone
+			wc.ClipboardPollInterval = testClipboardPollInterval
+
+			var logs syncBuffer
+			cb := &pollableClipboard{content: "initial"}
+			w, err := startWatch(wc, cb, nil, log.New(&logs, "", 0))
+			if err != nil {
+				t.Fatalf("startWatch failed: %v", err)
+			}
+			defer func() { _ = w.Close() }()
+
+			cb.set("", tt.readErr)
+			time.Sleep(10 * testClipboardPollInterval)
+			if got, _ := os.ReadFile(watchFile); string(got) != "important" {
+				t.Errorf("expected the file to be left alone, got %q", got)
+			}
+			if out := logs.String(); strings.Contains(out, "Failed") {
+				t.Errorf("expected an empty clipboard not to be reported as a failure, got:\n%s", out)
+			}
+
+			cb.set("copied after", nil)
+			waitForFileContent(t, watchFile, "copied after")
+		})
+	}
+}
+
+func TestStartWatch_ClipboardToFile_InitialSyncLeavesClipboard(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("stale file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Direction = DirectionClipboardToFile
+	wc.InitialSync = InitialSyncFile
+	wc.ClipboardPollInterval = testClipboardPollInterval
+
+	cb := &pollableClipboard{content: "host clipboard"}
+	w, err := startWatch(wc, cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	time.Sleep(5 * testClipboardPollInterval)
+	if got, _ := cb.Read(context.Background(), SelectionClipboard, MIMETypeText); string(got) != "host clipboard" {
+		t.Errorf("expected the clipboard to be left alone, got %q", got)
+	}
+}
+
+func TestStartWatch_Both_DoesNotBounceChangesBack(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Direction = DirectionBoth
+	wc.InitialSync = InitialSyncNone
+	wc.ClipboardPollInterval = testClipboardPollInterval
+
+	var logs syncBuffer
+	cb := &pollableClipboard{content: "initial"}
+	w, err := startWatch(wc, cb, nil, log.New(&logs, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	// file -> clipboard
+	if err := os.WriteFile(watchFile, []byte("from file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForClipboardContent(t, cb, "from file")
+
+	// clipboard -> file
+	cb.set("from clipboard", nil)
+	waitForFileContent(t, watchFile, "from clipboard")
+
+	// Let any echo play out, then check each change crossed over exactly once
+	time.Sleep(20 * testClipboardPollInterval)
+	out := logs.String()
+	if n := strings.Count(out, "Clipboard updated from file"); n != 1 {
+		t.Errorf("expected 1 file to clipboard sync, got %d:\n%s", n, out)
+	}
+	if n := strings.Count(out, "File updated from clipboard"); n != 1 {
+		t.Errorf("expected 1 clipboard to file sync, got %d:\n%s", n, out)
+	}
+}
+
+func TestStartWatch_ClipboardToFile_RejectsDirectory(t *testing.T) {
+	wc := testWatchConfig(t.TempDir())
+	wc.Direction = DirectionBoth
+
+	_, err := startWatch(wc, newRecordingClipboard(""), nil, log.New(&bytes.Buffer{}, "", 0))
+	if err == nil {
+		t.Error("expected error for syncing the clipboard into a directory, got nil")
+	}
+}
+
+func TestStartWatch_ReturnsErrorForUnknownDirection(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Direction = "sideways"
+
+	_, err := startWatch(wc, newRecordingClipboard(""), nil, log.New(&bytes.Buffer{}, "", 0))
+	if err == nil {
+		t.Error("expected error for unknown direction, got nil")
+	}
+}
+
+func waitForFileContent(t *testing.T, path, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got, err := os.ReadFile(path)
+		if err == nil && string(got) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("file content is %q, want %q", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitForClipboardContent(t *testing.T, cb Clipboard, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got, err := cb.Read(context.Background(), SelectionClipboard, MIMETypeText)
+		if err == nil && string(got) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("clipboard content is %q, want %q", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStartWatch_SyncsImages(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "screenshot.png")
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	if err := os.WriteFile(watchFile, []byte(png), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	history, err := NewHistory(HistoryConfig{Path: filepath.Join(dir, "history.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb := newRecordingClipboard("")
+	w, err := startWatch(testWatchConfig(watchFile), cb, history, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, png)
+	cb.mu.Lock()
+	contents := cb.contents
+	cb.mu.Unlock()
+	if len(contents) != 1 || contents[0].Type != "image/png" {
+		t.Errorf("expected the image to be written as image/png, got %+v", contents)
+	}
+	// Images are left out of the history, which holds text
+	time.Sleep(50 * time.Millisecond)
+	if _, err := history.Get(1); err == nil {
+		t.Error("expected no history entry for an image")
+	}
+}
+
+func TestStartWatch_OffersRepresentations(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(watchFile, []byte("# Notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Representations = []string{MIMETypeHTML}
+	cb := newRecordingClipboard("")
+	w, err := startWatch(wc, cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, "# Notes")
+	cb.mu.Lock()
+	contents := cb.contents
+	cb.mu.Unlock()
+	want := []Content{{Type: MIMETypeHTML, Data: []byte("<h1>Notes</h1>\n")}}
+	if len(contents) != 1 || !contents[0].IsText() || !reflect.DeepEqual(contents[0].Alternatives, want) {
+		t.Errorf("expected the text with its HTML, got %+v", contents)
+	}
+}
+
+func TestStartWatch_ReturnsErrorForUnknownRepresentation(t *testing.T) {
+	wc := testWatchConfig(filepath.Join(t.TempDir(), "notes.md"))
+	wc.Representations = []string{"application/pdf"}
+
+	if w, err := startWatch(wc, newRecordingClipboard(""), nil, log.New(&bytes.Buffer{}, "", 0)); err == nil {
+		_ = w.Close()
+		t.Error("expected an error for an unknown representation")
+	}
+}
+
+func TestStartWatch_TransformsContent(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "command.sh")
+	if err := os.WriteFile(watchFile, []byte("    make test\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Transforms = []TransformConfig{{Type: TransformDedent}, {Type: TransformTrimTrailingNewline}}
+	cb := newRecordingClipboard("")
+	w, err := startWatch(wc, cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, "make test")
+}
+
+func TestStartWatch_HandlesFailingFilter(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	// Upper-cases the text, and fails for text holding "secret"
+	filter := []string{"sh", "-c", `in=$(cat); case $in in *secret*) exit 1;; esac; printf %s "$in" | tr a-z A-Z`}
+
+	tests := []struct {
+		onError string
+		want    string
+	}{
+		{FilterOnErrorSkip, "LAST"},
+		{FilterOnErrorRaw, "a secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.onError, func(t *testing.T) {
+			watchFile := filepath.Join(t.TempDir(), "test.txt")
+			if err := os.WriteFile(watchFile, []byte("first"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			wc := testWatchConfig(watchFile)
+			wc.Transforms = []TransformConfig{{Type: TransformFilter, Command: filter, OnError: tt.onError}}
+			cb := newRecordingClipboard("")
+			w, err := startWatch(wc, cb, nil, log.New(&bytes.Buffer{}, "", 0))
+			if err != nil {
+				t.Fatalf("startWatch failed: %v", err)
+			}
+			defer func() { _ = w.Close() }()
+
+			waitForContent(t, cb.writes, "FIRST")
+			if err := os.WriteFile(watchFile, []byte("a secret"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.onError == FilterOnErrorSkip {
+				time.Sleep(100 * time.Millisecond)
+				if err := os.WriteFile(watchFile, []byte("last"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			waitForContent(t, cb.writes, tt.want)
+
+			if tt.onError != FilterOnErrorSkip {
+				return
+			}
+			cb.mu.Lock()
+			defer cb.mu.Unlock()
+			for _, content := range cb.contents {
+				if strings.Contains(strings.ToLower(string(content.Data)), "secret") {
+					t.Errorf("expected the failed update to be skipped, got %q", content.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestStartWatch_RedactsSecrets(t *testing.T) {
+	watchFile := filepath.Join(t.TempDir(), "trace.txt")
+	if err := os.WriteFile(watchFile, []byte("401 for token "+testGitHubToken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Secrets = SecretsConfig{Action: SecretActionRedact}
+	cb := newRecordingClipboard("")
+	var logs syncBuffer
+	w, err := startWatch(wc, cb, nil, log.New(&logs, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, "401 for token [REDACTED]")
+	if out := logs.String(); !strings.Contains(out, "GitHub token") || strings.Contains(out, testGitHubToken) {
+		t.Errorf("expected the kind of secret to be logged but not the token, got %q", out)
+	}
+}
+
+func TestStartWatch_RedactsSecretsInOtherTextTypes(t *testing.T) {
+	watchFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(watchFile, []byte(`{"token": "`+testGitHubToken+`"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.MIMEType = "application/json"
+	wc.Secrets = SecretsConfig{Action: SecretActionRedact}
+	cb := newRecordingClipboard("")
+	w, err := startWatch(wc, cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, `{"token": "[REDACTED]"}`)
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if got := cb.contents[len(cb.contents)-1].Type; got != "application/json" {
+		t.Errorf("expected type %q, got %q", "application/json", got)
+	}
+}
+
+func TestStartWatch_BlocksSecrets(t *testing.T) {
+	watchFile := filepath.Join(t.TempDir(), "trace.txt")
+	if err := os.WriteFile(watchFile, []byte("key "+testAWSKeyID), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Secrets = SecretsConfig{Action: SecretActionBlock}
+	cb := newRecordingClipboard("")
+	w, err := startWatch(wc, cb, nil, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := os.WriteFile(watchFile, []byte("clean"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case content := <-cb.writes:
+		if content != "clean" {
+			t.Errorf("expected the content with a secret to be blocked, got %q", content)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("clipboard was not updated within timeout")
+	}
+}
+
+func TestStartWatch_ClipboardToFile_RedactsSecretsInHistory(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	history, err := NewHistory(HistoryConfig{Path: filepath.Join(dir, "history.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Direction = DirectionClipboardToFile
+	wc.InitialSync = InitialSyncNone
+	wc.ClipboardPollInterval = testClipboardPollInterval
+	wc.Secrets = SecretsConfig{Action: SecretActionRedact}
+
+	cb := &pollableClipboard{content: "initial"}
+	w, err := startWatch(wc, cb, history, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	cb.set("token "+testGitHubToken, nil)
+	waitForFileContent(t, watchFile, "token "+testGitHubToken)
+
+	// The entry is recorded just after the file is written
+	var entry HistoryEntry
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entry, err = history.Get(1)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Content != "token [REDACTED]" {
+		t.Errorf("expected the secret to be redacted in history, got %q", entry.Content)
+	}
+}
+
+func TestStartWatch_ReturnsErrorForInvalidSecretsAction(t *testing.T) {
+	wc := testWatchConfig(filepath.Join(t.TempDir(), "a.txt"))
+	wc.Secrets = SecretsConfig{Action: "delete"}
+
+	if w, err := startWatch(wc, newRecordingClipboard(""), nil, log.New(&bytes.Buffer{}, "", 0)); err == nil {
+		_ = w.Close()
+		t.Error("expected an error for an unknown secrets action")
+	}
+}
+
+func TestStartWatch_ReturnsErrorForInvalidTransform(t *testing.T) {
+	wc := testWatchConfig(filepath.Join(t.TempDir(), "a.txt"))
+	wc.Transforms = []TransformConfig{{Type: TransformRegexReplace, Pattern: "("}}
+
+	if w, err := startWatch(wc, newRecordingClipboard(""), nil, log.New(&bytes.Buffer{}, "", 0)); err == nil {
+		_ = w.Close()
+		t.Error("expected an error for an invalid transform")
+	}
+}
+
+func TestStartWatch_RecordsHistory(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	history, err := NewHistory(HistoryConfig{Path: filepath.Join(dir, "history.jsonl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wc := testWatchConfig(watchFile)
+	wc.Name = "notes"
+	cb := newRecordingClipboard("")
+	w, err := startWatch(wc, cb, history, log.New(&bytes.Buffer{}, "", 0))
+	if err != nil {
+		t.Fatalf("startWatch failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	waitForContent(t, cb.writes, "initial")
+
+	// The entry is recorded just after the clipboard write
+	var entry HistoryEntry
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entry, err = history.Get(1)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Content != "initial" || entry.Source != HistorySourceFile || entry.Watch != "notes" {
+		t.Errorf("unexpected history entry %+v", entry)
+	}
+}
@@ -1,56 +1,309 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
 
-	"github.com/fsnotify/fsnotify"
+const (
+	defaultWaitRecheckInterval = time.Second
+	defaultStableCheckInterval = 100 * time.Millisecond
+	defaultPollInterval        = time.Second
 )
 
-type Watcher struct {
-	fsWatcher *fsnotify.Watcher
-	done      chan struct{}
+type Watcher interface {
+	Close() error
 }
 
-func NewWatcher(filePath string, callback func(string)) (*Watcher, error) {
-	fsWatcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
+type WatchMode string
+
+const (
+	WatchModeInotify WatchMode = "inotify"
+	WatchModePoll    WatchMode = "poll"
+	WatchModeAuto    WatchMode = "auto"
+)
+
+type watcherOptions struct {
+	mode         WatchMode
+	pollInterval time.Duration
+	waitForFile  bool
+	debounce     time.Duration
+	stableCheck  bool
+	pick         Pick
+	ignore       []string
+	onStart      func()
+}
+
+type WatcherOption func(*watcherOptions)
+
+// WithWatchMode selects the backend used to notice changes. The default is
+// WatchModeInotify.
+func WithWatchMode(mode WatchMode) WatcherOption {
+	return func(o *watcherOptions) {
+		o.mode = mode
+	}
+}
+
+// WithPollInterval sets how often the polling backend checks the file.
+func WithPollInterval(d time.Duration) WatcherOption {
+	return func(o *watcherOptions) {
+		o.pollInterval = d
+	}
+}
+
+// WithWaitForFile makes the watcher tolerate a missing watch file or parent
+// directory: it waits for them to appear and goes back to waiting if they
+// disappear again.
+func WithWaitForFile() WatcherOption {
+	return func(o *watcherOptions) {
+		o.waitForFile = true
+	}
+}
+
+// WithDebounce delays reading the file until no events have arrived for d, so
+// a burst of writes results in a single read of the final content.
+func WithDebounce(d time.Duration) WatcherOption {
+	return func(o *watcherOptions) {
+		o.debounce = d
+	}
+}
+
+// WithStableCheck additionally waits until the file's size and modification
+// time stop changing between two checks before reading it.
+func WithStableCheck() WatcherOption {
+	return func(o *watcherOptions) {
+		o.stableCheck = true
+	}
+}
+
+// WithPick selects which files of a directory or glob watch are synced. The
+// default is PickNewest.
+func WithPick(pick Pick) WatcherOption {
+	return func(o *watcherOptions) {
+		o.pick = pick
+	}
+}
+
+// WithIgnore adds glob patterns for file names that directory and glob
+// watches skip, on top of hidden, swap and backup files.
+func WithIgnore(patterns ...string) WatcherOption {
+	return func(o *watcherOptions) {
+		o.ignore = append(o.ignore, patterns...)
+	}
+}
+
+// WithOnStart runs f on the watcher's event loop before it handles any
+// change, so nothing f does overlaps the callbacks for those changes.
+func WithOnStart(f func()) WatcherOption {
+	return func(o *watcherOptions) {
+		o.onStart = f
+	}
+}
+
+// NewWatcher watches filePath and calls callback with the new content after
+// every change. filePath may also be a directory or a glob pattern such as
+// /tmp/snippets/*.txt, in which case the files matching it are synced as
+// selected by WithPick.
+func NewWatcher(filePath string, callback func(string), opts ...WatcherOption) (Watcher, error) {
+	o := watcherOptions{
+		mode:         WatchModeInotify,
+		pollInterval: defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	err = fsWatcher.Add(filePath)
+	target, err := newWatchTarget(filePath, o)
 	if err != nil {
-		_ = fsWatcher.Close()
 		return nil, err
 	}
 
-	w := &Watcher{
-		fsWatcher: fsWatcher,
-		done:      make(chan struct{}),
-	}
-
-	go func() {
-		for {
-			select {
-			case event, ok := <-fsWatcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					content, err := os.ReadFile(filePath)
-					if err == nil {
-						callback(string(content))
-					}
-				}
-			case <-w.done:
-				return
-			}
+	mode := o.mode
+	if mode == WatchModeAuto {
+		mode, _ = DetectWatchMode(filePath)
+	}
+
+	switch mode {
+	case WatchModeInotify:
+		return newInotifyWatcher(target, callback, o)
+	case WatchModePoll:
+		return newPollWatcher(target, callback, o)
+	default:
+		return nil, fmt.Errorf("unknown watch mode %q", o.mode)
+	}
+}
+
+// DetectWatchMode picks a backend for the given path, along with a short
+// explanation of why it was chosen. inotify is preferred unless the file lives
+// on a filesystem where change notifications from other machines never arrive.
+func DetectWatchMode(filePath string) (WatchMode, string) {
+	if hasGlobMeta(filepath.Base(filePath)) {
+		filePath = filepath.Dir(filePath)
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		return WatchModePoll, "watch file does not exist yet, so its filesystem is unknown"
+	}
+	if fsType, remote := remoteFilesystem(filePath); remote {
+		return WatchModePoll, fmt.Sprintf("watch file is on a %s filesystem", fsType)
+	}
+	return WatchModeInotify, "watch file is on a local filesystem"
+}
+
+// changeHandler turns change notifications from a backend into callbacks,
+// applying the debounce and stable check options. It is only used from the
+// backend's event loop goroutine.
+type changeHandler struct {
+	target      *watchTarget
+	callback    func(string)
+	onStart     func()
+	debounce    time.Duration
+	stableCheck bool
+
+	settle    *time.Timer
+	lastStat  string
+	pending   []string
+	isPending map[string]bool
+}
+
+func newChangeHandler(target *watchTarget, callback func(string), o watcherOptions) *changeHandler {
+	return &changeHandler{
+		target:      target,
+		callback:    callback,
+		onStart:     o.onStart,
+		debounce:    o.debounce,
+		stableCheck: o.stableCheck,
+		isPending:   make(map[string]bool),
+	}
+}
+
+// start runs the WithOnStart function, before the backend's event loop
+// handles anything else.
+func (h *changeHandler) start() {
+	if h.onStart != nil {
+		h.onStart()
+	}
+}
+
+func (h *changeHandler) pickCreated() bool {
+	return h.target.multi() && h.target.pick == PickCreated
+}
+
+// changed records that path was created or written to. For single file and
+// PickNewest targets the path only serves as a trigger, since what gets read
+// is decided once things settle; an empty path just asks for a re-check.
+func (h *changeHandler) changed(path string, created bool) {
+	if h.pickCreated() {
+		if path == "" || (!created && !h.isPending[path]) {
+			return
 		}
-	}()
+		if !h.isPending[path] {
+			h.isPending[path] = true
+			h.pending = append(h.pending, path)
+		}
+	}
 
-	return w, nil
+	// A freshly created file is usually still empty, so files picked for
+	// being new always wait for a quiet period.
+	if h.debounce <= 0 && !h.stableCheck && !h.pickCreated() {
+		h.emit(h.candidates())
+		return
+	}
+	h.lastStat = ""
+	h.resetSettle()
 }
 
-func (w *Watcher) Close() error {
-	close(w.done)
-	return w.fsWatcher.Close()
+// candidates returns the files that would be read if things settled now.
+func (h *changeHandler) candidates() []string {
+	switch {
+	case !h.target.multi():
+		return []string{h.target.path}
+	case h.pickCreated():
+		return h.pending
+	default:
+		if newest, ok := h.target.newest(); ok {
+			return []string{newest}
+		}
+		return nil
+	}
+}
+
+func (h *changeHandler) settleInterval() time.Duration {
+	if h.debounce > 0 {
+		return h.debounce
+	}
+	return defaultStableCheckInterval
+}
+
+func (h *changeHandler) resetSettle() {
+	if h.settle == nil {
+		h.settle = time.NewTimer(h.settleInterval())
+		return
+	}
+	if !h.settle.Stop() {
+		select {
+		case <-h.settle.C:
+		default:
+		}
+	}
+	h.settle.Reset(h.settleInterval())
+}
+
+func (h *changeHandler) settleC() <-chan time.Time {
+	if h.settle == nil {
+		return nil
+	}
+	return h.settle.C
+}
+
+// settled runs once the quiet period after the last event has passed. With
+// the stable check enabled it keeps waiting while the files are still growing
+// or being touched, even if no events report it (e.g. on network mounts).
+func (h *changeHandler) settled() {
+	paths := h.candidates()
+	if h.stableCheck {
+		stat := statSignature(paths)
+		if stat != h.lastStat {
+			h.lastStat = stat
+			h.resetSettle()
+			return
+		}
+		h.lastStat = ""
+	}
+	h.emit(paths)
+}
+
+// statSignature summarizes the size and modification time of each file, so
+// two calls return the same string only if none of them changed in between.
+func statSignature(paths []string) string {
+	var sig strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&sig, "%s:missing;", path)
+			continue
+		}
+		fmt.Fprintf(&sig, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return sig.String()
+}
+
+func (h *changeHandler) emit(paths []string) {
+	h.pending = nil
+	h.isPending = make(map[string]bool)
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			h.callback(string(content))
+		}
+	}
+}
+
+func (h *changeHandler) stop() {
+	if h.settle != nil {
+		h.settle.Stop()
+	}
 }
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type InotifyWatcher struct {
+	fsWatcher *fsnotify.Watcher
+	done      chan struct{}
+	handler   *changeHandler
+
+	target   *watchTarget
+	resolved string
+	present  bool
+
+	waitForFile         bool
+	waitRecheckInterval time.Duration
+}
+
+func newInotifyWatcher(target *watchTarget, callback func(string), o watcherOptions) (*InotifyWatcher, error) {
+	if !o.waitForFile {
+		if _, err := os.Stat(target.dir); err != nil {
+			return nil, err
+		}
+		if !target.multi() {
+			if _, err := os.Stat(target.path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &InotifyWatcher{
+		fsWatcher:           fsWatcher,
+		done:                make(chan struct{}),
+		handler:             newChangeHandler(target, callback, o),
+		target:              target,
+		waitForFile:         o.waitForFile,
+		waitRecheckInterval: defaultWaitRecheckInterval,
+	}
+
+	// Watch the parent directory rather than the file itself so that editors
+	// which save by renaming a temp file over the target don't orphan the watch.
+	if err := w.watchDirs(); err != nil && !w.waitForFile {
+		_ = fsWatcher.Close()
+		return nil, err
+	}
+	w.present = target.exists()
+
+	go w.run()
+
+	return w, nil
+}
+
+func (w *InotifyWatcher) run() {
+	var recheck <-chan time.Time
+	if w.waitForFile {
+		ticker := time.NewTicker(w.waitRecheckInterval)
+		defer ticker.Stop()
+		recheck = ticker.C
+	}
+	defer w.handler.stop()
+	w.handler.start()
+
+	for {
+		select {
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+		case _, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+		case <-recheck:
+			w.recheck()
+		case <-w.handler.settleC():
+			w.handler.settled()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *InotifyWatcher) handleEvent(event fsnotify.Event) {
+	name := filepath.Clean(event.Name)
+	target := w.target.matches(name) || (w.resolved != "" && name == w.resolved)
+	if !target && !(w.waitForFile && isPathOrAncestor(name, w.target.dir)) {
+		return
+	}
+
+	// The target may have been replaced by a different file or symlink, and
+	// in wait mode a directory on the way to it may have come or gone, so
+	// re-resolve whenever a directory entry changes.
+	appeared := false
+	if event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove) {
+		_ = w.watchDirs()
+		appeared = w.updatePresence()
+	}
+
+	switch {
+	case target && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create)):
+		w.handler.changed(name, event.Has(fsnotify.Create))
+	case appeared:
+		w.handler.changed("", false)
+	}
+}
+
+// recheck catches changes that produce no inotify events on the watched
+// directories, such as a filesystem being mounted over or unmounted from them.
+func (w *InotifyWatcher) recheck() {
+	if w.target.exists() == w.present {
+		return
+	}
+
+	// Watches on the old directory inodes are useless after a mount change,
+	// so start over with fresh ones.
+	for _, dir := range w.fsWatcher.WatchList() {
+		_ = w.fsWatcher.Remove(dir)
+	}
+	_ = w.watchDirs()
+	if w.updatePresence() {
+		w.handler.changed("", false)
+	}
+}
+
+func (w *InotifyWatcher) updatePresence() bool {
+	wasPresent := w.present
+	w.present = w.target.exists()
+	return w.present && !wasPresent
+}
+
+// watchDirs brings the set of watched directories in line with the current
+// state of the filesystem: the target's directory (or, in wait mode, its
+// closest existing ancestor) plus the directory of a watch file's symlink
+// target.
+func (w *InotifyWatcher) watchDirs() error {
+	dir := w.target.dir
+	if w.waitForFile {
+		dir = closestExistingDir(dir)
+	}
+	wanted := map[string]bool{dir: true}
+
+	w.resolved = ""
+	if !w.target.multi() {
+		if resolved, err := filepath.EvalSymlinks(w.target.path); err == nil && resolved != w.target.path {
+			w.resolved = resolved
+			wanted[filepath.Dir(resolved)] = true
+		}
+	}
+
+	// fsnotify drops watches on directories that get deleted, so its own
+	// list is the source of truth for what is still being watched.
+	watched := make(map[string]bool)
+	for _, dir := range w.fsWatcher.WatchList() {
+		if !wanted[dir] {
+			_ = w.fsWatcher.Remove(dir)
+			continue
+		}
+		watched[dir] = true
+	}
+
+	var firstErr error
+	for dir := range wanted {
+		if watched[dir] {
+			continue
+		}
+		if err := w.fsWatcher.Add(dir); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func closestExistingDir(dir string) string {
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+func isPathOrAncestor(path, of string) bool {
+	rel, err := filepath.Rel(path, of)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (w *InotifyWatcher) Close() error {
+	close(w.done)
+	return w.fsWatcher.Close()
+}
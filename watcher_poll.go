@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+// PollWatcher notices changes by periodically stat'ing the watch file, or the
+// files of a directory or glob watch. It is meant for network and FUSE
+// filesystems where inotify events for changes made on another machine never
+// arrive.
+type PollWatcher struct {
+	done     chan struct{}
+	handler  *changeHandler
+	target   *watchTarget
+	interval time.Duration
+	last     map[string]os.FileInfo
+}
+
+func newPollWatcher(target *watchTarget, callback func(string), o watcherOptions) (*PollWatcher, error) {
+	last, err := snapshot(target)
+	if err != nil && !o.waitForFile {
+		return nil, err
+	}
+
+	interval := o.pollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	w := &PollWatcher{
+		done:     make(chan struct{}),
+		handler:  newChangeHandler(target, callback, o),
+		target:   target,
+		interval: interval,
+		last:     last,
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// snapshot stats everything the target currently covers.
+func snapshot(target *watchTarget) (map[string]os.FileInfo, error) {
+	if target.multi() {
+		return target.files()
+	}
+	info, err := os.Stat(target.path)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]os.FileInfo{target.path: info}, nil
+}
+
+func (w *PollWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	defer w.handler.stop()
+	w.handler.start()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.poll()
+		case <-w.handler.settleC():
+			w.handler.settled()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *PollWatcher) poll() {
+	current, err := snapshot(w.target)
+	if err != nil {
+		w.last = nil
+		return
+	}
+
+	// A directory that just appeared is a new baseline rather than a batch
+	// of newly created files.
+	if w.last == nil && w.target.multi() {
+		w.last = current
+		w.handler.changed("", false)
+		return
+	}
+
+	// Report changes oldest first so new files are picked in creation order
+	paths := make([]string, 0, len(current))
+	for path := range current {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return current[paths[i]].ModTime().Before(current[paths[j]].ModTime())
+	})
+
+	for _, path := range paths {
+		info := current[path]
+		prev, existed := w.last[path]
+		if !existed || fileChanged(prev, info) {
+			w.handler.changed(path, !existed)
+		}
+	}
+	w.last = current
+}
+
+// fileChanged reports whether the file described by cur differs from prev,
+// including being replaced by a different file of the same size and mtime.
+func fileChanged(prev, cur os.FileInfo) bool {
+	return cur.Size() != prev.Size() || !cur.ModTime().Equal(prev.ModTime()) || !os.SameFile(prev, cur)
+}
+
+func (w *PollWatcher) Close() error {
+	close(w.done)
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testPollInterval = 20 * time.Millisecond
+
+func TestPollWatcher_CallsCallbackOnFileChange(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	}, WithWatchMode(WatchModePoll), WithPollInterval(testPollInterval))
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if _, ok := w.(*PollWatcher); !ok {
+		t.Fatalf("expected *PollWatcher, got %T", w)
+	}
+
+	// A different length is noticed even on filesystems with coarse mtimes
+	if err := os.WriteFile(watchFile, []byte("updated content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "updated content")
+}
+
+func TestPollWatcher_DetectsReplacementWithSameSizeAndMtime(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("aaaa"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(watchFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	}, WithWatchMode(WatchModePoll), WithPollInterval(testPollInterval))
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	tmpFile := filepath.Join(dir, "test.txt.tmp")
+	if err := os.WriteFile(tmpFile, []byte("bbbb"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(tmpFile, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpFile, watchFile); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "bbbb")
+}
+
+func TestPollWatcher_ReturnsErrorForNonExistentFile(t *testing.T) {
+	_, err := NewWatcher("/nonexistent/path/file.txt", func(string) {}, WithWatchMode(WatchModePoll))
+	if err == nil {
+		t.Error("expected error for non-existent file, got nil")
+	}
+}
+
+func TestPollWatcher_WaitForFile_SyncsWhenFileAppears(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "nested", "test.txt")
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	}, WithWatchMode(WatchModePoll), WithPollInterval(testPollInterval), WithWaitForFile())
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(watchFile, []byte("appeared"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "appeared")
+}
+
+func TestPollWatcher_Close_StopsWatching(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	}, WithWatchMode(WatchModePoll), WithPollInterval(testPollInterval))
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if err := os.WriteFile(watchFile, []byte("after close"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-called:
+		t.Error("callback was called after Close")
+	case <-time.After(5 * testPollInterval):
+	}
+}
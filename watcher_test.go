@@ -44,6 +44,52 @@ func TestWatcher_CallsCallbackOnFileChange(t *testing.T) {
 	}
 }
 
+func TestWatcher_OnStart_RunsBeforeChanges(t *testing.T) {
+	for _, mode := range []WatchMode{WatchModeInotify, WatchModePoll} {
+		t.Run(string(mode), func(t *testing.T) {
+			dir := t.TempDir()
+			watchFile := filepath.Join(dir, "test.txt")
+			if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			started, release := make(chan struct{}), make(chan struct{})
+			called := make(chan string, 10)
+			w, err := NewWatcher(watchFile, func(content string) { called <- content },
+				WithWatchMode(mode),
+				WithPollInterval(10*time.Millisecond),
+				WithOnStart(func() {
+					close(started)
+					<-release
+				}))
+			if err != nil {
+				t.Fatalf("NewWatcher failed: %v", err)
+			}
+			defer func() { _ = w.Close() }()
+
+			<-started
+			if err := os.WriteFile(watchFile, []byte("updated"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case content := <-called:
+				t.Fatalf("expected no callback while starting, got %q", content)
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			close(release)
+			select {
+			case content := <-called:
+				if content != "updated" {
+					t.Errorf("expected content %q, got %q", "updated", content)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("callback was not called within timeout")
+			}
+		})
+	}
+}
+
 func TestNewWatcher_ReturnsErrorForNonExistentFile(t *testing.T) {
 	_, err := NewWatcher("/nonexistent/path/file.txt", func(string) {})
 	if err == nil {
@@ -86,3 +132,501 @@ func TestWatcher_Close_StopsWatching(t *testing.T) {
 		// Expected - no callback after close
 	}
 }
+
+func waitForContent(t *testing.T, called <-chan string, want string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case content := <-called:
+			if content == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("callback was not called with %q within timeout", want)
+		}
+	}
+}
+
+func TestWatcher_SurvivesRenameOver(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	// Save the way vim and VS Code do: write a temp file, rename it over the target
+	for _, content := range []string{"first save", "second save"} {
+		tmpFile := filepath.Join(dir, ".test.txt.tmp")
+		if err := os.WriteFile(tmpFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmpFile, watchFile); err != nil {
+			t.Fatal(err)
+		}
+		waitForContent(t, called, content)
+	}
+}
+
+func TestWatcher_SurvivesDeleteAndRecreate(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := os.Remove(watchFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(watchFile, []byte("recreated"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "recreated")
+
+	// Writes to the new file must still be picked up
+	if err := os.WriteFile(watchFile, []byte("written again"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "written again")
+}
+
+func TestWatcher_SurvivesMvReplacement(t *testing.T) {
+	dir := t.TempDir()
+	otherDir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	// Move the old file away, then move a replacement in from elsewhere
+	if err := os.Rename(watchFile, filepath.Join(otherDir, "old.txt")); err != nil {
+		t.Fatal(err)
+	}
+	replacement := filepath.Join(otherDir, "replacement.txt")
+	if err := os.WriteFile(replacement, []byte("replacement"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replacement, watchFile); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "replacement")
+
+	if err := os.WriteFile(watchFile, []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "edited")
+}
+
+func TestWatcher_IgnoresOtherFilesInDirectory(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := os.WriteFile(filepath.Join(dir, "other.txt"), []byte("other"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case content := <-called:
+		t.Errorf("callback was called for another file with %q", content)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNewWatcher_WaitForFile_AllowsNonExistentFile(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "missing", "nested", "test.txt")
+
+	w, err := NewWatcher(watchFile, func(string) {}, WithWaitForFile())
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	_ = w.Close()
+}
+
+func TestWatcher_WaitForFile_SyncsWhenFileAppears(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	}, WithWaitForFile())
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := os.WriteFile(watchFile, []byte("appeared"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "appeared")
+}
+
+func TestWatcher_WaitForFile_SyncsWhenParentDirectoriesAppear(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "a", "b", "test.txt")
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	}, WithWaitForFile())
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(watchFile, []byte("nested"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "nested")
+
+	if err := os.WriteFile(watchFile, []byte("nested update"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "nested update")
+}
+
+func TestWatcher_WaitForFile_WaitsAgainAfterDirectoryRemoved(t *testing.T) {
+	dir := t.TempDir()
+	subDir := filepath.Join(dir, "share")
+	watchFile := filepath.Join(subDir, "test.txt")
+
+	if err := os.Mkdir(subDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(watchFile, []byte("initial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	}, WithWaitForFile())
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := os.RemoveAll(subDir); err != nil {
+		t.Fatal(err)
+	}
+	// Give the watcher a moment to fall back to the parent directory
+	time.Sleep(50 * time.Millisecond)
+
+	if err := os.Mkdir(subDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(watchFile, []byte("back again"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "back again")
+}
+
+func TestWatcher_Debounce_CoalescesBurstOfWrites(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	}, WithDebounce(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	// Write the file in several chunks, the way a slow script would
+	f, err := os.OpenFile(watchFile, os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range []string{"one ", "two ", "three"} {
+		if _, err := f.WriteString(chunk); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case content := <-called:
+		if content != "one two three" {
+			t.Errorf("expected content %q, got %q", "one two three", content)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback was not called within timeout")
+	}
+
+	select {
+	case content := <-called:
+		t.Errorf("expected a single callback, got another with %q", content)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatcher_StableCheck_WaitsForFileToStopChanging(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(watchFile, func(content string) {
+		called <- content
+	}, WithDebounce(30*time.Millisecond), WithStableCheck())
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := os.WriteFile(watchFile, []byte("final"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	select {
+	case content := <-called:
+		if content != "final" {
+			t.Errorf("expected content %q, got %q", "final", content)
+		}
+		// One quiet period to settle plus one more to confirm the file is stable
+		if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+			t.Errorf("expected callback after stability check, got it after %v", elapsed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback was not called within timeout")
+	}
+}
+
+func TestNewWatcher_DefaultsToInotify(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWatcher(watchFile, func(string) {})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if _, ok := w.(*InotifyWatcher); !ok {
+		t.Errorf("expected *InotifyWatcher, got %T", w)
+	}
+}
+
+func TestNewWatcher_ReturnsErrorForUnknownWatchMode(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewWatcher(watchFile, func(string) {}, WithWatchMode("fanotify"))
+	if err == nil {
+		t.Error("expected error for unknown watch mode, got nil")
+	}
+}
+
+func TestDetectWatchMode_PollsWhenFileDoesNotExist(t *testing.T) {
+	mode, reason := DetectWatchMode("/nonexistent/path/file.txt")
+	if mode != WatchModePoll {
+		t.Errorf("expected %q, got %q", WatchModePoll, mode)
+	}
+	if reason == "" {
+		t.Error("expected a reason")
+	}
+}
+
+func TestDetectWatchMode_UsesInotifyOnLocalFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	watchFile := filepath.Join(dir, "test.txt")
+
+	err := os.WriteFile(watchFile, []byte("initial"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsType, remote := remoteFilesystem(watchFile); remote {
+		t.Skipf("temp dir is on a %s filesystem", fsType)
+	}
+
+	mode, _ := DetectWatchMode(watchFile)
+	if mode != WatchModeInotify {
+		t.Errorf("expected %q, got %q", WatchModeInotify, mode)
+	}
+}
+
+func TestWatcher_Directory_SyncsNewestFile(t *testing.T) {
+	for _, mode := range []WatchMode{WatchModeInotify, WatchModePoll} {
+		t.Run(string(mode), func(t *testing.T) {
+			dir := t.TempDir()
+			old := filepath.Join(dir, "a-old.txt")
+			if err := os.WriteFile(old, []byte("old"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			past := time.Now().Add(-time.Hour)
+			if err := os.Chtimes(old, past, past); err != nil {
+				t.Fatal(err)
+			}
+
+			called := make(chan string, 10)
+			w, err := NewWatcher(dir, func(content string) {
+				called <- content
+			}, WithWatchMode(mode), WithPollInterval(testPollInterval), WithDebounce(20*time.Millisecond))
+			if err != nil {
+				t.Fatalf("NewWatcher failed: %v", err)
+			}
+			defer func() { _ = w.Close() }()
+
+			if err := os.WriteFile(filepath.Join(dir, "b-new.txt"), []byte("new snippet"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			waitForContent(t, called, "new snippet")
+
+			// Editor swap files never win, even though they are newer
+			if err := os.WriteFile(filepath.Join(dir, ".b-new.txt.swp"), []byte("swap"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case content := <-called:
+				if content != "new snippet" {
+					t.Errorf("expected swap file to be ignored, got %q", content)
+				}
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestWatcher_Glob_OnlySyncsMatchingFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	called := make(chan string, 10)
+	w, err := NewWatcher(filepath.Join(dir, "*.txt"), func(content string) {
+		called <- content
+	}, WithDebounce(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err := os.WriteFile(filepath.Join(dir, "notes.md"), []byte("markdown"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case content := <-called:
+		t.Errorf("expected non-matching file to be ignored, got %q", content)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "snippet.txt"), []byte("text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForContent(t, called, "text")
+}
+
+func TestWatcher_PickCreated_SyncsEachNewFile(t *testing.T) {
+	for _, mode := range []WatchMode{WatchModeInotify, WatchModePoll} {
+		t.Run(string(mode), func(t *testing.T) {
+			dir := t.TempDir()
+			existing := filepath.Join(dir, "existing.txt")
+			if err := os.WriteFile(existing, []byte("existing"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			called := make(chan string, 10)
+			w, err := NewWatcher(dir, func(content string) {
+				called <- content
+			}, WithWatchMode(mode), WithPollInterval(testPollInterval), WithPick(PickCreated))
+			if err != nil {
+				t.Fatalf("NewWatcher failed: %v", err)
+			}
+			defer func() { _ = w.Close() }()
+
+			// Modifying an existing file is not a new snippet
+			if err := os.WriteFile(existing, []byte("existing, edited"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case content := <-called:
+				t.Errorf("expected edits to existing files to be ignored, got %q", content)
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			for _, name := range []string{"snippet-1.txt", "snippet-2.txt"} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				waitForContent(t, called, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+//go:build unix
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// The Wayland protocol is a stream of messages, each an 8-byte header (the
+// object id, then the message size and opcode) followed by 32-bit aligned
+// arguments in host byte order. File descriptors travel out of band as
+// SCM_RIGHTS ancillary data.
+const (
+	wlHeaderSize     = 8
+	wlMaxMessageSize = 4096
+	wlMaxFDs         = 28
+)
+
+// wlConn frames Wayland messages on a unix socket. It is used by the native
+// Wayland clipboard and, in tests, by the stand-in compositor.
+type wlConn struct {
+	conn *net.UnixConn
+	in   []byte
+	fds  []int
+}
+
+func newWLConn(conn *net.UnixConn) *wlConn {
+	return &wlConn{conn: conn}
+}
+
+// send writes one message. Arguments may be uint32 (also used for object and
+// new ids), string or *os.File, which is passed as a file descriptor. The
+// caller keeps ownership of any files.
+func (c *wlConn) send(object uint32, opcode uint16, args ...any) error {
+	msg := make([]byte, wlHeaderSize, 64)
+	var fds []int
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case uint32:
+			msg = binary.NativeEndian.AppendUint32(msg, arg)
+		case string:
+			// Strings are NUL terminated, and the length includes the NUL
+			msg = binary.NativeEndian.AppendUint32(msg, uint32(len(arg)+1))
+			msg = append(msg, arg...)
+			msg = append(msg, make([]byte, 4-len(arg)%4)...)
+		case *os.File:
+			fds = append(fds, int(arg.Fd()))
+		default:
+			return fmt.Errorf("wayland: unsupported argument type %T", arg)
+		}
+	}
+	if len(msg) > wlMaxMessageSize {
+		return fmt.Errorf("wayland: message of %d bytes is too large", len(msg))
+	}
+	binary.NativeEndian.PutUint32(msg, object)
+	binary.NativeEndian.PutUint32(msg[4:], uint32(len(msg))<<16|uint32(opcode))
+
+	var oob []byte
+	if len(fds) > 0 {
+		oob = unix.UnixRights(fds...)
+	}
+	_, _, err := c.conn.WriteMsgUnix(msg, oob, nil)
+	return err
+}
+
+// next blocks until a whole message has arrived.
+func (c *wlConn) next() (*wlMessage, error) {
+	for {
+		if len(c.in) >= wlHeaderSize {
+			word := binary.NativeEndian.Uint32(c.in[4:])
+			size := int(word >> 16)
+			if size < wlHeaderSize || size%4 != 0 {
+				return nil, fmt.Errorf("wayland: invalid message size %d", size)
+			}
+			if len(c.in) >= size {
+				m := &wlMessage{
+					object: binary.NativeEndian.Uint32(c.in),
+					opcode: uint16(word),
+					body:   append([]byte(nil), c.in[wlHeaderSize:size]...),
+					conn:   c,
+				}
+				c.in = c.in[size:]
+				return m, nil
+			}
+		}
+
+		buf := make([]byte, wlMaxMessageSize)
+		oob := make([]byte, unix.CmsgSpace(wlMaxFDs*4))
+		n, oobn, _, _, err := c.conn.ReadMsgUnix(buf, oob)
+		if err != nil {
+			return nil, err
+		}
+		if oobn > 0 {
+			c.fds = append(c.fds, parseRights(oob[:oobn])...)
+		}
+		if n == 0 {
+			return nil, io.EOF
+		}
+		c.in = append(c.in, buf[:n]...)
+	}
+}
+
+func parseRights(oob []byte) []int {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil
+	}
+	var fds []int
+	for i := range msgs {
+		if rights, err := unix.ParseUnixRights(&msgs[i]); err == nil {
+			fds = append(fds, rights...)
+		}
+	}
+	return fds
+}
+
+// Close closes the socket and any file descriptors that were received but
+// never claimed.
+func (c *wlConn) Close() error {
+	for _, fd := range c.fds {
+		_ = unix.Close(fd)
+	}
+	c.fds = nil
+	return c.conn.Close()
+}
+
+// wlMessage is a received message whose arguments are read in order. A
+// malformed body makes every later read return the zero value and sets err.
+type wlMessage struct {
+	object uint32
+	opcode uint16
+	body   []byte
+	conn   *wlConn
+	err    error
+}
+
+func (m *wlMessage) uint() uint32 {
+	if len(m.body) < 4 {
+		m.fail()
+		return 0
+	}
+	v := binary.NativeEndian.Uint32(m.body)
+	m.body = m.body[4:]
+	return v
+}
+
+func (m *wlMessage) string() string {
+	n := int(m.uint())
+	padded := (n + 3) &^ 3
+	if n == 0 || padded > len(m.body) {
+		m.fail()
+		return ""
+	}
+	s := string(m.body[:n-1])
+	m.body = m.body[padded:]
+	return s
+}
+
+// file claims the next file descriptor received on the connection.
+func (m *wlMessage) file() *os.File {
+	if len(m.conn.fds) == 0 {
+		m.fail()
+		return nil
+	}
+	fd := m.conn.fds[0]
+	m.conn.fds = m.conn.fds[1:]
+	return os.NewFile(uintptr(fd), "wayland-fd")
+}
+
+func (m *wlMessage) fail() {
+	if m.err == nil {
+		m.err = errors.New("wayland: malformed message")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The native X11 clipboard speaks the core X11 protocol in little-endian byte
+// order. Requests are a 4-byte header (opcode, one byte of data, length in
+// 4-byte units) followed by the body. Everything the server sends is 32
+// bytes, with replies optionally followed by more data.
+const (
+	x11OpCreateWindow           = 1
+	x11OpChangeWindowAttributes = 2
+	x11OpInternAtom             = 16
+	x11OpChangeProperty         = 18
+	x11OpDeleteProperty         = 19
+	x11OpGetProperty            = 20
+	x11OpSetSelectionOwner      = 22
+	x11OpGetSelectionOwner      = 23
+	x11OpConvertSelection       = 24
+	x11OpSendEvent              = 25
+
+	x11ResponseError = 0
+	x11ResponseReply = 1
+
+	x11EventPropertyNotify   = 28
+	x11EventSelectionClear   = 29
+	x11EventSelectionRequest = 30
+	x11EventSelectionNotify  = 31
+
+	x11PropertyNewValue = 0
+	x11PropertyDeleted  = 1
+
+	x11CWEventMask          = 0x800
+	x11PropertyChangeMask   = 0x400000
+	x11WindowClassInputOnly = 2
+	x11PropModeReplace      = 0
+
+	// Predefined atoms
+	x11AtomPrimary   = 1
+	x11AtomSecondary = 2
+	x11AtomAtom      = 4
+	x11AtomString    = 31
+)
+
+const xauthMagicCookie = "MIT-MAGIC-COOKIE-1"
+
+// x11Request builds a request. Lengths and padding are filled in by encode.
+type x11Request struct {
+	buf []byte
+}
+
+func newX11Request(opcode, data byte) *x11Request {
+	return &x11Request{buf: []byte{opcode, data, 0, 0}}
+}
+
+func (r *x11Request) u16(v uint16) *x11Request {
+	r.buf = binary.LittleEndian.AppendUint16(r.buf, v)
+	return r
+}
+
+func (r *x11Request) u32(v uint32) *x11Request {
+	r.buf = binary.LittleEndian.AppendUint32(r.buf, v)
+	return r
+}
+
+// bytes appends b padded to a multiple of 4 bytes.
+func (r *x11Request) bytes(b []byte) *x11Request {
+	r.buf = append(r.buf, b...)
+	r.buf = append(r.buf, make([]byte, pad4(len(b))-len(b))...)
+	return r
+}
+
+func (r *x11Request) encode() []byte {
+	binary.LittleEndian.PutUint16(r.buf[2:], uint16(len(r.buf)/4))
+	return r.buf
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// x11Display is a parsed $DISPLAY.
+type x11Display struct {
+	network string
+	address string
+	number  string
+}
+
+func parseX11Display(display string) (x11Display, error) {
+	if display == "" {
+		return x11Display{}, fmt.Errorf("x11: %w: DISPLAY is not set", ErrNoDisplay)
+	}
+
+	// A path, as used by XQuartz, is the socket itself
+	if strings.HasPrefix(display, "/") {
+		colon := strings.LastIndexByte(display, ':')
+		if colon < 0 {
+			return x11Display{}, fmt.Errorf("x11: invalid DISPLAY %q", display)
+		}
+		number, _, _ := strings.Cut(display[colon+1:], ".")
+		socket := display[:colon+1] + number
+		return x11Display{network: "unix", address: socket, number: number}, nil
+	}
+
+	colon := strings.LastIndexByte(display, ':')
+	if colon < 0 {
+		return x11Display{}, fmt.Errorf("x11: invalid DISPLAY %q", display)
+	}
+	host := display[:colon]
+	number, _, _ := strings.Cut(display[colon+1:], ".")
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return x11Display{}, fmt.Errorf("x11: invalid DISPLAY %q", display)
+	}
+
+	if host == "" || host == "unix" {
+		return x11Display{network: "unix", address: "/tmp/.X11-unix/X" + number, number: number}, nil
+	}
+	return x11Display{network: "tcp", address: net.JoinHostPort(host, strconv.Itoa(6000+n)), number: number}, nil
+}
+
+// x11Setup is the part of the server's connection setup reply that the
+// clipboard needs.
+type x11Setup struct {
+	resourceIDBase   uint32
+	resourceIDMask   uint32
+	maxRequestLength int
+	root             uint32
+}
+
+func dialX11(display string) (net.Conn, *bufio.Reader, x11Setup, error) {
+	d, err := parseX11Display(display)
+	if err != nil {
+		return nil, nil, x11Setup{}, err
+	}
+	conn, err := net.Dial(d.network, d.address)
+	if err != nil {
+		return nil, nil, x11Setup{}, fmt.Errorf("x11: %w: %w", ErrNoDisplay, err)
+	}
+
+	authName, authData := xauthCookie(d)
+	r := bufio.NewReader(conn)
+	setup, err := x11Handshake(conn, r, authName, authData)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, x11Setup{}, err
+	}
+	return conn, r, setup, nil
+}
+
+func x11Handshake(w io.Writer, r io.Reader, authName string, authData []byte) (x11Setup, error) {
+	req := []byte{'l', 0}
+	req = binary.LittleEndian.AppendUint16(req, 11)
+	req = binary.LittleEndian.AppendUint16(req, 0)
+	req = binary.LittleEndian.AppendUint16(req, uint16(len(authName)))
+	req = binary.LittleEndian.AppendUint16(req, uint16(len(authData)))
+	req = append(req, 0, 0)
+	req = append(req, authName...)
+	req = append(req, make([]byte, pad4(len(authName))-len(authName))...)
+	req = append(req, authData...)
+	req = append(req, make([]byte, pad4(len(authData))-len(authData))...)
+	if _, err := w.Write(req); err != nil {
+		return x11Setup{}, fmt.Errorf("x11: %w", err)
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return x11Setup{}, fmt.Errorf("x11: reading setup: %w", err)
+	}
+	extra := make([]byte, int(binary.LittleEndian.Uint16(header[6:]))*4)
+	if _, err := io.ReadFull(r, extra); err != nil {
+		return x11Setup{}, fmt.Errorf("x11: reading setup: %w", err)
+	}
+
+	switch header[0] {
+	case 1:
+	case 0:
+		reason := extra[:min(int(header[1]), len(extra))]
+		return x11Setup{}, fmt.Errorf("x11: connection refused: %s", reason)
+	default:
+		return x11Setup{}, fmt.Errorf("x11: connection refused: %s", strings.TrimRight(string(extra), "\x00"))
+	}
+
+	if len(extra) < 32 {
+		return x11Setup{}, errors.New("x11: setup reply too short")
+	}
+	vendorLen := int(binary.LittleEndian.Uint16(extra[16:]))
+	numFormats := int(extra[21])
+	screen := 32 + pad4(vendorLen) + 8*numFormats
+	if extra[20] == 0 || len(extra) < screen+4 {
+		return x11Setup{}, errors.New("x11: the server has no screens")
+	}
+	return x11Setup{
+		resourceIDBase:   binary.LittleEndian.Uint32(extra[4:]),
+		resourceIDMask:   binary.LittleEndian.Uint32(extra[8:]),
+		maxRequestLength: int(binary.LittleEndian.Uint16(extra[18:])) * 4,
+		root:             binary.LittleEndian.Uint32(extra[screen:]),
+	}, nil
+}
+
+// xauthCookie looks up the MIT-MAGIC-COOKIE-1 for the display in the
+// Xauthority file. Without one the connection is attempted without
+// authorization, which servers started with -ac or xhost accept.
+func xauthCookie(d x11Display) (string, []byte) {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		path = filepath.Join(home, ".Xauthority")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil
+	}
+
+	hostname, _ := os.Hostname()
+	for _, entry := range parseXauthority(data) {
+		if entry.name != xauthMagicCookie || (entry.number != d.number && entry.number != "") {
+			continue
+		}
+		if entry.family == xauthFamilyWild || (entry.family == xauthFamilyLocal && entry.address == hostname) {
+			return entry.name, entry.data
+		}
+	}
+	return "", nil
+}
+
+const (
+	xauthFamilyLocal = 256
+	xauthFamilyWild  = 65535
+)
+
+type xauthEntry struct {
+	family  uint16
+	address string
+	number  string
+	name    string
+	data    []byte
+}
+
+// parseXauthority reads the entries of an Xauthority file, stopping at the
+// first one that is cut short.
+func parseXauthority(data []byte) []xauthEntry {
+	var entries []xauthEntry
+	field := func() ([]byte, bool) {
+		if len(data) < 2 {
+			return nil, false
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+n {
+			return nil, false
+		}
+		f := data[2 : 2+n]
+		data = data[2+n:]
+		return f, true
+	}
+
+	for len(data) >= 2 {
+		family := binary.BigEndian.Uint16(data)
+		data = data[2:]
+		address, ok1 := field()
+		number, ok2 := field()
+		name, ok3 := field()
+		cookie, ok4 := field()
+		if !ok1 || !ok2 || !ok3 || !ok4 {
+			break
+		}
+		entries = append(entries, xauthEntry{
+			family:  family,
+			address: string(address),
+			number:  string(number),
+			name:    string(name),
+			data:    cookie,
+		})
+	}
+	return entries
+}